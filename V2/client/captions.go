@@ -0,0 +1,283 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elucid503/overture-play/v2/innertube"
+	"github.com/elucid503/overture-play/v2/pot"
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// CaptionOptions controls how a caption track is fetched
+type CaptionOptions struct {
+	// Timed text format to request; defaults to json3
+	Format types.CaptionFormat
+	// Language to machine-translate a translatable track into; empty keeps the original language
+	TranslateTo string
+}
+
+// parseCaptions converts the caption track list from a player response
+// Tracks record the subs PO token policy of the client that returned them, since client names are not unique
+func (c *Client) parseCaptions(data Captions, clientConfig innertube.ClientConfig) ([]types.CaptionTrack, []types.CaptionLanguage) {
+	renderer := data.PlayerCaptionsTracklistRenderer
+
+	tracks := make([]types.CaptionTrack, 0, len(renderer.CaptionTracks))
+	for _, t := range renderer.CaptionTracks {
+		kind := types.CaptionKindManual
+		if t.Kind == "asr" {
+			kind = types.CaptionKindASR
+		}
+
+		tracks = append(tracks, types.CaptionTrack{
+			LanguageCode:   t.LanguageCode,
+			Name:           t.Name.String(),
+			Kind:           kind,
+			IsTranslatable: t.IsTranslatable,
+			BaseURL:        t.BaseURL,
+			VssID:          t.VssID,
+			ClientName:     clientConfig.Name,
+
+			SubsPoTokenPolicy: clientConfig.SubsPoTokenPolicy,
+		})
+	}
+
+	languages := make([]types.CaptionLanguage, 0, len(renderer.TranslationLanguages))
+	for _, l := range renderer.TranslationLanguages {
+		languages = append(languages, types.CaptionLanguage{
+			Code: l.LanguageCode,
+			Name: l.LanguageName.String(),
+		})
+	}
+
+	return tracks, languages
+}
+
+// FetchCaptions downloads a caption track and parses its cues
+// A subs PO token is attached when the providing client's policy or the track URL asks for one
+func (c *Client) FetchCaptions(ctx context.Context, video *types.Video, track types.CaptionTrack, opts CaptionOptions) (*types.Transcript, error) {
+	if track.BaseURL == "" {
+		return nil, fmt.Errorf("caption track has no URL")
+	}
+	if opts.TranslateTo != "" && !track.IsTranslatable {
+		return nil, fmt.Errorf("caption track %s is not translatable", track.LanguageCode)
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = types.CaptionFormatJSON3
+	}
+
+	captionURL, err := url.Parse(track.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid caption URL: %w", err)
+	}
+
+	q := captionURL.Query()
+	q.Set("fmt", string(format))
+	if opts.TranslateTo != "" {
+		q.Set("tlang", opts.TranslateTo)
+	}
+
+	token, err := c.getSubsPOToken(ctx, video, track)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		q.Set("pot", token)
+		q.Set("c", track.ClientName)
+	}
+	captionURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", captionURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setBasicRequestHeaders(req)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("caption request returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		// YouTube answers with an empty body when a required PO token is missing
+		return nil, fmt.Errorf("caption request returned no data")
+	}
+
+	var cues []types.CaptionCue
+	switch format {
+	case types.CaptionFormatJSON3:
+		cues, err = parseJSON3(body)
+	case types.CaptionFormatSRV3:
+		cues, err = parseSRV3(body)
+	default:
+		return nil, fmt.Errorf("unsupported caption format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.Transcript{
+		Track:        track,
+		TranslatedTo: opts.TranslateTo,
+		Cues:         cues,
+	}, nil
+}
+
+// getSubsPOToken returns a subs PO token for the track if one is needed
+// Tracks whose URL carries exp=xpe are only served with a token
+func (c *Client) getSubsPOToken(ctx context.Context, video *types.Video, track types.CaptionTrack) (string, error) {
+	state := types.PoTokenState{Premium: c.isPremium()}
+
+	policy := track.SubsPoTokenPolicy
+	if strings.Contains(track.BaseURL, "exp=xpe") {
+		policy.Required = true
+	}
+
+	if !policy.ShouldFetch(state) {
+		return "", nil
+	}
+
+	var token string
+	var err error
+	if c.POTProvider != nil {
+		videoID := ""
+		if video != nil {
+			videoID = video.ID
+		}
+		token, err = c.POTProvider.GetPOToken(ctx, pot.TokenRequest{
+			Context:    types.PoTokenContextSubs,
+			VideoID:    videoID,
+			ClientName: track.ClientName,
+		})
+	}
+
+	if token == "" && policy.IsRequired(state) {
+		if err != nil {
+			return "", fmt.Errorf("%w: subs token: %v", ErrPoTokenRequired, err)
+		}
+		return "", fmt.Errorf("%w: subs token", ErrPoTokenRequired)
+	}
+
+	return token, nil
+}
+
+// json3Document is the json3 timed text format
+type json3Document struct {
+	Events []struct {
+		StartMs    int64 `json:"tStartMs"`
+		DurationMs int64 `json:"dDurationMs"`
+		Segs       []struct {
+			UTF8 string `json:"utf8"`
+		} `json:"segs"`
+	} `json:"events"`
+}
+
+// parseJSON3 parses json3 timed text into cues
+// Events without text, such as ASR window and line-break events, are skipped
+func parseJSON3(data []byte) ([]types.CaptionCue, error) {
+	var doc json3Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse json3 captions: %w", err)
+	}
+
+	cues := make([]types.CaptionCue, 0, len(doc.Events))
+	for _, e := range doc.Events {
+		var b strings.Builder
+		for _, seg := range e.Segs {
+			b.WriteString(seg.UTF8)
+		}
+
+		text := strings.TrimSpace(b.String())
+		if text == "" {
+			continue
+		}
+
+		cues = append(cues, types.CaptionCue{
+			Start:    time.Duration(e.StartMs) * time.Millisecond,
+			Duration: time.Duration(e.DurationMs) * time.Millisecond,
+			Text:     text,
+		})
+	}
+
+	return cues, nil
+}
+
+// parseSRV3 parses srv3 timed text into cues
+// Each <p t="ms" d="ms"> is a cue; text inside nested <s> segments is concatenated and <br> becomes a newline
+func parseSRV3(data []byte) ([]types.CaptionCue, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	var cues []types.CaptionCue
+	var current *types.CaptionCue
+	var text strings.Builder
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse srv3 captions: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				current = &types.CaptionCue{}
+				text.Reset()
+				for _, attr := range t.Attr {
+					ms, _ := strconv.ParseInt(attr.Value, 10, 64)
+					switch attr.Name.Local {
+					case "t":
+						current.Start = time.Duration(ms) * time.Millisecond
+					case "d":
+						current.Duration = time.Duration(ms) * time.Millisecond
+					}
+				}
+			case "br":
+				if current != nil {
+					text.WriteString("\n")
+				}
+			}
+
+		case xml.CharData:
+			if current != nil {
+				text.Write(t)
+			}
+
+		case xml.EndElement:
+			if t.Name.Local == "p" && current != nil {
+				current.Text = strings.TrimSpace(text.String())
+				if current.Text != "" {
+					cues = append(cues, *current)
+				}
+				current = nil
+			}
+		}
+	}
+
+	return cues, nil
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/elucid503/overture-play/v2/innertube"
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// fixtureCues are the cues shared by testdata/captions.json3 and testdata/captions.srv3
+var fixtureCues = []types.CaptionCue{
+	{Start: 1200 * time.Millisecond, Duration: 2800 * time.Millisecond, Text: "we're no strangers"},
+	{Start: 4000 * time.Millisecond, Duration: 3500 * time.Millisecond, Text: "to love"},
+	{Start: 7500 * time.Millisecond, Duration: 2000 * time.Millisecond, Text: "[Music]"},
+	{Start: 10 * time.Second, Duration: 2500 * time.Millisecond, Text: "you know the rules\nand so do I"},
+	{Start: time.Hour + 2*time.Minute + 3*time.Second, Duration: 1500 * time.Millisecond, Text: "A --> B & été"},
+}
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func checkCues(t *testing.T, got, want []types.CaptionCue) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d cues, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("cue %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseJSON3(t *testing.T) {
+	cues, err := parseJSON3(readTestdata(t, "captions.json3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCues(t, cues, fixtureCues)
+
+	if _, err := parseJSON3([]byte("<timedtext/>")); err == nil {
+		t.Error("expected an error for non-JSON input")
+	}
+}
+
+func TestParseSRV3(t *testing.T) {
+	cues, err := parseSRV3(readTestdata(t, "captions.srv3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := append([]types.CaptionCue(nil), fixtureCues...)
+	want[len(want)-1].Text += " 'quoted'"
+	checkCues(t, cues, want)
+
+	if _, err := parseSRV3([]byte(`<timedtext><body><p t="0" d="1">a</s></body>`)); err == nil {
+		t.Error("expected an error for mismatched tags")
+	}
+}
+
+func TestFetchCaptions(t *testing.T) {
+	json3 := readTestdata(t, "captions.json3")
+
+	var query map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		if r.URL.Query().Get("lang") == "empty" {
+			return
+		}
+		w.Write(json3)
+	}))
+	defer server.Close()
+
+	c := NewClient()
+	c.POTProvider = nil
+
+	track := types.CaptionTrack{LanguageCode: "en", BaseURL: server.URL + "/api/timedtext?v=dQw4w9WgXcQ&lang=en", IsTranslatable: true}
+	transcript, err := c.FetchCaptions(context.Background(), &types.Video{ID: "dQw4w9WgXcQ"}, track, CaptionOptions{TranslateTo: "de"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCues(t, transcript.Cues, fixtureCues)
+
+	if query["fmt"][0] != "json3" || query["tlang"][0] != "de" || query["v"][0] != "dQw4w9WgXcQ" {
+		t.Errorf("caption request query = %v", query)
+	}
+	if transcript.TranslatedTo != "de" || transcript.Track.LanguageCode != "en" {
+		t.Errorf("transcript translated to %q from %q", transcript.TranslatedTo, transcript.Track.LanguageCode)
+	}
+
+	track.IsTranslatable = false
+	if _, err := c.FetchCaptions(context.Background(), nil, track, CaptionOptions{TranslateTo: "de"}); err == nil {
+		t.Error("expected an error translating an untranslatable track")
+	}
+
+	track.BaseURL = server.URL + "/api/timedtext?lang=empty"
+	if _, err := c.FetchCaptions(context.Background(), nil, track, CaptionOptions{}); err == nil {
+		t.Error("expected an error for an empty response")
+	}
+}
+
+func TestCaptionTrackKeepsClientPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(readTestdata(t, "captions.json3"))
+	}))
+	defer server.Close()
+
+	// Two clients share a name but not a subs token policy, as the default WEB clients do
+	free := innertube.ClientConfig{Name: "WEB"}
+	gated := innertube.ClientConfig{Name: "WEB", SubsPoTokenPolicy: types.PoTokenPolicy{Required: true, Recommended: true}}
+
+	c := NewClient()
+	c.POTProvider = nil
+	c.Clients = []innertube.ClientConfig{free, gated}
+
+	data := Captions{PlayerCaptionsTracklistRenderer: CaptionsTracklist{
+		CaptionTracks: []CaptionTrack{{BaseURL: server.URL + "/api/timedtext?v=dQw4w9WgXcQ&lang=en", LanguageCode: "en"}},
+	}}
+
+	tracks, _ := c.parseCaptions(data, gated)
+	_, err := c.FetchCaptions(context.Background(), &types.Video{ID: "dQw4w9WgXcQ"}, tracks[0], CaptionOptions{})
+	if !errors.Is(err, ErrPoTokenRequired) {
+		t.Errorf("track from the gated client: got %v, want ErrPoTokenRequired", err)
+	}
+
+	tracks, _ = c.parseCaptions(data, free)
+	if _, err := c.FetchCaptions(context.Background(), &types.Video{ID: "dQw4w9WgXcQ"}, tracks[0], CaptionOptions{}); err != nil {
+		t.Errorf("track from the free client: %v", err)
+	}
+}
@@ -1,13 +1,17 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/elucid503/overture-play/v2/auth"
@@ -17,13 +21,25 @@ import (
 	"github.com/elucid503/overture-play/v2/types"
 )
 
+// DefaultBaseURL is the origin player discovery and player API requests go to
+const DefaultBaseURL = "https://www.youtube.com"
+
 // Client is the main YouTube client for fetching video information
+//
+// A Client is safe for concurrent use by multiple goroutines once configured.
+// The player fields are populated lazily on first use and must not be written
+// directly afterwards; use SetVisitorData and the accessor methods instead.
 type Client struct {
 	HTTPClient  *http.Client
-	POTProvider *pot.Provider
+	POTProvider pot.TokenProvider
 	Decipherer  *decipher.Decipherer
 	Auth        *auth.Auth
 
+	// Stores player scripts and extracted artifacts across restarts; nil disables caching
+	PlayerCache decipher.PlayerCache
+	// Solves signature and n challenges in place of the built-in goja solver; nil uses the built-in
+	ChallengeSolver decipher.ChallengeSolver
+
 	Clients      []innertube.ClientConfig
 	PlayerURL    string
 	PlayerID     string
@@ -33,6 +49,31 @@ type Client struct {
 	UserAgent   string
 	AcceptLang  string
 	Debug       bool
+
+	// Origin for the iframe API, watch and embed pages, player scripts and the player API,
+	// e.g. a proxy or a test server; empty uses DefaultBaseURL
+	BaseURL string
+
+	// The signed-in account has YouTube Premium, which lifts some PO token requirements
+	Premium bool
+	// Keep formats whose required PO token is missing, flagged with MissingPoToken, instead of dropping them
+	KeepFormatsMissingPoToken bool
+
+	// Query every client concurrently and merge their formats instead of stopping at the first that works
+	MergeClientFormats bool
+
+	// How often to re-check the player ID for rotations; zero disables periodic checks
+	PlayerRefreshInterval time.Duration
+	// Called after a rotated player has been loaded
+	OnPlayerChange PlayerChangeCallback
+
+	// Guards the player fields and VisitorData
+	mu               sync.RWMutex
+	playerLoad       *playerLoad
+	playerLoadedAt   time.Time
+	playerCheckedAt  time.Time
+	playerStale      bool
+	playerRefreshing bool
 }
 
 // NewClient creates a new YouTube client with default configuration
@@ -56,7 +97,14 @@ func NewClientWithOptions(opts ClientOptions) *Client {
 	if opts.HTTPClient != nil {
 		c.HTTPClient = opts.HTTPClient
 	}
-	if opts.POTServerURL != "" {
+	if len(opts.POTProviders) > 0 {
+		// Explicit providers first; a configured server URL is the last resort
+		providers := append([]pot.TokenProvider{}, opts.POTProviders...)
+		if opts.POTServerURL != "" {
+			providers = append(providers, pot.NewProvider(opts.POTServerURL, opts.HTTPClient))
+		}
+		c.POTProvider = pot.NewChain(providers...)
+	} else if opts.POTServerURL != "" {
 		c.POTProvider = pot.NewProvider(opts.POTServerURL, opts.HTTPClient)
 	}
 	if len(opts.Clients) > 0 {
@@ -68,6 +116,9 @@ func NewClientWithOptions(opts ClientOptions) *Client {
 	if opts.AcceptLang != "" {
 		c.AcceptLang = opts.AcceptLang
 	}
+	if opts.BaseURL != "" {
+		c.BaseURL = opts.BaseURL
+	}
 	if opts.Auth != nil {
 		c.Auth = opts.Auth
 		// Update HTTP client with cookie jar if auth has cookies
@@ -102,7 +153,22 @@ func NewClientWithOptions(opts ClientOptions) *Client {
 		}
 	}
 
+	if opts.PlayerCacheDir != "" {
+		if fc, err := decipher.NewFileCache(opts.PlayerCacheDir); err == nil {
+			c.PlayerCache = fc
+		}
+	}
+
+	if opts.ChallengeSolver != nil {
+		c.ChallengeSolver = opts.ChallengeSolver
+	}
+
 	c.Debug = opts.Debug
+	c.Premium = opts.Premium
+	c.KeepFormatsMissingPoToken = opts.KeepFormatsMissingPoToken
+	c.MergeClientFormats = opts.MergeClientFormats
+	c.PlayerRefreshInterval = opts.PlayerRefreshInterval
+	c.OnPlayerChange = opts.OnPlayerChange
 
 	return c
 }
@@ -111,11 +177,32 @@ func NewClientWithOptions(opts ClientOptions) *Client {
 type ClientOptions struct {
 	HTTPClient   *http.Client
 	POTServerURL string
+	// PO token providers tried in order, e.g. pot.NewStaticProvider or pot.NewScriptProvider
+	POTProviders []pot.TokenProvider
 	Clients      []innertube.ClientConfig
 	UserAgent    string
 	AcceptLang   string
 	Debug        bool
 
+	// Origin to send YouTube requests to instead of DefaultBaseURL
+	BaseURL string
+
+	// PO token policy options
+	Premium                   bool
+	KeepFormatsMissingPoToken bool
+
+	// Merge formats from every client in Clients instead of stopping at the first that works
+	MergeClientFormats bool
+
+	// Player rotation options
+	PlayerRefreshInterval time.Duration
+	OnPlayerChange        PlayerChangeCallback
+
+	// Directory for the on-disk player cache; empty disables caching
+	PlayerCacheDir string
+	// External challenge solver, e.g. decipher.NewNodeSolver(); nil uses the built-in goja solver
+	ChallengeSolver decipher.ChallengeSolver
+
 	// Authentication options
 	Auth         *auth.Auth   // Pre-configured auth
 	CookieFile   string       // Path to Netscape cookie file
@@ -124,27 +211,45 @@ type ClientOptions struct {
 
 // GetVideo fetches video information and formats
 func (c *Client) GetVideo(videoID string) (*types.Video, error) {
+	return c.GetVideoContext(context.Background(), videoID)
+}
+
+// GetVideoContext fetches video information and formats
+// The context bounds every outbound request, including player discovery and PO token generation
+func (c *Client) GetVideoContext(ctx context.Context, videoID string) (*types.Video, error) {
 	videoID = c.extractVideoID(videoID)
 	if videoID == "" {
 		return nil, fmt.Errorf("invalid video ID or URL")
 	}
 
 	// Fetch player info first
-	if err := c.ensurePlayer(); err != nil {
+	d, err := c.ensurePlayer(ctx)
+	if err != nil {
 		return nil, fmt.Errorf("failed to fetch player: %w", err)
 	}
 
-	// Try each client until one works
-	var lastErr error
+	if c.MergeClientFormats {
+		return c.getVideoMerged(ctx, d, videoID)
+	}
+
+	// Try each client until one works, collecting every failure
+	failures := &AllClientsFailedError{}
 	for _, clientConfig := range c.Clients {
-		video, err := c.fetchWithClient(videoID, clientConfig)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		video, err := c.fetchWithClient(ctx, d, videoID, clientConfig)
 		if err == nil {
 			return video, nil
 		}
-		lastErr = err
+		failures.Errors = append(failures.Errors, &ClientError{
+			ClientName: clientConfig.Name,
+			Err:        err,
+		})
 	}
 
-	return nil, fmt.Errorf("all clients failed, last error: %w", lastErr)
+	return nil, failures
 }
 
 // extractVideoID extracts the video ID from a URL or returns as-is if already an ID
@@ -175,42 +280,10 @@ func (c *Client) extractVideoID(input string) string {
 	return ""
 }
 
-// ensurePlayer fetches and caches the player script
-func (c *Client) ensurePlayer() error {
-	if c.Decipherer != nil {
-		return nil
-	}
-
-	// Fetch player URL from YouTube page
-	playerURL, err := c.fetchPlayerURL()
-	if err != nil {
-		return err
-	}
-
-	c.PlayerURL = playerURL
-	c.PlayerID = decipher.ExtractPlayerID(playerURL)
-
-	// Fetch player code
-	playerCode, err := c.fetchPlayerCode(playerURL)
-	if err != nil {
-		return err
-	}
-
-	c.PlayerCode = playerCode
-
-	// Create decipherer
-	c.Decipherer, err = decipher.NewDecipherer(playerCode)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 // fetchPlayerURL gets the current player URL from YouTube
 // Uses a clean HTTP client without cookies to avoid auth-related redirects
-func (c *Client) fetchPlayerURL() (string, error) {
-	req, err := http.NewRequest("GET", "https://www.youtube.com/iframe_api", nil)
+func (c *Client) fetchPlayerURL(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL()+"/iframe_api", nil)
 	if err != nil {
 		return "", err
 	}
@@ -218,8 +291,7 @@ func (c *Client) fetchPlayerURL() (string, error) {
 	c.setBasicRequestHeaders(req)
 
 	// Use a clean HTTP client without cookie jar to avoid auth redirects
-	cleanClient := &http.Client{Timeout: c.HTTPClient.Timeout}
-	resp, err := cleanClient.Do(req)
+	resp, err := c.cleanHTTPClient().Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -247,7 +319,7 @@ func (c *Client) fetchPlayerURL() (string, error) {
 	match := re.FindStringSubmatch(content)
 	if len(match) >= 2 {
 		playerID := match[1]
-		return fmt.Sprintf("https://www.youtube.com/s/player/%s/player_ias.vflset/en_US/base.js", playerID), nil
+		return fmt.Sprintf("%s/s/player/%s/player_ias.vflset/en_US/base.js", c.baseURL(), playerID), nil
 	}
 
 	if c.Debug {
@@ -255,14 +327,14 @@ func (c *Client) fetchPlayerURL() (string, error) {
 	}
 
 	// Fallback: fetch from YouTube page
-	return c.fetchPlayerURLFromPage()
+	return c.fetchPlayerURLFromPage(ctx)
 }
 
 // fetchPlayerURLFromPage extracts player URL from main YouTube page
 // Uses a clean HTTP client without cookies to avoid auth-related redirects
-func (c *Client) fetchPlayerURLFromPage() (string, error) {
+func (c *Client) fetchPlayerURLFromPage(ctx context.Context) (string, error) {
 	// Try a video watch page first - more reliable for extracting player
-	req, err := http.NewRequest("GET", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL()+"/watch?v=dQw4w9WgXcQ", nil)
 	if err != nil {
 		return "", err
 	}
@@ -270,8 +342,7 @@ func (c *Client) fetchPlayerURLFromPage() (string, error) {
 	c.setBasicRequestHeaders(req)
 
 	// Use a clean HTTP client without cookie jar to avoid auth redirects
-	cleanClient := &http.Client{Timeout: c.HTTPClient.Timeout}
-	resp, err := cleanClient.Do(req)
+	resp, err := c.cleanHTTPClient().Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -289,8 +360,12 @@ func (c *Client) fetchPlayerURLFromPage() (string, error) {
 	}
 
 	// Extract visitor_data from the page if we don't have it yet
-	if c.VisitorData == "" {
-		c.VisitorData = auth.ExtractVisitorDataFromHTML(pageContent)
+	if visitorData := auth.ExtractVisitorDataFromHTML(pageContent); visitorData != "" {
+		c.mu.Lock()
+		if c.VisitorData == "" {
+			c.VisitorData = visitorData
+		}
+		c.mu.Unlock()
 	}
 
 	// Look for player script URL with multiple patterns
@@ -317,7 +392,7 @@ func (c *Client) fetchPlayerURLFromPage() (string, error) {
 				playerPath = fmt.Sprintf("/s/player/%s/player_ias.vflset/en_US/base.js", playerPath)
 			}
 			if !strings.HasPrefix(playerPath, "http") {
-				playerPath = "https://www.youtube.com" + playerPath
+				playerPath = c.baseURL() + playerPath
 			}
 			if c.Debug {
 				fmt.Printf("[DEBUG] watch page: found player URL with pattern %d: %s\n", i, playerPath)
@@ -331,12 +406,12 @@ func (c *Client) fetchPlayerURLFromPage() (string, error) {
 	}
 
 	// Final fallback: try embed page which is simpler
-	return c.fetchPlayerURLFromEmbed()
+	return c.fetchPlayerURLFromEmbed(ctx)
 }
 
 // fetchPlayerURLFromEmbed extracts player URL from embed page
-func (c *Client) fetchPlayerURLFromEmbed() (string, error) {
-	req, err := http.NewRequest("GET", "https://www.youtube.com/embed/dQw4w9WgXcQ", nil)
+func (c *Client) fetchPlayerURLFromEmbed(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL()+"/embed/dQw4w9WgXcQ", nil)
 	if err != nil {
 		return "", err
 	}
@@ -344,8 +419,7 @@ func (c *Client) fetchPlayerURLFromEmbed() (string, error) {
 	c.setBasicRequestHeaders(req)
 
 	// Use a clean HTTP client without cookie jar to avoid auth redirects
-	cleanClient := &http.Client{Timeout: c.HTTPClient.Timeout}
-	resp, err := cleanClient.Do(req)
+	resp, err := c.cleanHTTPClient().Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -378,7 +452,7 @@ func (c *Client) fetchPlayerURLFromEmbed() (string, error) {
 				playerPath = fmt.Sprintf("/s/player/%s/player_ias.vflset/en_US/base.js", playerPath)
 			}
 			if !strings.HasPrefix(playerPath, "http") {
-				playerPath = "https://www.youtube.com" + playerPath
+				playerPath = c.baseURL() + playerPath
 			}
 			if c.Debug {
 				fmt.Printf("[DEBUG] embed page: found player URL with pattern %d: %s\n", i, playerPath)
@@ -395,8 +469,8 @@ func (c *Client) fetchPlayerURLFromEmbed() (string, error) {
 }
 
 // fetchPlayerCode downloads the player JavaScript code
-func (c *Client) fetchPlayerCode(playerURL string) (string, error) {
-	req, err := http.NewRequest("GET", playerURL, nil)
+func (c *Client) fetchPlayerCode(ctx context.Context, playerURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", playerURL, nil)
 	if err != nil {
 		return "", err
 	}
@@ -404,8 +478,7 @@ func (c *Client) fetchPlayerCode(playerURL string) (string, error) {
 	c.setBasicRequestHeaders(req)
 
 	// Use a clean HTTP client without cookie jar to avoid auth redirects
-	cleanClient := &http.Client{Timeout: c.HTTPClient.Timeout}
-	resp, err := cleanClient.Do(req)
+	resp, err := c.cleanHTTPClient().Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -420,23 +493,23 @@ func (c *Client) fetchPlayerCode(playerURL string) (string, error) {
 }
 
 // fetchWithClient fetches video info using a specific innertube client
-func (c *Client) fetchWithClient(videoID string, clientConfig innertube.ClientConfig) (*types.Video, error) {
-	// Get context with visitor data if available
-	var ctx innertube.InnertubeContext
+func (c *Client) fetchWithClient(ctx context.Context, d *decipher.Decipherer, videoID string, clientConfig innertube.ClientConfig) (*types.Video, error) {
+	// Get innertube context with visitor data if available
+	var itCtx innertube.InnertubeContext
 	visitorData := c.getVisitorData()
 	if visitorData != "" {
-		ctx = clientConfig.GetContextWithVisitor(visitorData)
+		itCtx = clientConfig.GetContextWithVisitor(visitorData)
 	} else {
-		ctx = clientConfig.GetContext()
+		itCtx = clientConfig.GetContext()
 	}
 
 	sts := 0
-	if c.Decipherer != nil {
-		sts = c.Decipherer.GetSignatureTimestamp()
+	if d != nil {
+		sts = d.GetSignatureTimestamp()
 	}
 
 	payload := map[string]interface{}{
-		"context": ctx,
+		"context": itCtx,
 		"videoId": videoID,
 		"playbackContext": map[string]interface{}{
 			"contentPlaybackContext": map[string]interface{}{
@@ -448,23 +521,25 @@ func (c *Client) fetchWithClient(videoID string, clientConfig innertube.ClientCo
 		"contentCheckOk": true,
 	}
 
-	// Add Player PO token if required for this client
-	playerPOToken, err := c.getPlayerPOToken(videoID, clientConfig)
+	// Add Player PO token if this client's policy asks for one
+	state := types.PoTokenState{Premium: c.isPremium()}
+	playerPOToken, err := c.getPlayerPOToken(ctx, videoID, clientConfig, state)
 	if err == nil && playerPOToken != "" {
 		payload["serviceIntegrityDimensions"] = map[string]string{
 			"poToken": playerPOToken,
 		}
+		state.PlayerToken = true
 	}
 
 	// Make player API request - no API key needed for modern clients
-	apiURL := "https://www.youtube.com/youtubei/v1/player?prettyPrint=false"
+	apiURL := c.baseURL() + "/youtubei/v1/player?prettyPrint=false"
 
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", apiURL, strings.NewReader(string(payloadBytes)))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(string(payloadBytes)))
 	if err != nil {
 		return nil, err
 	}
@@ -483,13 +558,17 @@ func (c *Client) fetchWithClient(videoID string, clientConfig innertube.ClientCo
 	}
 
 	// Parse response
-	return c.parsePlayerResponse(body, clientConfig, videoID)
+	return c.parsePlayerResponse(ctx, d, body, clientConfig, videoID, state)
+}
+
+// isPremium reports whether requests are made as a signed-in Premium subscriber
+func (c *Client) isPremium() bool {
+	return c.Premium && c.Auth != nil && c.Auth.IsLoggedIn()
 }
 
 // getPlayerPOToken gets a PO token for the player API request (bound to video ID)
-func (c *Client) getPlayerPOToken(videoID string, clientConfig innertube.ClientConfig) (string, error) {
-	// Check if client requires PO token for player
-	if !clientConfig.RequiresPoToken() {
+func (c *Client) getPlayerPOToken(ctx context.Context, videoID string, clientConfig innertube.ClientConfig, state types.PoTokenState) (string, error) {
+	if !clientConfig.ShouldFetchPoToken(types.PoTokenContextPlayer, state) {
 		return "", nil
 	}
 
@@ -498,18 +577,18 @@ func (c *Client) getPlayerPOToken(videoID string, clientConfig innertube.ClientC
 		return "", nil
 	}
 
-	if !c.POTProvider.IsAvailable() {
-		return "", nil
-	}
-
 	// Player PO token is bound to video ID
-	return c.POTProvider.GetToken(videoID)
+	return c.POTProvider.GetPOToken(ctx, pot.TokenRequest{
+		Context:    types.PoTokenContextPlayer,
+		VideoID:    videoID,
+		ClientName: clientConfig.Name,
+	})
 }
 
 // getGVSPOToken gets a GVS PO token for stream URLs (bound to visitor_data or data_sync_id)
-func (c *Client) getGVSPOToken(videoID string, clientConfig innertube.ClientConfig) (string, error) {
-	// Check if client requires PO token for GVS
-	if len(clientConfig.GVSPoTokenPolicies) == 0 {
+// protocols are those the response's streams are fetched over
+func (c *Client) getGVSPOToken(ctx context.Context, videoID string, clientConfig innertube.ClientConfig, state types.PoTokenState, protocols []types.StreamingProtocol) (string, error) {
+	if !clientConfig.ShouldFetchPoToken(types.PoTokenContextGVS, state, protocols...) {
 		return "", nil
 	}
 
@@ -518,10 +597,6 @@ func (c *Client) getGVSPOToken(videoID string, clientConfig innertube.ClientConf
 		return "", nil
 	}
 
-	if !c.POTProvider.IsAvailable() {
-		return "", nil
-	}
-
 	// GVS PO token is bound to visitor_data (unauthenticated) or data_sync_id (authenticated)
 	visitorData := c.getVisitorData()
 	dataSyncID := ""
@@ -529,11 +604,18 @@ func (c *Client) getGVSPOToken(videoID string, clientConfig innertube.ClientConf
 		dataSyncID = c.Auth.GetDataSyncID()
 	}
 
-	return c.POTProvider.GetGVSToken(visitorData, dataSyncID)
+	return c.POTProvider.GetPOToken(ctx, pot.TokenRequest{
+		Context:     types.PoTokenContextGVS,
+		VideoID:     videoID,
+		VisitorData: visitorData,
+		DataSyncID:  dataSyncID,
+		ClientName:  clientConfig.Name,
+	})
 }
 
 // parsePlayerResponse parses the player API response
-func (c *Client) parsePlayerResponse(data []byte, clientConfig innertube.ClientConfig, videoID string) (*types.Video, error) {
+// state carries the premium and player token state used to evaluate PO token policies
+func (c *Client) parsePlayerResponse(ctx context.Context, d *decipher.Decipherer, data []byte, clientConfig innertube.ClientConfig, videoID string, state types.PoTokenState) (*types.Video, error) {
 	var resp PlayerResponse
 	if err := json.Unmarshal(data, &resp); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
@@ -541,13 +623,16 @@ func (c *Client) parsePlayerResponse(data []byte, clientConfig innertube.ClientC
 
 	// Check for playability errors
 	if resp.PlayabilityStatus.Status != "OK" {
-		return nil, fmt.Errorf("video not playable: %s - %s",
-			resp.PlayabilityStatus.Status,
-			resp.PlayabilityStatus.Reason)
+		return nil, newPlayabilityError(resp.PlayabilityStatus, clientConfig.Name)
 	}
 
+	allFormats := append(resp.StreamingData.Formats, resp.StreamingData.AdaptiveFormats...)
+
 	// Get GVS PO token for stream URLs (bound to visitor_data or data_sync_id)
-	gvsPOToken, _ := c.getGVSPOToken(videoID, clientConfig)
+	gvsPOToken, err := c.getGVSPOToken(ctx, videoID, clientConfig, state, formatProtocols(allFormats))
+	if err != nil && c.Debug {
+		fmt.Printf("[DEBUG] GVS PO token unavailable for %s: %v\n", clientConfig.Name, err)
+	}
 
 	video := &types.Video{
 		ID: resp.VideoDetails.VideoID,
@@ -560,28 +645,121 @@ func (c *Client) parsePlayerResponse(data []byte, clientConfig innertube.ClientC
 		Duration: c.parseDuration(resp.VideoDetails.LengthSeconds),
 
 		ViewCount: c.parseInt(resp.VideoDetails.ViewCount),
-		IsLive:    resp.VideoDetails.IsLiveContent,
 		IsPrivate: resp.VideoDetails.IsPrivate,
 
 		Formats:    make([]types.Format, 0),
 		Thumbnails: c.parseThumbnails(resp.VideoDetails.Thumbnail),
 	}
 
-	// Parse formats
-	allFormats := append(resp.StreamingData.Formats, resp.StreamingData.AdaptiveFormats...)
+	video.FetchedAt = time.Now()
+	c.parseMetadata(video, &resp)
+	video.Captions, video.TranslationLanguages = c.parseCaptions(resp.Captions, clientConfig)
+
+	// URLs normally carry their own expire parameter; expiresInSeconds covers those that don't
+	var fallbackExpiry time.Time
+	if seconds := c.parseInt(resp.StreamingData.ExpiresInSeconds); seconds > 0 {
+		fallbackExpiry = video.FetchedAt.Add(time.Duration(seconds) * time.Second)
+	}
+
+	// Parse formats, solving each distinct challenge once for the whole response
+	solved := c.solveChallenges(d, allFormats)
+	dropped := 0
 	for _, sf := range allFormats {
-		format, err := c.parseFormat(sf, gvsPOToken)
+		format, err := c.parseFormat(d, sf, gvsPOToken, solved)
 		if err != nil {
 			continue
 		}
+
+		// A missing required token means the URL will 403
+		format.Protocol = formatProtocol(sf)
+		format.ClientName = clientConfig.Name
+		format.VideoID = video.ID
+		format.ExpiresAt = parseURLExpiry(format.URL)
+		if format.ExpiresAt.IsZero() {
+			format.ExpiresAt = fallbackExpiry
+		}
+		if gvsPOToken == "" && clientConfig.GVSPoTokenPolicy(format.Protocol).IsRequired(state) {
+			format.MissingPoToken = true
+			if !c.KeepFormatsMissingPoToken {
+				dropped++
+				continue
+			}
+		}
+
 		video.Formats = append(video.Formats, format)
 	}
 
+	if len(video.Formats) == 0 && dropped > 0 {
+		return nil, fmt.Errorf("%w: %d formats from %s need a GVS PO token", ErrPoTokenRequired, dropped, clientConfig.Name)
+	}
+
 	return video, nil
 }
 
+// formatProtocol returns the protocol a format's stream is fetched over
+// Live formats are DASH segments; all others are direct HTTPS downloads
+func formatProtocol(sf StreamingFormat) types.StreamingProtocol {
+	if sf.TargetDurationSec > 0 {
+		return types.ProtocolDASH
+	}
+	return types.ProtocolHTTPS
+}
+
+// formatProtocols returns the distinct protocols of formats, in order of first appearance
+func formatProtocols(formats []StreamingFormat) []types.StreamingProtocol {
+	var protocols []types.StreamingProtocol
+	for _, sf := range formats {
+		protocol := formatProtocol(sf)
+		if !slices.Contains(protocols, protocol) {
+			protocols = append(protocols, protocol)
+		}
+	}
+	return protocols
+}
+
+// solvedChallenges holds batch-solved signature and n values for one player response
+type solvedChallenges struct {
+	signatures map[string]string
+	n          map[string]string
+}
+
+// solveChallenges collects every signature and n value in the formats and solves each distinct one once
+func (c *Client) solveChallenges(d *decipher.Decipherer, formats []StreamingFormat) solvedChallenges {
+	var solved solvedChallenges
+	if d == nil {
+		return solved
+	}
+
+	var sigs, ns []string
+	for _, sf := range formats {
+		streamURL := sf.URL
+		if sf.SignatureCipher != "" {
+			params, err := url.ParseQuery(sf.SignatureCipher)
+			if err != nil {
+				continue
+			}
+			if s := params.Get("s"); s != "" {
+				sigs = append(sigs, s)
+			}
+			streamURL = params.Get("url")
+		}
+
+		if parsedURL, err := url.Parse(streamURL); err == nil {
+			if n := parsedURL.Query().Get("n"); n != "" {
+				ns = append(ns, n)
+			}
+		}
+	}
+
+	// A failed batch leaves the map partial; parseFormat falls back to solving individually
+	solved.signatures, _ = d.DecipherSignatures(sigs)
+	solved.n = d.SolveNChallenges(ns)
+
+	return solved
+}
+
 // parseFormat parses a streaming format and deciphers URLs
-func (c *Client) parseFormat(sf StreamingFormat, gvsPOToken string) (types.Format, error) {
+func (c *Client) parseFormat(d *decipher.Decipherer, sf StreamingFormat, gvsPOToken string, solved solvedChallenges) (types.Format, error) {
 	format := types.Format{
 		ITag:     sf.ITag,
 		MimeType: sf.MimeType,
@@ -602,6 +780,36 @@ func (c *Client) parseFormat(sf StreamingFormat, gvsPOToken string) (types.Forma
 
 		IndexRange: c.parseRange(sf.IndexRange),
 		InitRange:  c.parseRange(sf.InitRange),
+
+		ApproxDuration: time.Duration(c.parseInt(sf.ApproxDurationMs)) * time.Millisecond,
+		LastModified:   c.parseMicros(sf.LastModified),
+		Projection:     types.Projection(sf.ProjectionType),
+
+		HasDRM:      len(sf.DRMFamilies) > 0,
+		DRMFamilies: sf.DRMFamilies,
+
+		LoudnessDB: sf.LoudnessDB,
+		IsDRC:      sf.IsDRC,
+	}
+	format.SetCodecsFromMimeType()
+
+	if sf.ColorInfo != nil {
+		format.ColorInfo = &types.ColorInfo{
+			Primaries:               sf.ColorInfo.Primaries,
+			TransferCharacteristics: sf.ColorInfo.TransferCharacteristics,
+			MatrixCoefficients:      sf.ColorInfo.MatrixCoefficients,
+		}
+	}
+
+	if sf.AudioTrack != nil {
+		// Track IDs are "<language>.<index>", e.g. "en.4"
+		language, _, _ := strings.Cut(sf.AudioTrack.ID, ".")
+		format.AudioTrack = &types.AudioTrack{
+			ID:          sf.AudioTrack.ID,
+			Language:    language,
+			DisplayName: sf.AudioTrack.DisplayName,
+			IsDefault:   sf.AudioTrack.AudioIsDefault,
+		}
 	}
 
 	// Get URL
@@ -610,7 +818,7 @@ func (c *Client) parseFormat(sf StreamingFormat, gvsPOToken string) (types.Forma
 		streamURL = sf.URL
 	} else if sf.SignatureCipher != "" {
 		// Decipher the URL
-		deciphered, err := c.decipherURL(sf.SignatureCipher)
+		deciphered, err := c.decipherURL(d, sf.SignatureCipher, solved.signatures)
 		if err != nil {
 			return format, err
 		}
@@ -620,7 +828,7 @@ func (c *Client) parseFormat(sf StreamingFormat, gvsPOToken string) (types.Forma
 	}
 
 	// Process n-parameter
-	streamURL = c.processNParameter(streamURL)
+	streamURL = c.processNParameter(d, streamURL, solved.n)
 
 	// Add GVS PO token if available
 	if gvsPOToken != "" {
@@ -645,7 +853,8 @@ func (c *Client) addPOTokenToURL(streamURL string, poToken string) string {
 }
 
 // decipherURL deciphers a signature cipher
-func (c *Client) decipherURL(signatureCipher string) (string, error) {
+// Pre-solved signatures are used when present
+func (c *Client) decipherURL(d *decipher.Decipherer, signatureCipher string, solved map[string]string) (string, error) {
 	params, err := url.ParseQuery(signatureCipher)
 	if err != nil {
 		return "", err
@@ -658,8 +867,14 @@ func (c *Client) decipherURL(signatureCipher string) (string, error) {
 		signatureParam = "sig"
 	}
 
-	if signature != "" && c.Decipherer != nil {
-		deciphered := c.Decipherer.DecipherSignature(signature)
+	if signature != "" && d != nil {
+		deciphered, ok := solved[signature]
+		if !ok {
+			var err error
+			if deciphered, err = d.DecipherSignature(signature); err != nil {
+				return "", err
+			}
+		}
 
 		parsedURL, err := url.Parse(streamURL)
 		if err != nil {
@@ -676,8 +891,9 @@ func (c *Client) decipherURL(signatureCipher string) (string, error) {
 }
 
 // processNParameter processes and solves the n-parameter challenge
-func (c *Client) processNParameter(streamURL string) string {
-	if c.Decipherer == nil {
+// Pre-solved values are used when present
+func (c *Client) processNParameter(d *decipher.Decipherer, streamURL string, solved map[string]string) string {
+	if d == nil {
 		return streamURL
 	}
 
@@ -692,12 +908,18 @@ func (c *Client) processNParameter(streamURL string) string {
 		return streamURL
 	}
 
-	solved, err := c.Decipherer.SolveNChallenge(n)
-	if err != nil || solved == n {
+	result, ok := solved[n]
+	if !ok {
+		var err error
+		if result, err = d.SolveNChallenge(n); err != nil {
+			return streamURL
+		}
+	}
+	if result == n {
 		return streamURL
 	}
 
-	q.Set("n", solved)
+	q.Set("n", result)
 	parsedURL.RawQuery = q.Encode()
 	return parsedURL.String()
 }
@@ -738,6 +960,31 @@ func (c *Client) parseInt(s string) int {
 	return i
 }
 
+// parseMicros parses a microseconds-since-epoch string, returning the zero time if absent
+func (c *Client) parseMicros(s string) time.Time {
+	us, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || us <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(us)
+}
+
+// baseURL returns the origin YouTube requests are sent to, without a trailing slash
+func (c *Client) baseURL() string {
+	if c.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return strings.TrimSuffix(c.BaseURL, "/")
+}
+
+// cleanHTTPClient returns an HTTP client sharing the configured transport but without a cookie jar
+func (c *Client) cleanHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: c.HTTPClient.Transport,
+		Timeout:   c.HTTPClient.Timeout,
+	}
+}
+
 // setRequestHeaders sets standard request headers
 func (c *Client) setRequestHeaders(req *http.Request) {
 	req.Header.Set("User-Agent", c.UserAgent)
@@ -796,8 +1043,12 @@ func (c *Client) setAPIRequestHeaders(req *http.Request, clientConfig innertube.
 
 // getVisitorData returns the visitor data for API requests
 func (c *Client) getVisitorData() string {
-	if c.VisitorData != "" {
-		return c.VisitorData
+	c.mu.RLock()
+	visitorData := c.VisitorData
+	c.mu.RUnlock()
+
+	if visitorData != "" {
+		return visitorData
 	}
 	if c.Auth != nil {
 		return c.Auth.GetVisitorData()
@@ -807,7 +1058,9 @@ func (c *Client) getVisitorData() string {
 
 // SetVisitorData sets the visitor data for API requests
 func (c *Client) SetVisitorData(visitorData string) {
+	c.mu.Lock()
 	c.VisitorData = visitorData
+	c.mu.Unlock()
 }
 
 // IsAuthenticated returns true if the client has valid authentication
@@ -0,0 +1,426 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/elucid503/overture-play/v2/decipher"
+	"github.com/elucid503/overture-play/v2/innertube"
+	"github.com/elucid503/overture-play/v2/pot"
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// testPlayerID is the player the fake server advertises
+const testPlayerID = "0123abcd"
+
+// fakeSolver uppercases every challenge, counting calls
+type fakeSolver struct {
+	loads atomic.Int32
+}
+
+func (s *fakeSolver) LoadPlayer(playerID, playerCode string) error {
+	s.loads.Add(1)
+	return nil
+}
+
+func (s *fakeSolver) SolveSignatures(playerID string, sigs []string) (map[string]string, error) {
+	return solveUpper(sigs), nil
+}
+
+func (s *fakeSolver) SolveN(playerID string, challenges []string) (map[string]string, error) {
+	return solveUpper(challenges), nil
+}
+
+func solveUpper(challenges []string) map[string]string {
+	out := make(map[string]string, len(challenges))
+	for _, c := range challenges {
+		out[c] = strings.ToUpper(c)
+	}
+	return out
+}
+
+// fakeYouTube serves the iframe API, a player script and the player API, counting requests per path
+type fakeYouTube struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	hits map[string]int
+
+	// Makes the iframe API fail, as during an outage
+	down atomic.Bool
+}
+
+func newFakeYouTube(t *testing.T, playerResponse string) *fakeYouTube {
+	f := &fakeYouTube{hits: make(map[string]int)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/iframe_api", func(w http.ResponseWriter, r *http.Request) {
+		f.hit(r)
+		if f.down.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `var scriptUrl = 'https:\/\/www.youtube.com\/s\/player\/%s\/www-widgetapi.vflset\/www-widgetapi.js';`, testPlayerID)
+	})
+	mux.HandleFunc("/s/player/", func(w http.ResponseWriter, r *http.Request) {
+		f.hit(r)
+		// Slow enough that concurrent callers overlap the download
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprint(w, `var cfg = {signatureTimestamp:20000};`)
+	})
+	mux.HandleFunc("/youtubei/v1/player", func(w http.ResponseWriter, r *http.Request) {
+		f.hit(r)
+		if r.Method != http.MethodPost {
+			t.Errorf("player API called with %s", r.Method)
+		}
+		fmt.Fprint(w, playerResponse)
+	})
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeYouTube) hit(r *http.Request) {
+	f.mu.Lock()
+	f.hits[r.URL.Path]++
+	f.mu.Unlock()
+}
+
+func (f *fakeYouTube) count(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hits[path]
+}
+
+// newTestClient returns a client that sends every request to base with no PO token provider
+func newTestClient(base string, solver *fakeSolver) *Client {
+	c := NewClientWithOptions(ClientOptions{
+		BaseURL:         base,
+		ChallengeSolver: solver,
+		Clients:         []innertube.ClientConfig{{Name: "TEST", Version: "1.0", ContextName: 1}},
+	})
+	c.POTProvider = nil
+	return c
+}
+
+const testPlayerResponse = `{
+	"playabilityStatus": {"status": "OK"},
+	"videoDetails": {"videoId": "dQw4w9WgXcQ", "title": "Test", "lengthSeconds": "212", "author": "Tester", "channelId": "UC123"},
+	"streamingData": {
+		"expiresInSeconds": "21540",
+		"adaptiveFormats": [
+			{
+				"itag": 251, "mimeType": "audio/webm; codecs=\"opus\"", "bitrate": 160000, "contentLength": "4000000",
+				"audioQuality": "AUDIO_QUALITY_MEDIUM", "audioSampleRate": "48000", "audioChannels": 2,
+				"signatureCipher": "s=abc&sp=sig&url=https%3A%2F%2Frr1.googlevideo.com%2Fvideoplayback%3Fitag%3D251%26n%3Dxyz"
+			}
+		]
+	}
+}`
+
+func TestGetVideoContextConcurrent(t *testing.T) {
+	server := newFakeYouTube(t, testPlayerResponse)
+	solver := &fakeSolver{}
+	c := newTestClient(server.URL+"/", solver)
+
+	const callers = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			video, err := c.GetVideoContext(context.Background(), "dQw4w9WgXcQ")
+			if err != nil {
+				errs <- err
+				return
+			}
+			if len(video.Formats) != 1 {
+				errs <- fmt.Errorf("got %d formats, want 1", len(video.Formats))
+				return
+			}
+
+			u, err := url.Parse(video.Formats[0].URL)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if q := u.Query(); q.Get("sig") != "ABC" || q.Get("n") != "XYZ" {
+				errs <- fmt.Errorf("challenges not solved in %s", video.Formats[0].URL)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if n := server.count("/s/player/" + testPlayerID + "/player_ias.vflset/en_US/base.js"); n != 1 {
+		t.Errorf("player downloaded %d times, want 1", n)
+	}
+	if n := server.count("/iframe_api"); n != 1 {
+		t.Errorf("iframe API fetched %d times, want 1", n)
+	}
+	if n := server.count("/youtubei/v1/player"); n != callers {
+		t.Errorf("player API called %d times, want %d", n, callers)
+	}
+	if n := solver.loads.Load(); n != 1 {
+		t.Errorf("player loaded into the solver %d times, want 1", n)
+	}
+
+	info := c.GetPlayerInfo()
+	if info.ID != testPlayerID || info.SignatureTimestamp != 20000 || !strings.HasPrefix(info.URL, server.URL+"/s/player/") {
+		t.Errorf("player info = %+v", info)
+	}
+}
+
+func TestGetVideoContextCancelledLeader(t *testing.T) {
+	server := newFakeYouTube(t, testPlayerResponse)
+	c := newTestClient(server.URL, &fakeSolver{})
+
+	// The first caller gives up mid-download; the next caller must load the player itself
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetVideoContext(ctx, "dQw4w9WgXcQ"); err == nil {
+		t.Fatal("expected the cancelled caller to fail")
+	}
+
+	if _, err := c.GetVideoContext(context.Background(), "dQw4w9WgXcQ"); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetPlayerID() != testPlayerID {
+		t.Errorf("player ID = %q, want %q", c.GetPlayerID(), testPlayerID)
+	}
+}
+
+func TestPlayerCacheWithSolver(t *testing.T) {
+	server := newFakeYouTube(t, testPlayerResponse)
+	cache, err := decipher.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first client fetches the player and stores its code; the second loads it from the cache
+	for i := 0; i < 2; i++ {
+		c := newTestClient(server.URL, &fakeSolver{})
+		c.PlayerCache = cache
+
+		if _, err := c.GetVideoContext(context.Background(), "dQw4w9WgXcQ"); err != nil {
+			t.Fatal(err)
+		}
+		if c.GetPlayerInfo().SignatureTimestamp != 20000 {
+			t.Errorf("client %d: signature timestamp = %d, want 20000", i, c.GetPlayerInfo().SignatureTimestamp)
+		}
+	}
+
+	if n := server.count("/s/player/" + testPlayerID + "/player_ias.vflset/en_US/base.js"); n != 1 {
+		t.Errorf("player downloaded %d times, want 1", n)
+	}
+
+	artifacts, err := cache.Get(testPlayerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if artifacts.PlayerCode == "" || artifacts.PlayerURL == "" || artifacts.SignatureStrategy != "" {
+		t.Errorf("cached artifacts = %+v, want the code and URL only", artifacts)
+	}
+}
+
+func TestRefreshPlayerSharesLoad(t *testing.T) {
+	server := newFakeYouTube(t, testPlayerResponse)
+	solver := &fakeSolver{}
+	c := newTestClient(server.URL, solver)
+
+	// Refreshes racing the first lookup must wait for its player load rather than start their own
+	const callers = 8
+	var wg sync.WaitGroup
+	var loaded atomic.Int32
+	for i := 0; i < callers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetVideoContext(context.Background(), "dQw4w9WgXcQ"); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			changed, err := c.RefreshPlayer(context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			if changed {
+				loaded.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := server.count("/s/player/" + testPlayerID + "/player_ias.vflset/en_US/base.js"); n != 1 {
+		t.Errorf("player downloaded %d times, want 1", n)
+	}
+	if n := solver.loads.Load(); n != 1 {
+		t.Errorf("player loaded into the solver %d times, want 1", n)
+	}
+	if n := loaded.Load(); n > 1 {
+		t.Errorf("%d refreshes reported loading the player, want at most 1", n)
+	}
+
+	// With the player current, a refresh only records the check
+	if changed, err := c.RefreshPlayer(context.Background()); err != nil || changed {
+		t.Errorf("RefreshPlayer = %v, %v; want false, nil", changed, err)
+	}
+}
+
+func TestNewStreamHandlerMarksPlayerStale(t *testing.T) {
+	server := newFakeYouTube(t, testPlayerResponse)
+	c := newTestClient(server.URL, &fakeSolver{})
+
+	h := c.NewStreamHandler("dQw4w9WgXcQ")
+	if h.Resolver == nil || h.OnForbidden == nil {
+		t.Fatal("handler is not wired to the client")
+	}
+
+	h.OnForbidden(types.Format{ITag: 251})
+
+	c.mu.RLock()
+	stale := c.playerStale
+	c.mu.RUnlock()
+	if !stale {
+		t.Error("403 did not mark the player stale")
+	}
+}
+
+func TestFailedPlayerRefreshBacksOff(t *testing.T) {
+	server := newFakeYouTube(t, testPlayerResponse)
+	c := newTestClient(server.URL, &fakeSolver{})
+
+	if _, err := c.GetVideoContext(context.Background(), "dQw4w9WgXcQ"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A 403 marks the player stale long enough ago that the next lookup re-checks it, but the check fails
+	server.down.Store(true)
+	c.MarkPlayerStale()
+	c.mu.Lock()
+	c.playerCheckedAt = time.Now().Add(-2 * minPlayerRecheck)
+	c.mu.Unlock()
+
+	for i := 0; i < 5; i++ {
+		if _, err := c.GetVideoContext(context.Background(), "dQw4w9WgXcQ"); err != nil {
+			t.Fatal(err)
+		}
+		waitForRefresh(t, c)
+	}
+
+	if n := server.count("/iframe_api"); n != 2 {
+		t.Errorf("iframe API fetched %d times, want 2: the initial load and one failed check", n)
+	}
+
+	// Once the interval has passed the player is checked again
+	server.down.Store(false)
+	c.mu.Lock()
+	c.playerCheckedAt = time.Now().Add(-2 * minPlayerRecheck)
+	c.mu.Unlock()
+
+	if _, err := c.GetVideoContext(context.Background(), "dQw4w9WgXcQ"); err != nil {
+		t.Fatal(err)
+	}
+	waitForRefresh(t, c)
+
+	if n := server.count("/iframe_api"); n != 3 {
+		t.Errorf("iframe API fetched %d times, want 3", n)
+	}
+	c.mu.RLock()
+	stale := c.playerStale
+	c.mu.RUnlock()
+	if stale {
+		t.Error("player still stale after a successful check")
+	}
+}
+
+// waitForRefresh waits for a background player check started by a lookup to finish
+func waitForRefresh(t *testing.T, c *Client) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.RLock()
+		refreshing := c.playerRefreshing
+		c.mu.RUnlock()
+		if !refreshing {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("background player check did not finish")
+}
+
+// fakePOT hands out a fixed token, recording the contexts asked for
+type fakePOT struct {
+	mu       sync.Mutex
+	contexts []types.PoTokenContext
+}
+
+func (p *fakePOT) Name() string { return "fake" }
+
+func (p *fakePOT) GetPOToken(ctx context.Context, req pot.TokenRequest) (string, error) {
+	p.mu.Lock()
+	p.contexts = append(p.contexts, req.Context)
+	p.mu.Unlock()
+	return "token", nil
+}
+
+func TestGVSPoTokenFollowsFormatProtocols(t *testing.T) {
+	// A client that needs a GVS token for DASH only
+	config := innertube.ClientConfig{
+		Name: "TEST",
+		GVSPoTokenPolicies: map[types.StreamingProtocol]types.PoTokenPolicy{
+			types.ProtocolDASH: {Required: true, Recommended: true},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		format    string
+		protocol  types.StreamingProtocol
+		wantToken bool
+	}{
+		{"https", `{"itag": 251, "mimeType": "audio/webm; codecs=\"opus\"", "url": "https://rr1.googlevideo.com/videoplayback?itag=251"}`, types.ProtocolHTTPS, false},
+		{"live dash", `{"itag": 140, "mimeType": "audio/mp4; codecs=\"mp4a.40.2\"", "url": "https://rr1.googlevideo.com/videoplayback?itag=140", "targetDurationSec": 5}`, types.ProtocolDASH, true},
+	}
+
+	for _, tt := range tests {
+		provider := &fakePOT{}
+		c := NewClientWithOptions(ClientOptions{})
+		c.POTProvider = provider
+
+		body := `{"playabilityStatus": {"status": "OK"}, "videoDetails": {"videoId": "dQw4w9WgXcQ"}, "streamingData": {"adaptiveFormats": [` + tt.format + `]}}`
+		video, err := c.parsePlayerResponse(context.Background(), nil, []byte(body), config, "dQw4w9WgXcQ", types.PoTokenState{})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if got := len(provider.contexts) > 0; got != tt.wantToken {
+			t.Errorf("%s: fetched GVS token = %v, want %v", tt.name, got, tt.wantToken)
+		}
+		if len(video.Formats) != 1 || video.Formats[0].Protocol != tt.protocol {
+			t.Fatalf("%s: formats = %+v", tt.name, video.Formats)
+		}
+		if got := strings.Contains(video.Formats[0].URL, "pot=token"); got != tt.wantToken {
+			t.Errorf("%s: token in URL = %v, want %v", tt.name, got, tt.wantToken)
+		}
+	}
+}
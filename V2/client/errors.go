@@ -0,0 +1,151 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sentinel errors for playability failures, matchable with errors.Is
+var (
+	// ErrLoginRequired is returned when the video requires sign-in (typically an age gate)
+	ErrLoginRequired = errors.New("login required")
+
+	// ErrBotCheck is returned when YouTube asks to confirm the request is not from a bot
+	ErrBotCheck = errors.New("bot check required")
+
+	// ErrUnplayable is returned when the video cannot be played (typically a region block)
+	ErrUnplayable = errors.New("video unplayable")
+
+	// ErrLiveStreamOffline is returned for scheduled premieres and live streams that have not started
+	ErrLiveStreamOffline = errors.New("live stream offline")
+
+	// ErrVideoUnavailable is returned when the video has been removed or does not exist
+	ErrVideoUnavailable = errors.New("video unavailable")
+
+	// ErrNotPlayable is returned for any other non-OK playability status
+	ErrNotPlayable = errors.New("video not playable")
+
+	// ErrPoTokenRequired is returned when every format needs a PO token the provider could not supply
+	ErrPoTokenRequired = errors.New("PO token required")
+)
+
+// PlayabilityError describes a non-OK playability status returned by a single client
+type PlayabilityError struct {
+	Status     string
+	Reason     string
+	ClientName string
+
+	// Set for ErrLiveStreamOffline when the response carries a scheduled start time
+	ScheduledStartTime time.Time
+
+	// One of the sentinel errors above
+	Kind error
+}
+
+// Error returns a human-readable description of the playability failure
+func (e *PlayabilityError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Kind, e.Status)
+	if e.Reason != "" {
+		msg += " - " + e.Reason
+	}
+	if !e.ScheduledStartTime.IsZero() {
+		msg += fmt.Sprintf(" (scheduled for %s)", e.ScheduledStartTime.Format(time.RFC3339))
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error so callers can use errors.Is
+func (e *PlayabilityError) Unwrap() error {
+	return e.Kind
+}
+
+// newPlayabilityError classifies a playability status into a PlayabilityError
+func newPlayabilityError(status PlayabilityStatus, clientName string) *PlayabilityError {
+	e := &PlayabilityError{
+		Status:     status.Status,
+		Reason:     status.Reason,
+		ClientName: clientName,
+	}
+
+	reason := strings.ToLower(status.Reason)
+
+	switch {
+	case strings.Contains(reason, "not a bot"):
+		e.Kind = ErrBotCheck
+	case status.Status == "LOGIN_REQUIRED", status.Status == "AGE_CHECK_REQUIRED", status.Status == "AGE_VERIFICATION_REQUIRED":
+		e.Kind = ErrLoginRequired
+	case status.Status == "LIVE_STREAM_OFFLINE":
+		e.Kind = ErrLiveStreamOffline
+	case status.Status == "UNPLAYABLE":
+		e.Kind = ErrUnplayable
+	case status.Status == "ERROR":
+		e.Kind = ErrVideoUnavailable
+	default:
+		e.Kind = ErrNotPlayable
+	}
+
+	// Premieres and upcoming streams carry their start time in the offline slate
+	if status.LiveStreamability != nil {
+		slate := status.LiveStreamability.LiveStreamabilityRenderer.OfflineSlate.LiveStreamOfflineSlateRenderer
+		if secs, err := strconv.ParseInt(slate.ScheduledStartTime, 10, 64); err == nil && secs > 0 {
+			e.ScheduledStartTime = time.Unix(secs, 0)
+		}
+	}
+
+	return e
+}
+
+// ClientError records the failure of a single innertube client
+type ClientError struct {
+	ClientName string
+	Err        error
+}
+
+// Error returns the client name and its failure
+func (e *ClientError) Error() string {
+	return fmt.Sprintf("%s: %v", e.ClientName, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ClientError) Unwrap() error {
+	return e.Err
+}
+
+// AllClientsFailedError aggregates the failures of every client tried by GetVideo
+// errors.Is and errors.As match against any of the collected failures
+type AllClientsFailedError struct {
+	Errors []*ClientError
+}
+
+// Error returns a summary of every client failure
+func (e *AllClientsFailedError) Error() string {
+	if len(e.Errors) == 0 {
+		return "all clients failed: no clients configured"
+	}
+
+	parts := make([]string, 0, len(e.Errors))
+	for _, ce := range e.Errors {
+		parts = append(parts, ce.Error())
+	}
+	return "all clients failed: " + strings.Join(parts, "; ")
+}
+
+// Unwrap returns every client failure so errors.Is/errors.As can inspect them
+func (e *AllClientsFailedError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, ce := range e.Errors {
+		errs = append(errs, ce)
+	}
+	return errs
+}
+
+// Last returns the failure of the last client tried, or nil if none were tried
+func (e *AllClientsFailedError) Last() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e.Errors[len(e.Errors)-1].Err
+}
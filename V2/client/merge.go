@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/elucid503/overture-play/v2/decipher"
+	"github.com/elucid503/overture-play/v2/innertube"
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// clientResult is the outcome of querying one innertube client
+type clientResult struct {
+	video *types.Video
+	err   error
+}
+
+// getVideoMerged queries every configured client concurrently and merges their formats
+// Metadata comes from the first client, in c.Clients order, that succeeded
+func (c *Client) getVideoMerged(ctx context.Context, d *decipher.Decipherer, videoID string) (*types.Video, error) {
+	results := make([]clientResult, len(c.Clients))
+
+	var wg sync.WaitGroup
+	for i, clientConfig := range c.Clients {
+		wg.Add(1)
+		go func(i int, clientConfig innertube.ClientConfig) {
+			defer wg.Done()
+			video, err := c.fetchWithClient(ctx, d, videoID, clientConfig)
+			results[i] = clientResult{video: video, err: err}
+		}(i, clientConfig)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var merged *types.Video
+	var candidates []rankedFormat
+	failures := &AllClientsFailedError{}
+
+	for i, result := range results {
+		clientConfig := c.Clients[i]
+		if result.err != nil {
+			failures.Errors = append(failures.Errors, &ClientError{
+				ClientName: clientConfig.Name,
+				Err:        result.err,
+			})
+			if c.Debug {
+				fmt.Printf("[DEBUG] client %s failed: %v\n", clientConfig.Name, result.err)
+			}
+			continue
+		}
+
+		if merged == nil {
+			merged = result.video
+		} else if len(merged.Captions) == 0 && len(result.video.Captions) > 0 {
+			merged.Captions = result.video.Captions
+			merged.TranslationLanguages = result.video.TranslationLanguages
+		}
+
+		for _, f := range result.video.Formats {
+			candidates = append(candidates, rankedFormat{
+				format:      f,
+				clientIndex: i,
+				tokenFree:   !clientConfig.GVSPoTokenPolicy(f.Protocol).Required,
+			})
+		}
+	}
+
+	if merged == nil {
+		return nil, failures
+	}
+
+	merged.Formats = mergeFormats(candidates)
+	return merged, nil
+}
+
+// rankedFormat is a format together with what is known about its reliability
+type rankedFormat struct {
+	format      types.Format
+	clientIndex int
+	// The providing client never needs a GVS PO token for this protocol
+	tokenFree bool
+}
+
+// formatKey identifies a format across clients; multi-audio videos repeat an itag per audio track
+type formatKey struct {
+	itag       int
+	audioTrack string
+}
+
+// keyOf returns the identity of a format across clients and refreshes
+func keyOf(f types.Format) formatKey {
+	key := formatKey{itag: f.ITag}
+	if f.AudioTrack != nil {
+		key.audioTrack = f.AudioTrack.ID
+	}
+	return key
+}
+
+// mergeFormats deduplicates formats by itag and audio track, keeping the most reliable copy of each
+// Formats keep the position of their first occurrence
+func mergeFormats(candidates []rankedFormat) []types.Format {
+	index := make(map[formatKey]int)
+	var best []rankedFormat
+
+	for _, candidate := range candidates {
+		key := keyOf(candidate.format)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(best)
+			best = append(best, candidate)
+			continue
+		}
+		if candidate.moreReliable(best[i]) {
+			best[i] = candidate
+		}
+	}
+
+	formats := make([]types.Format, len(best))
+	for i, b := range best {
+		formats[i] = b.format
+	}
+	return formats
+}
+
+// moreReliable reports whether r is expected to stream more reliably than other
+// A URL missing a required PO token will 403, a URL that never needed one cannot be rejected for lacking one,
+// and otherwise the client listed first wins
+func (r rankedFormat) moreReliable(other rankedFormat) bool {
+	if r.format.MissingPoToken != other.format.MissingPoToken {
+		return !r.format.MissingPoToken
+	}
+	if r.tokenFree != other.tokenFree {
+		return r.tokenFree
+	}
+	return r.clientIndex < other.clientIndex
+}
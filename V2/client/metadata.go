@@ -0,0 +1,93 @@
+package client
+
+import (
+	"time"
+
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// Date layouts used by the microformat, newest first
+var microformatDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02",
+}
+
+// parseMetadata fills the video's metadata from videoDetails and the player microformat
+// The microformat is absent for some clients, in which case only videoDetails fields are set
+func (c *Client) parseMetadata(video *types.Video, resp *PlayerResponse) {
+	details := resp.VideoDetails
+
+	video.Keywords = details.Keywords
+	video.IsLiveContent = details.IsLiveContent
+	video.IsLive = details.IsLive
+	video.IsUpcoming = details.IsUpcoming
+	if details.ChannelID != "" {
+		video.ChannelURL = "https://www.youtube.com/channel/" + details.ChannelID
+	}
+
+	// A legacy age gate reason is only set on age-restricted videos
+	video.AgeRestricted = resp.PlayabilityStatus.DesktopLegacyAgeGateReason != 0
+
+	mf := resp.Microformat.PlayerMicroformatRenderer
+	if mf == nil {
+		return
+	}
+
+	video.Category = mf.Category
+	video.OwnerProfileURL = mf.OwnerProfileURL
+	video.AvailableCountries = mf.AvailableCountries
+	video.IsUnlisted = mf.IsUnlisted
+	video.LikeCount = c.parseInt(mf.LikeCount)
+
+	video.UploadDate = parseMicroformatDate(mf.UploadDate)
+	video.PublishDate = parseMicroformatDate(mf.PublishDate)
+
+	if mf.IsFamilySafe != nil {
+		video.IsFamilySafe = *mf.IsFamilySafe
+		if !video.IsFamilySafe {
+			video.AgeRestricted = true
+		}
+	}
+
+	// Some clients omit videoDetails fields the microformat carries
+	if video.Title == "" {
+		video.Title = mf.Title.String()
+	}
+	if video.Description == "" {
+		video.Description = mf.Description.String()
+	}
+	if video.Author == "" {
+		video.Author = mf.OwnerChannelName
+	}
+	if video.ChannelID == "" && mf.ExternalChannelID != "" {
+		video.ChannelID = mf.ExternalChannelID
+		video.ChannelURL = "https://www.youtube.com/channel/" + mf.ExternalChannelID
+	}
+	if video.Duration == 0 {
+		video.Duration = c.parseDuration(mf.LengthSeconds)
+	}
+	if video.ViewCount == 0 {
+		video.ViewCount = c.parseInt(mf.ViewCount)
+	}
+
+	if live := mf.LiveBroadcastDetails; live != nil {
+		video.IsLive = video.IsLive || live.IsLiveNow
+		video.LiveStartTime = parseMicroformatDate(live.StartTimestamp)
+		video.LiveEndTime = parseMicroformatDate(live.EndTimestamp)
+	}
+}
+
+// parseMicroformatDate parses a microformat date or timestamp, returning the zero time if absent or malformed
+func parseMicroformatDate(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range microformatDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
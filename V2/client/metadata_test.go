@@ -0,0 +1,186 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elucid503/overture-play/v2/innertube"
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// parseFixture runs a player response fixture through parsePlayerResponse, as fetchWithClient does
+func parseFixture(t *testing.T, name string) (*types.Video, error) {
+	t.Helper()
+	return parseTestResponse(t, readTestdata(t, name))
+}
+
+// parseTestResponse parses a player response for a TEST client with no decipherer or PO token provider
+func parseTestResponse(t *testing.T, body []byte) (*types.Video, error) {
+	t.Helper()
+
+	c := NewClient()
+	c.POTProvider = nil
+	config := innertube.ClientConfig{Name: "TEST"}
+	return c.parsePlayerResponse(context.Background(), nil, body, config, "", types.PoTokenState{})
+}
+
+// videoFromFixture parses a fixture that must be playable
+func videoFromFixture(t *testing.T, name string) *types.Video {
+	t.Helper()
+
+	video, err := parseFixture(t, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return video
+}
+
+// date parses an RFC 3339 timestamp for expectations
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+
+	d, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestParseMetadataNormal(t *testing.T) {
+	video := videoFromFixture(t, "player_normal.json")
+
+	uploaded := date(t, "2009-10-24T23:57:33-07:00")
+	if !video.UploadDate.Equal(uploaded) || !video.PublishDate.Equal(uploaded) {
+		t.Errorf("upload date %v, publish date %v, want %v", video.UploadDate, video.PublishDate, uploaded)
+	}
+	if video.IsLive || video.IsLiveContent || video.IsUpcoming {
+		t.Errorf("live %v, live content %v, upcoming %v", video.IsLive, video.IsLiveContent, video.IsUpcoming)
+	}
+	if video.AgeRestricted || !video.IsFamilySafe {
+		t.Errorf("age restricted %v, family safe %v", video.AgeRestricted, video.IsFamilySafe)
+	}
+	if strings.Join(video.AvailableCountries, ",") != "AD,AE,GB,US,ZW" {
+		t.Errorf("available countries %v", video.AvailableCountries)
+	}
+	if video.LikeCount != 17000000 || video.ViewCount != 1500000000 {
+		t.Errorf("likes %d, views %d", video.LikeCount, video.ViewCount)
+	}
+	if video.Category != "Music" || video.OwnerProfileURL != "http://www.youtube.com/@RickAstleyYT" || len(video.Keywords) != 2 {
+		t.Errorf("category %q, owner %q, keywords %v", video.Category, video.OwnerProfileURL, video.Keywords)
+	}
+	if video.ChannelURL != "https://www.youtube.com/channel/UCuAXFkgsw1L7xaCfnd5JJOw" {
+		t.Errorf("channel URL %q", video.ChannelURL)
+	}
+	if !video.LiveStartTime.IsZero() || !video.LiveEndTime.IsZero() {
+		t.Errorf("live window %v to %v on a regular video", video.LiveStartTime, video.LiveEndTime)
+	}
+	if video.ID != "dQw4w9WgXcQ" || video.Duration != 213 || video.Author != "Rick Astley" {
+		t.Errorf("ID %q, duration %v, author %q", video.ID, video.Duration, video.Author)
+	}
+
+	if len(video.Formats) != 1 {
+		t.Fatalf("got %d formats, want 1", len(video.Formats))
+	}
+	f := video.Formats[0]
+	if f.ITag != 251 || f.Protocol != types.ProtocolHTTPS || f.ClientName != "TEST" || f.VideoID != "dQw4w9WgXcQ" {
+		t.Errorf("format %d: protocol %q, client %q, video %q", f.ITag, f.Protocol, f.ClientName, f.VideoID)
+	}
+	if f.LoudnessDB == nil || *f.LoudnessDB != 0 {
+		t.Errorf("loudness %v, want a reported 0 dB", f.LoudnessDB)
+	}
+	if !f.ExpiresAt.Equal(time.Unix(1760000000, 0)) {
+		t.Errorf("expires at %v", f.ExpiresAt)
+	}
+}
+
+func TestParseMetadataLive(t *testing.T) {
+	video := videoFromFixture(t, "player_live.json")
+
+	if !video.IsLive || !video.IsLiveContent || video.IsUpcoming {
+		t.Errorf("live %v, live content %v, upcoming %v", video.IsLive, video.IsLiveContent, video.IsUpcoming)
+	}
+	if !video.LiveStartTime.Equal(date(t, "2022-07-12T15:05:52Z")) || !video.LiveEndTime.IsZero() {
+		t.Errorf("live window %v to %v", video.LiveStartTime, video.LiveEndTime)
+	}
+	if !video.UploadDate.Equal(date(t, "2022-07-12T07:53:26-07:00")) {
+		t.Errorf("upload date %v", video.UploadDate)
+	}
+	if strings.Join(video.AvailableCountries, ",") != "FR,US" || video.LikeCount != 1200000 {
+		t.Errorf("available countries %v, likes %d", video.AvailableCountries, video.LikeCount)
+	}
+	if video.AgeRestricted {
+		t.Error("live stream marked age restricted")
+	}
+
+	// Live formats are DASH segments
+	if len(video.Formats) != 1 || video.Formats[0].Protocol != types.ProtocolDASH {
+		t.Errorf("formats %+v, want one DASH format", video.Formats)
+	}
+}
+
+func TestParsePremiere(t *testing.T) {
+	_, err := parseFixture(t, "player_premiere.json")
+	if !errors.Is(err, ErrLiveStreamOffline) {
+		t.Fatalf("got %v, want ErrLiveStreamOffline", err)
+	}
+
+	var playErr *PlayabilityError
+	if !errors.As(err, &playErr) {
+		t.Fatalf("got %T, want *PlayabilityError", err)
+	}
+	if !playErr.ScheduledStartTime.Equal(date(t, "2027-01-01T12:00:00Z")) || playErr.ClientName != "TEST" {
+		t.Errorf("scheduled start %v, client %q", playErr.ScheduledStartTime, playErr.ClientName)
+	}
+}
+
+func TestParseAgeGated(t *testing.T) {
+	_, err := parseFixture(t, "player_agegated.json")
+	if !errors.Is(err, ErrLoginRequired) {
+		t.Fatalf("got %v, want ErrLoginRequired", err)
+	}
+
+	var playErr *PlayabilityError
+	if !errors.As(err, &playErr) || playErr.Status != "LOGIN_REQUIRED" || playErr.Reason != "Sign in to confirm your age" {
+		t.Errorf("got %+v", playErr)
+	}
+}
+
+func TestParseMetadataWithoutMicroformat(t *testing.T) {
+	// Signed-in, age-verified sessions get a playable response that still carries the age gate reason
+	video, err := parseTestResponse(t, []byte(`{
+		"playabilityStatus": {"status": "OK", "desktopLegacyAgeGateReason": 1},
+		"videoDetails": {"videoId": "HtVdAasjOgU", "channelId": "UC1", "isLive": true}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !video.AgeRestricted || !video.IsLive || !video.UploadDate.IsZero() || video.ChannelURL != "https://www.youtube.com/channel/UC1" {
+		t.Errorf("got %+v", video)
+	}
+}
+
+func TestParseMicroformatDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"2009-10-24T23:57:33-07:00", "2009-10-25T06:57:33Z"},
+		{"2009-10-24T23:57:33-0700", "2009-10-25T06:57:33Z"},
+		{"2009-10-24", "2009-10-24T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		if got := parseMicroformatDate(tt.in); !got.Equal(date(t, tt.want)) {
+			t.Errorf("parseMicroformatDate(%q) = %v, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "yesterday", "24/10/2009"} {
+		if got := parseMicroformatDate(in); !got.IsZero() {
+			t.Errorf("parseMicroformatDate(%q) = %v, want zero", in, got)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elucid503/overture-play/v2/decipher"
+)
+
+// minPlayerRecheck limits how often a stale player report triggers a new player check
+const minPlayerRecheck = 30 * time.Second
+
+// playerLoad tracks an in-flight player download shared by concurrent callers
+type playerLoad struct {
+	done chan struct{}
+	err  error
+}
+
+// PlayerInfo describes the currently loaded player script
+type PlayerInfo struct {
+	ID                 string
+	URL                string
+	SignatureTimestamp int
+	LoadedAt           time.Time
+	CheckedAt          time.Time
+}
+
+// PlayerChange is emitted when YouTube rotates the player script
+type PlayerChange struct {
+	OldID  string
+	NewID  string
+	OldURL string
+	NewURL string
+
+	SignatureTimestamp int
+	ChangedAt          time.Time
+}
+
+// PlayerChangeCallback is called after a new player has been loaded and swapped in
+type PlayerChangeCallback func(PlayerChange)
+
+// ensurePlayer fetches and caches the player script, returning the current decipherer
+// Concurrent callers share a single download; only one goroutine fetches the player
+// Once loaded, a due player check runs in the background while callers keep the current decipherer
+func (c *Client) ensurePlayer(ctx context.Context) (*decipher.Decipherer, error) {
+	for {
+		c.mu.Lock()
+		if c.Decipherer != nil {
+			d := c.Decipherer
+			if c.playerCheckDue() {
+				c.playerRefreshing = true
+				go c.refreshPlayerBackground()
+			}
+			c.mu.Unlock()
+			return d, nil
+		}
+
+		load, leader := c.beginPlayerLoad()
+		c.mu.Unlock()
+
+		if leader {
+			d, err := c.loadPlayer(ctx)
+			c.finishPlayerLoad(load, err)
+			return d, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-load.done:
+		}
+
+		// Retry if the leader was cancelled by its own context rather than a real failure
+		if load.err != nil && !isContextError(load.err) {
+			return nil, load.err
+		}
+	}
+}
+
+// beginPlayerLoad returns the player load in flight, or starts one led by the caller; c.mu must be held
+func (c *Client) beginPlayerLoad() (load *playerLoad, leader bool) {
+	if c.playerLoad != nil {
+		return c.playerLoad, false
+	}
+
+	c.playerLoad = &playerLoad{done: make(chan struct{})}
+	return c.playerLoad, true
+}
+
+// finishPlayerLoad records the leader's result and releases the callers waiting on it
+func (c *Client) finishPlayerLoad(load *playerLoad, err error) {
+	c.mu.Lock()
+	c.playerLoad = nil
+	load.err = err
+	c.mu.Unlock()
+	close(load.done)
+}
+
+// playerCheckDue reports whether the loaded player should be re-checked; c.mu must be held
+func (c *Client) playerCheckDue() bool {
+	if c.playerRefreshing {
+		return false
+	}
+
+	since := time.Since(c.playerCheckedAt)
+	if c.playerStale && since >= minPlayerRecheck {
+		return true
+	}
+
+	return c.PlayerRefreshInterval > 0 && since >= c.PlayerRefreshInterval
+}
+
+// refreshPlayerBackground re-checks the player outside of any caller's request
+func (c *Client) refreshPlayerBackground() {
+	timeout := c.HTTPClient.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := c.RefreshPlayer(ctx)
+	if err != nil && c.Debug {
+		fmt.Printf("[DEBUG] player refresh failed: %v\n", err)
+	}
+
+	c.mu.Lock()
+	c.playerRefreshing = false
+	if err != nil {
+		// Count a failed check too, so an outage is retried once per interval rather than on every lookup
+		c.playerCheckedAt = time.Now()
+	}
+	c.mu.Unlock()
+}
+
+// RefreshPlayer checks whether YouTube has rotated the player script and rebuilds the decipherer if so
+// The decipherer is swapped atomically; requests already holding the old one finish with it
+// A player load already in flight is waited for rather than duplicated
+// Returns true if this call loaded a new player
+func (c *Client) RefreshPlayer(ctx context.Context) (bool, error) {
+	playerURL, err := c.fetchPlayerURL(ctx)
+	if err != nil {
+		return false, err
+	}
+	playerID := decipher.ExtractPlayerID(playerURL)
+
+	for {
+		c.mu.Lock()
+		if c.Decipherer != nil && playerID == c.PlayerID {
+			c.playerCheckedAt = time.Now()
+			c.playerStale = false
+			c.mu.Unlock()
+			return false, nil
+		}
+
+		load, leader := c.beginPlayerLoad()
+		c.mu.Unlock()
+
+		if leader {
+			_, err := c.loadPlayerFrom(ctx, playerURL)
+			c.finishPlayerLoad(load, err)
+			return err == nil, err
+		}
+
+		// The other load may have been for this player; check again once it is done
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-load.done:
+		}
+	}
+}
+
+// MarkPlayerStale reports that stream URLs produced by the current player were rejected (e.g. a 403)
+// The next lookup re-checks the player ID in the background, at most once per minPlayerRecheck
+// Handlers from NewStreamHandler call it on every 403; other handlers need it set as their OnForbidden
+func (c *Client) MarkPlayerStale() {
+	c.mu.Lock()
+	c.playerStale = true
+	c.mu.Unlock()
+}
+
+// loadPlayer discovers the current player URL and loads it
+func (c *Client) loadPlayer(ctx context.Context) (*decipher.Decipherer, error) {
+	// Fetch player URL from YouTube page
+	playerURL, err := c.fetchPlayerURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.loadPlayerFrom(ctx, playerURL)
+}
+
+// loadPlayerFrom builds a decipherer for the player at playerURL and swaps it in
+// The player cache is consulted first; the script is only downloaded on a miss
+func (c *Client) loadPlayerFrom(ctx context.Context, playerURL string) (*decipher.Decipherer, error) {
+	playerID := decipher.ExtractPlayerID(playerURL)
+
+	d, playerCode, err := c.buildDecipherer(ctx, playerID, playerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	change := PlayerChange{
+		OldID:  c.PlayerID,
+		NewID:  playerID,
+		OldURL: c.PlayerURL,
+		NewURL: playerURL,
+
+		SignatureTimestamp: d.GetSignatureTimestamp(),
+		ChangedAt:          now,
+	}
+	changed := c.Decipherer != nil && c.PlayerID != playerID
+
+	c.Decipherer = d
+	c.PlayerURL = playerURL
+	c.PlayerID = playerID
+	c.PlayerCode = playerCode
+	c.playerLoadedAt = now
+	c.playerCheckedAt = now
+	c.playerStale = false
+	callback := c.OnPlayerChange
+	c.mu.Unlock()
+
+	if changed && callback != nil {
+		callback(change)
+	}
+
+	return d, nil
+}
+
+// buildDecipherer returns a decipherer and the player code, from the player cache if possible
+// With a ChallengeSolver configured, only the player code is taken from the cache
+func (c *Client) buildDecipherer(ctx context.Context, playerID, playerURL string) (*decipher.Decipherer, string, error) {
+	var playerCode string
+
+	if c.PlayerCache != nil && playerID != "" {
+		artifacts, err := c.PlayerCache.Get(playerID)
+		if err == nil {
+			// Entries written alongside an external solver hold only the code, so extraction may still be needed
+			playerCode = artifacts.PlayerCode
+			if c.ChallengeSolver == nil {
+				if d, err := decipher.NewDeciphererFromArtifacts(artifacts); err == nil {
+					return d, artifacts.PlayerCode, nil
+				}
+			}
+		}
+		if err != nil && !errors.Is(err, decipher.ErrCacheMiss) && c.Debug {
+			fmt.Printf("[DEBUG] player cache read failed: %v\n", err)
+		}
+	}
+
+	// Fetch player code
+	fetched := playerCode == ""
+	if fetched {
+		var err error
+		if playerCode, err = c.fetchPlayerCode(ctx, playerURL); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var d *decipher.Decipherer
+	var err error
+	if c.ChallengeSolver != nil {
+		d, err = decipher.NewWithSolver(playerCode, playerURL, c.ChallengeSolver)
+	} else {
+		d, err = decipher.New(playerCode, playerURL)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// A solver-backed decipherer adds nothing to a cached entry, so only new code is stored
+	if c.PlayerCache != nil && playerID != "" && (fetched || c.ChallengeSolver == nil) {
+		if err := c.PlayerCache.Put(d.Artifacts(playerID, playerURL)); err != nil && c.Debug {
+			fmt.Printf("[DEBUG] player cache write failed: %v\n", err)
+		}
+	}
+
+	return d, playerCode, nil
+}
+
+// GetPlayerURL returns the URL of the currently loaded player script
+func (c *Client) GetPlayerURL() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.PlayerURL
+}
+
+// GetPlayerID returns the ID of the currently loaded player script
+func (c *Client) GetPlayerID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.PlayerID
+}
+
+// GetPlayerInfo returns details about the currently loaded player script
+func (c *Client) GetPlayerInfo() PlayerInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	info := PlayerInfo{
+		ID:        c.PlayerID,
+		URL:       c.PlayerURL,
+		LoadedAt:  c.playerLoadedAt,
+		CheckedAt: c.playerCheckedAt,
+	}
+	if c.Decipherer != nil {
+		info.SignatureTimestamp = c.Decipherer.GetSignatureTimestamp()
+	}
+	return info
+}
+
+// isContextError reports whether err was caused by context cancellation or deadline
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/elucid503/overture-play/v2/stream"
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// RefreshFormats re-resolves the stream URLs of a previously fetched video
+// Formats are matched by itag and audio track and keep their order; formats no longer offered are removed
+// and newly offered ones are appended. On error the video is left unchanged
+func (c *Client) RefreshFormats(ctx context.Context, video *types.Video) error {
+	if video == nil || video.ID == "" {
+		return fmt.Errorf("video has no ID")
+	}
+
+	fresh, err := c.GetVideoContext(ctx, video.ID)
+	if err != nil {
+		return err
+	}
+
+	byKey := make(map[formatKey]types.Format, len(fresh.Formats))
+	for _, f := range fresh.Formats {
+		byKey[keyOf(f)] = f
+	}
+
+	formats := make([]types.Format, 0, len(fresh.Formats))
+	for _, old := range video.Formats {
+		key := keyOf(old)
+		if f, ok := byKey[key]; ok {
+			formats = append(formats, f)
+			delete(byKey, key)
+		}
+	}
+	for _, f := range fresh.Formats {
+		if _, ok := byKey[keyOf(f)]; ok {
+			formats = append(formats, f)
+		}
+	}
+
+	video.Formats = formats
+	video.FetchedAt = fresh.FetchedAt
+	video.VisitorData = fresh.VisitorData
+	video.DataSyncID = fresh.DataSyncID
+	video.PlayerURL = fresh.PlayerURL
+
+	return nil
+}
+
+// ResolveFormat fetches a fresh copy of format, matched by itag and audio track, for the given video
+func (c *Client) ResolveFormat(ctx context.Context, videoID string, format types.Format) (types.Format, error) {
+	fresh, err := c.GetVideoContext(ctx, videoID)
+	if err != nil {
+		return types.Format{}, err
+	}
+
+	key := keyOf(format)
+	for _, f := range fresh.Formats {
+		if keyOf(f) == key {
+			return f, nil
+		}
+	}
+
+	return types.Format{}, fmt.Errorf("%w: itag %d is no longer offered for %s", types.ErrFormatNotAvailable, format.ITag, videoID)
+}
+
+// FormatResolver returns a resolver for the video's formats, suitable for stream.Handler.Resolver
+func (c *Client) FormatResolver(videoID string) func(ctx context.Context, format types.Format) (types.Format, error) {
+	return func(ctx context.Context, format types.Format) (types.Format, error) {
+		return c.ResolveFormat(ctx, videoID, format)
+	}
+}
+
+// NewStreamHandler returns a stream handler wired to this client for the video's formats
+// Expired or rejected stream URLs are re-resolved with FormatResolver, and a 403 marks the player stale
+func (c *Client) NewStreamHandler(videoID string) *stream.Handler {
+	h := stream.NewHandler()
+	h.Resolver = c.FormatResolver(videoID)
+	h.OnForbidden = func(types.Format) {
+		c.MarkPlayerStale()
+	}
+	return h
+}
+
+// parseURLExpiry reads the expire parameter (Unix seconds) of a stream URL, returning the zero time if absent
+func parseURLExpiry(streamURL string) time.Time {
+	parsedURL, err := url.Parse(streamURL)
+	if err != nil {
+		return time.Time{}
+	}
+
+	seconds, err := strconv.ParseInt(parsedURL.Query().Get("expire"), 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
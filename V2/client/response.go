@@ -5,6 +5,8 @@ type PlayerResponse struct {
 	PlayabilityStatus PlayabilityStatus `json:"playabilityStatus"`
 	VideoDetails      VideoDetails      `json:"videoDetails"`
 	StreamingData     StreamingData     `json:"streamingData"`
+	Captions          Captions          `json:"captions"`
+	Microformat       Microformat       `json:"microformat"`
 }
 
 // PlayabilityStatus indicates if the video can be played
@@ -12,6 +14,7 @@ type PlayabilityStatus struct {
 	Status          string `json:"status"`
 	Reason          string `json:"reason"`
 	PlayableInEmbed bool   `json:"playableInEmbed"`
+	DesktopLegacyAgeGateReason int `json:"desktopLegacyAgeGateReason"`
 	LiveStreamability *LiveStreamability `json:"liveStreamability"`
 }
 
@@ -42,10 +45,43 @@ type VideoDetails struct {
 	ViewCount        string             `json:"viewCount"`
 	Author           string             `json:"author"`
 	IsLiveContent    bool               `json:"isLiveContent"`
+	IsLive           bool               `json:"isLive"`
+	IsUpcoming       bool               `json:"isUpcoming"`
 	IsPrivate        bool               `json:"isPrivate"`
 	IsOwnerViewing   bool               `json:"isOwnerViewing"`
 }
 
+// Microformat wraps the player microformat
+type Microformat struct {
+	PlayerMicroformatRenderer *PlayerMicroformatRenderer `json:"playerMicroformatRenderer"`
+}
+
+// PlayerMicroformatRenderer contains page-level metadata not present in videoDetails
+type PlayerMicroformatRenderer struct {
+	Title                Text                  `json:"title"`
+	Description          Text                  `json:"description"`
+	LengthSeconds        string                `json:"lengthSeconds"`
+	OwnerProfileURL      string                `json:"ownerProfileUrl"`
+	ExternalChannelID    string                `json:"externalChannelId"`
+	OwnerChannelName     string                `json:"ownerChannelName"`
+	IsFamilySafe         *bool                 `json:"isFamilySafe"`
+	IsUnlisted           bool                  `json:"isUnlisted"`
+	AvailableCountries   []string              `json:"availableCountries"`
+	ViewCount            string                `json:"viewCount"`
+	LikeCount            string                `json:"likeCount"`
+	Category             string                `json:"category"`
+	PublishDate          string                `json:"publishDate"`
+	UploadDate           string                `json:"uploadDate"`
+	LiveBroadcastDetails *LiveBroadcastDetails `json:"liveBroadcastDetails"`
+}
+
+// LiveBroadcastDetails describes the broadcast window of a live stream or premiere
+type LiveBroadcastDetails struct {
+	IsLiveNow      bool   `json:"isLiveNow"`
+	StartTimestamp string `json:"startTimestamp"`
+	EndTimestamp   string `json:"endTimestamp"`
+}
+
 // ThumbnailContainer holds thumbnail data
 type ThumbnailContainer struct {
 	Thumbnails []ThumbnailData `json:"thumbnails"`
@@ -90,14 +126,87 @@ type StreamingFormat struct {
 
 	ApproxDurationMs string     `json:"approxDurationMs"`
 	LastModified     string     `json:"lastModified"`
+	// Set on live and post-live formats, which are segments served through the DASH manifest
+	TargetDurationSec int       `json:"targetDurationSec"`
 	ProjectionType   string     `json:"projectionType"`
 
+	DRMFamilies      []string        `json:"drmFamilies"`
+	ColorInfo        *ColorInfo      `json:"colorInfo"`
+	LoudnessDB       *float64        `json:"loudnessDb"`
+	AudioTrack       *AudioTrackData `json:"audioTrack"`
+	IsDRC            bool            `json:"isDrc"`
+
 	InitRange        *RangeData `json:"initRange"`
 	IndexRange       *RangeData `json:"indexRange"`
 }
 
+// ColorInfo describes the color space of a video format
+type ColorInfo struct {
+	Primaries               string `json:"primaries"`
+	TransferCharacteristics string `json:"transferCharacteristics"`
+	MatrixCoefficients      string `json:"matrixCoefficients"`
+}
+
+// AudioTrackData identifies one audio track of a multi-audio video
+type AudioTrackData struct {
+	DisplayName    string `json:"displayName"`
+	ID             string `json:"id"`
+	AudioIsDefault bool   `json:"audioIsDefault"`
+}
+
 // RangeData represents byte ranges for streaming
 type RangeData struct {
 	Start string `json:"start"`
 	End   string `json:"end"`
 }
+
+// Text is a renderer text field, either a simple string or a list of runs
+type Text struct {
+	SimpleText string    `json:"simpleText"`
+	Runs       []TextRun `json:"runs"`
+}
+
+// TextRun is a single run of a Text
+type TextRun struct {
+	Text string `json:"text"`
+}
+
+// String returns the text with all runs joined
+func (t Text) String() string {
+	if t.SimpleText != "" {
+		return t.SimpleText
+	}
+
+	var s string
+	for _, run := range t.Runs {
+		s += run.Text
+	}
+	return s
+}
+
+// Captions wraps the caption track list
+type Captions struct {
+	PlayerCaptionsTracklistRenderer CaptionsTracklist `json:"playerCaptionsTracklistRenderer"`
+}
+
+// CaptionsTracklist lists the caption tracks and the languages they can be translated into
+type CaptionsTracklist struct {
+	CaptionTracks        []CaptionTrack        `json:"captionTracks"`
+	TranslationLanguages []TranslationLanguage `json:"translationLanguages"`
+}
+
+// CaptionTrack is a single caption track
+type CaptionTrack struct {
+	BaseURL        string `json:"baseUrl"`
+	Name           Text   `json:"name"`
+	VssID          string `json:"vssId"`
+	LanguageCode   string `json:"languageCode"`
+	Kind           string `json:"kind"`
+	IsTranslatable bool   `json:"isTranslatable"`
+}
+
+// TranslationLanguage is a language caption tracks can be machine-translated into
+type TranslationLanguage struct {
+	LanguageCode string `json:"languageCode"`
+	LanguageName Text   `json:"languageName"`
+}
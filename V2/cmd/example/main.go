@@ -61,18 +61,12 @@ func main() {
 	}
 
 	// Find best audio format
-	audioFormats := video.AudioFormats()
-	if len(audioFormats) == 0 {
-		fmt.Println("\nNo audio formats available")
+	selection, err := youtube.SelectFormats(video, "bestaudio/ba*")
+	if err != nil {
+		fmt.Printf("\nNo audio formats available: %v\n", err)
 		return
 	}
-
-	bestAudio := audioFormats[0]
-	for _, f := range audioFormats {
-		if f.Bitrate > bestAudio.Bitrate {
-			bestAudio = f
-		}
-	}
+	bestAudio := *selection.Audio()
 
 	// Create safe filename
 	safeTitle := sanitizeFilename(video.Title)
@@ -0,0 +1,185 @@
+package dash
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elucid503/overture-play/v2/internal/ebml"
+)
+
+// webmInfo is what the init segment says about how to interpret cues
+type webmInfo struct {
+	// Absolute offset of the Segment's data; cluster positions are relative to it
+	segmentStart  int64
+	timecodeScale uint64
+	duration      time.Duration
+}
+
+// ParseCues parses a WebM Cues element into a segment index
+// init holds the file from offset 0 through at least the Segment Info; index holds the Cues element
+// read from indexOffset, and size is the length of the whole file
+func ParseCues(init, index []byte, indexOffset, size int64) (*SegmentIndex, error) {
+	idx, err := parseCues(init, index, indexOffset, size)
+	if err != nil {
+		return nil, invalidIndex(err)
+	}
+	return idx, nil
+}
+
+// invalidIndex reports malformed EBML as ErrInvalidIndex, passing other errors through
+func invalidIndex(err error) error {
+	var ebmlErr *ebml.Error
+	if errors.As(err, &ebmlErr) {
+		return fmt.Errorf("%w: %s", ErrInvalidIndex, ebmlErr.Msg)
+	}
+	return err
+}
+
+// parseCues implements ParseCues
+func parseCues(init, index []byte, indexOffset, size int64) (*SegmentIndex, error) {
+	info, err := parseWebMInit(init)
+	if err != nil {
+		return nil, err
+	}
+
+	id, cuesSize, header, err := ebml.ParseHeader(index)
+	if err != nil {
+		return nil, err
+	}
+	if id != ebml.IDCues {
+		return nil, fmt.Errorf("%w: index range holds element %#x, not Cues", ErrInvalidIndex, id)
+	}
+	body := index[header:]
+	if cuesSize != ebml.UnknownSize && cuesSize < int64(len(body)) {
+		body = body[:cuesSize]
+	}
+
+	var idx SegmentIndex
+	err = ebml.EachElement(body, func(id uint64, data []byte) error {
+		if id != ebml.IDCuePoint {
+			return nil
+		}
+
+		var cueTime, position uint64
+		hasPosition := false
+		err := ebml.EachElement(data, func(id uint64, data []byte) error {
+			switch id {
+			case ebml.IDCueTime:
+				cueTime = ebml.ReadUint(data)
+			case ebml.IDCueTrackPositions:
+				if hasPosition {
+					return nil
+				}
+				return ebml.EachElement(data, func(id uint64, data []byte) error {
+					if id == ebml.IDCueClusterPosition {
+						position = ebml.ReadUint(data)
+						hasPosition = true
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+		if err != nil || !hasPosition {
+			return err
+		}
+
+		offset := info.segmentStart + int64(position)
+		if n := len(idx.Segments); n > 0 && idx.Segments[n-1].Offset == offset {
+			return nil
+		}
+
+		idx.Segments = append(idx.Segments, Segment{
+			Start:  time.Duration(cueTime * info.timecodeScale),
+			Offset: offset,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sizes and durations run up to the next cue; the last cluster ends at the Cues or the end of the file
+	for i := range idx.Segments {
+		seg := &idx.Segments[i]
+		if i+1 < len(idx.Segments) {
+			next := idx.Segments[i+1]
+			seg.Size = next.Offset - seg.Offset
+			seg.Duration = next.Start - seg.Start
+			continue
+		}
+
+		end := size
+		if indexOffset > seg.Offset {
+			end = indexOffset
+		}
+		seg.Size = end - seg.Offset
+		if info.duration > seg.Start {
+			seg.Duration = info.duration - seg.Start
+		}
+	}
+
+	return &idx, nil
+}
+
+// parseWebMInit finds the Segment data offset, timecode scale and duration in a WebM init segment
+func parseWebMInit(init []byte) (webmInfo, error) {
+	info := webmInfo{timecodeScale: ebml.DefaultTimecodeScale}
+
+	pos := 0
+	for {
+		if pos >= len(init) {
+			return info, fmt.Errorf("%w: no Segment in init data", ErrInvalidIndex)
+		}
+
+		id, size, header, err := ebml.ParseHeader(init[pos:])
+		if err != nil {
+			return info, err
+		}
+
+		if id == ebml.IDSegment {
+			pos += header
+			info.segmentStart = int64(pos)
+			break
+		}
+		if id != ebml.IDEBML || size == ebml.UnknownSize {
+			return info, fmt.Errorf("%w: expected Segment, found element %#x", ErrInvalidIndex, id)
+		}
+		pos += header + int(size)
+	}
+
+	var rawDuration float64
+	for pos < len(init) {
+		id, size, header, err := ebml.ParseHeader(init[pos:])
+		if err != nil || id == ebml.IDCluster || size == ebml.UnknownSize {
+			break
+		}
+
+		if id == ebml.IDInfo {
+			if pos+header+int(size) > len(init) {
+				return info, fmt.Errorf("%w: truncated Segment Info", ErrInvalidIndex)
+			}
+			err := ebml.EachElement(init[pos+header:pos+header+int(size)], func(id uint64, data []byte) error {
+				switch id {
+				case ebml.IDTimecodeScale:
+					if scale := ebml.ReadUint(data); scale > 0 {
+						info.timecodeScale = scale
+					}
+				case ebml.IDDuration:
+					rawDuration = ebml.ReadFloat(data)
+				}
+				return nil
+			})
+			if err != nil {
+				return info, err
+			}
+			break
+		}
+
+		pos += header + int(size)
+	}
+
+	info.duration = time.Duration(rawDuration * float64(info.timecodeScale))
+	return info, nil
+}
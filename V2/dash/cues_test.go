@@ -0,0 +1,147 @@
+package dash
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/elucid503/overture-play/v2/internal/ebml"
+)
+
+// element encodes an EBML element with a known size
+func element(id uint64, body ...[]byte) []byte {
+	var data []byte
+	for _, b := range body {
+		data = append(data, b...)
+	}
+
+	out := idBytes(id)
+	if len(data) < 0x7F {
+		out = append(out, 0x80|byte(len(data)))
+	} else {
+		out = append(out, 0x40|byte(len(data)>>8), byte(len(data)))
+	}
+	return append(out, data...)
+}
+
+// unknownSizeHeader encodes an element header whose size is not stored
+func unknownSizeHeader(id uint64) []byte {
+	return append(idBytes(id), 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+}
+
+// idBytes encodes an element ID, which keeps its length marker
+func idBytes(id uint64) []byte {
+	var out []byte
+	for id > 0 {
+		out = append([]byte{byte(id)}, out...)
+		id >>= 8
+	}
+	return out
+}
+
+// uintBody encodes an unsigned integer element body
+func uintBody(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+// cuePoint encodes a CuePoint for a cluster at a segment-relative position
+func cuePoint(ms, position uint64) []byte {
+	return element(ebml.IDCuePoint,
+		element(ebml.IDCueTime, uintBody(ms)),
+		element(ebml.IDCueTrackPositions, element(0xF7, uintBody(1)), element(ebml.IDCueClusterPosition, uintBody(position))),
+	)
+}
+
+// webmInit encodes an EBML header and the start of a Segment of unknown size with its Info
+// It also returns the offset of the Segment's data
+func webmInit(durationMs float64) ([]byte, int64) {
+	init := element(ebml.IDEBML, element(0x4282, []byte("webm")))
+	init = append(init, unknownSizeHeader(ebml.IDSegment)...)
+	segmentStart := int64(len(init))
+
+	return append(init, element(ebml.IDInfo,
+		element(ebml.IDTimecodeScale, uintBody(ebml.DefaultTimecodeScale)),
+		element(ebml.IDDuration, binary.BigEndian.AppendUint64(nil, math.Float64bits(durationMs))),
+	)...), segmentStart
+}
+
+func TestParseCues(t *testing.T) {
+	init, segmentStart := webmInit(9000)
+
+	points := [][]byte{
+		cuePoint(0, 100),
+		cuePoint(3000, 500),
+		cuePoint(3000, 500), // a second track's cue for the same cluster
+		cuePoint(6000, 900),
+	}
+
+	// Cues of unknown size run to the end of the index data
+	unknown := unknownSizeHeader(ebml.IDCues)
+	for _, p := range points {
+		unknown = append(unknown, p...)
+	}
+
+	tests := []struct {
+		name  string
+		index []byte
+	}{
+		{"known size", element(ebml.IDCues, points...)},
+		{"unknown size", unknown},
+	}
+
+	for _, tt := range tests {
+		indexOffset := segmentStart + 1300
+		idx, err := ParseCues(init, tt.index, indexOffset, indexOffset+int64(len(tt.index)))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		checkSegments(t, idx, []Segment{
+			{Start: 0, Duration: 3 * time.Second, Offset: segmentStart + 100, Size: 400},
+			{Start: 3 * time.Second, Duration: 3 * time.Second, Offset: segmentStart + 500, Size: 400},
+			{Start: 6 * time.Second, Duration: 3 * time.Second, Offset: segmentStart + 900, Size: 400},
+		})
+	}
+}
+
+func TestParseCuesAfterClusters(t *testing.T) {
+	init, segmentStart := webmInit(4000)
+
+	// Cues placed before the clusters; the last cluster runs to the end of the file
+	index := element(ebml.IDCues, cuePoint(0, 200), cuePoint(2500, 700))
+	idx, err := ParseCues(init, index, segmentStart+50, segmentStart+1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkSegments(t, idx, []Segment{
+		{Start: 0, Duration: 2500 * time.Millisecond, Offset: segmentStart + 200, Size: 500},
+		{Start: 2500 * time.Millisecond, Duration: 1500 * time.Millisecond, Offset: segmentStart + 700, Size: 300},
+	})
+}
+
+func TestParseCuesInvalid(t *testing.T) {
+	init, _ := webmInit(1000)
+	cues := element(ebml.IDCues, cuePoint(0, 100))
+	point := cuePoint(0, 100)
+
+	tests := []struct {
+		name        string
+		init, index []byte
+	}{
+		{"not cues", init, element(ebml.IDCluster, cuePoint(0, 100))},
+		{"no segment", element(ebml.IDEBML, element(0x4282, []byte("webm"))), cues},
+		{"bad vint", init, []byte{0x00}},
+		{"overrun", init, element(ebml.IDCues, point[:len(point)-1])},
+		{"unknown size header", unknownSizeHeader(ebml.IDEBML), cues},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseCues(tt.init, tt.index, 5000, 6000)
+		if !errors.Is(err, ErrInvalidIndex) {
+			t.Errorf("%s: got %v, want ErrInvalidIndex", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Package dash parses the segment indexes of DASH formats for time-based seeking.
+package dash
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrInvalidIndex is returned when index data is truncated or malformed
+var ErrInvalidIndex = errors.New("invalid segment index")
+
+// Segment is one independently decodable piece of a stream: an fMP4 fragment or a WebM cluster
+type Segment struct {
+	Start    time.Duration
+	Duration time.Duration
+
+	// Absolute byte offset and size in the file
+	Offset int64
+	Size   int64
+}
+
+// SegmentIndex lists the segments of a stream in presentation order
+type SegmentIndex struct {
+	Segments []Segment
+}
+
+// Find returns the segment containing t; t before the first segment maps to the first one
+// Returns false if the index is empty or t is past the end of the last segment
+func (idx *SegmentIndex) Find(t time.Duration) (Segment, bool) {
+	if len(idx.Segments) == 0 {
+		return Segment{}, false
+	}
+
+	i := sort.Search(len(idx.Segments), func(i int) bool {
+		return idx.Segments[i].Start > t
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	seg := idx.Segments[i]
+	if i == len(idx.Segments)-1 && seg.Duration > 0 && t >= seg.Start+seg.Duration {
+		return Segment{}, false
+	}
+
+	return seg, true
+}
+
+// Duration returns the end time of the last segment
+func (idx *SegmentIndex) Duration() time.Duration {
+	if len(idx.Segments) == 0 {
+		return 0
+	}
+	last := idx.Segments[len(idx.Segments)-1]
+	return last.Start + last.Duration
+}
+
+// ticksToDuration converts a count of timescale units to a duration without overflowing
+func ticksToDuration(ticks, timescale uint64) time.Duration {
+	secs := ticks / timescale
+	rem := ticks % timescale
+	return time.Duration(secs)*time.Second + time.Duration(rem*uint64(time.Second)/timescale)
+}
@@ -0,0 +1,114 @@
+package dash
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ParseSidx parses the first sidx box in data, which was read from byte offset base of an fMP4 file
+func ParseSidx(data []byte, base int64) (*SegmentIndex, error) {
+	for pos := 0; pos+8 <= len(data); {
+		size := uint64(binary.BigEndian.Uint32(data[pos:]))
+		boxType := string(data[pos+4 : pos+8])
+		header := 8
+
+		switch size {
+		case 0:
+			// Box extends to the end of the data
+			size = uint64(len(data) - pos)
+		case 1:
+			if pos+16 > len(data) {
+				return nil, fmt.Errorf("%w: truncated box header", ErrInvalidIndex)
+			}
+			size = binary.BigEndian.Uint64(data[pos+8:])
+			header = 16
+		}
+		if size < uint64(header) {
+			return nil, fmt.Errorf("%w: box %q has size %d", ErrInvalidIndex, boxType, size)
+		}
+
+		if boxType == "sidx" {
+			if uint64(pos)+size > uint64(len(data)) {
+				return nil, fmt.Errorf("%w: truncated sidx box", ErrInvalidIndex)
+			}
+			// Referenced offsets are relative to the first byte after the sidx box
+			anchor := base + int64(pos) + int64(size)
+			return parseSidxPayload(data[pos+header:pos+int(size)], anchor)
+		}
+
+		if uint64(len(data)-pos) < size {
+			break
+		}
+		pos += int(size)
+	}
+
+	return nil, fmt.Errorf("%w: no sidx box", ErrInvalidIndex)
+}
+
+// parseSidxPayload parses a sidx box body (ISO/IEC 14496-12 8.16.3)
+func parseSidxPayload(p []byte, anchor int64) (*SegmentIndex, error) {
+	if len(p) < 12 {
+		return nil, fmt.Errorf("%w: truncated sidx", ErrInvalidIndex)
+	}
+
+	version := p[0]
+	timescale := uint64(binary.BigEndian.Uint32(p[8:]))
+	if timescale == 0 {
+		return nil, fmt.Errorf("%w: zero timescale", ErrInvalidIndex)
+	}
+
+	var earliest, firstOffset uint64
+	q := 12
+	if version == 0 {
+		if len(p) < q+8 {
+			return nil, fmt.Errorf("%w: truncated sidx", ErrInvalidIndex)
+		}
+		earliest = uint64(binary.BigEndian.Uint32(p[q:]))
+		firstOffset = uint64(binary.BigEndian.Uint32(p[q+4:]))
+		q += 8
+	} else {
+		if len(p) < q+16 {
+			return nil, fmt.Errorf("%w: truncated sidx", ErrInvalidIndex)
+		}
+		earliest = binary.BigEndian.Uint64(p[q:])
+		firstOffset = binary.BigEndian.Uint64(p[q+8:])
+		q += 16
+	}
+
+	if len(p) < q+4 {
+		return nil, fmt.Errorf("%w: truncated sidx", ErrInvalidIndex)
+	}
+	count := int(binary.BigEndian.Uint16(p[q+2:]))
+	q += 4
+
+	if len(p) < q+count*12 {
+		return nil, fmt.Errorf("%w: sidx lists %d references but holds fewer", ErrInvalidIndex, count)
+	}
+
+	idx := &SegmentIndex{Segments: make([]Segment, 0, count)}
+	offset := anchor + int64(firstOffset)
+	ticks := earliest
+
+	for i := 0; i < count; i++ {
+		ref := binary.BigEndian.Uint32(p[q:])
+		duration := uint64(binary.BigEndian.Uint32(p[q+4:]))
+		q += 12
+
+		if ref&0x80000000 != 0 {
+			return nil, fmt.Errorf("%w: hierarchical sidx is not supported", ErrInvalidIndex)
+		}
+		size := int64(ref & 0x7fffffff)
+
+		idx.Segments = append(idx.Segments, Segment{
+			Start:    ticksToDuration(ticks, timescale),
+			Duration: ticksToDuration(ticks+duration, timescale) - ticksToDuration(ticks, timescale),
+			Offset:   offset,
+			Size:     size,
+		})
+
+		offset += size
+		ticks += duration
+	}
+
+	return idx, nil
+}
@@ -0,0 +1,165 @@
+package dash
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// sidxRef is one reference of a sidx box
+type sidxRef struct {
+	size     uint32
+	duration uint32
+	indirect bool
+}
+
+// sidxBox builds a sidx box of the given version
+func sidxBox(version byte, timescale uint32, earliest, firstOffset uint64, refs []sidxRef) []byte {
+	body := []byte{version, 0, 0, 0}
+	body = binary.BigEndian.AppendUint32(body, 1) // reference_ID
+	body = binary.BigEndian.AppendUint32(body, timescale)
+	if version == 0 {
+		body = binary.BigEndian.AppendUint32(body, uint32(earliest))
+		body = binary.BigEndian.AppendUint32(body, uint32(firstOffset))
+	} else {
+		body = binary.BigEndian.AppendUint64(body, earliest)
+		body = binary.BigEndian.AppendUint64(body, firstOffset)
+	}
+	body = binary.BigEndian.AppendUint16(body, 0)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(refs)))
+
+	for _, ref := range refs {
+		typed := ref.size
+		if ref.indirect {
+			typed |= 0x80000000
+		}
+		body = binary.BigEndian.AppendUint32(body, typed)
+		body = binary.BigEndian.AppendUint32(body, ref.duration)
+		body = binary.BigEndian.AppendUint32(body, 0x90000000) // starts with SAP
+	}
+
+	box := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	box = append(box, "sidx"...)
+	return append(box, body...)
+}
+
+// box builds a plain box with an 8 byte header
+func box(boxType string, body []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	b = append(b, boxType...)
+	return append(b, body...)
+}
+
+// checkSegments compares parsed segments with the expected ones
+func checkSegments(t *testing.T, got *SegmentIndex, want []Segment) {
+	t.Helper()
+
+	if len(got.Segments) != len(want) {
+		t.Fatalf("got %d segments %+v, want %d", len(got.Segments), got.Segments, len(want))
+	}
+	for i := range want {
+		if got.Segments[i] != want[i] {
+			t.Errorf("segment %d = %+v, want %+v", i, got.Segments[i], want[i])
+		}
+	}
+}
+
+func TestParseSidxVersion0(t *testing.T) {
+	sidx := sidxBox(0, 44100, 0, 0, []sidxRef{
+		{size: 1000, duration: 441000},
+		{size: 2000, duration: 441000},
+		{size: 500, duration: 220500},
+	})
+
+	// A box before the sidx is skipped; data was read from offset 700 of the file
+	data := append(box("free", make([]byte, 8)), sidx...)
+	idx, err := ParseSidx(data, 700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := int64(700 + len(data))
+	checkSegments(t, idx, []Segment{
+		{Start: 0, Duration: 10 * time.Second, Offset: first, Size: 1000},
+		{Start: 10 * time.Second, Duration: 10 * time.Second, Offset: first + 1000, Size: 2000},
+		{Start: 20 * time.Second, Duration: 5 * time.Second, Offset: first + 3000, Size: 500},
+	})
+	if idx.Duration() != 25*time.Second {
+		t.Errorf("duration = %v", idx.Duration())
+	}
+}
+
+func TestParseSidxVersion1(t *testing.T) {
+	// 64-bit fields: an earliest time past 2^32 ticks and a gap before the first segment
+	const timescale = 90000
+	earliest := uint64(50000) * timescale
+	sidx := sidxBox(1, timescale, earliest, 64, []sidxRef{
+		{size: 4096, duration: 2 * timescale},
+		{size: 8192, duration: timescale / 2},
+	})
+
+	idx, err := ParseSidx(sidx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := int64(len(sidx) + 64)
+	start := 50000 * time.Second
+	checkSegments(t, idx, []Segment{
+		{Start: start, Duration: 2 * time.Second, Offset: first, Size: 4096},
+		{Start: start + 2*time.Second, Duration: 500 * time.Millisecond, Offset: first + 4096, Size: 8192},
+	})
+}
+
+func TestParseSidxInvalid(t *testing.T) {
+	valid := sidxBox(0, 1000, 0, 0, []sidxRef{{size: 10, duration: 1000}, {size: 10, duration: 1000}})
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"no sidx", box("moof", make([]byte, 16))},
+		{"truncated", valid[:len(valid)-4]},
+		{"zero timescale", sidxBox(0, 0, 0, 0, nil)},
+		{"hierarchical", sidxBox(0, 1000, 0, 0, []sidxRef{{size: 10, duration: 1000, indirect: true}})},
+		{"short box", []byte{0, 0, 0, 4, 's', 'i', 'd', 'x'}},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseSidx(tt.data, 0); !errors.Is(err, ErrInvalidIndex) {
+			t.Errorf("%s: got %v, want ErrInvalidIndex", tt.name, err)
+		}
+	}
+}
+
+func TestSegmentIndexFind(t *testing.T) {
+	idx := &SegmentIndex{Segments: []Segment{
+		{Start: time.Second, Duration: 2 * time.Second, Offset: 100},
+		{Start: 3 * time.Second, Duration: 2 * time.Second, Offset: 200},
+	}}
+
+	tests := []struct {
+		t      time.Duration
+		offset int64
+		ok     bool
+	}{
+		{0, 100, true},
+		{time.Second, 100, true},
+		{2999 * time.Millisecond, 100, true},
+		{3 * time.Second, 200, true},
+		{4999 * time.Millisecond, 200, true},
+		{5 * time.Second, 0, false},
+	}
+
+	for _, tt := range tests {
+		seg, ok := idx.Find(tt.t)
+		if ok != tt.ok || seg.Offset != tt.offset {
+			t.Errorf("Find(%v) = %d, %v; want %d, %v", tt.t, seg.Offset, ok, tt.offset, tt.ok)
+		}
+	}
+
+	if _, ok := (&SegmentIndex{}).Find(0); ok {
+		t.Error("found a segment in an empty index")
+	}
+}
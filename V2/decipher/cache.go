@@ -0,0 +1,150 @@
+package decipher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// artifactsVersion is bumped whenever the extraction logic changes so stale cache entries are ignored
+const artifactsVersion = 3
+
+// ErrCacheMiss is returned by a PlayerCache when no entry exists for a player ID
+var ErrCacheMiss = errors.New("player cache miss")
+
+// PlayerArtifacts holds a player script together with everything extracted from it
+type PlayerArtifacts struct {
+	Version   int       `json:"version"`
+	PlayerID  string    `json:"playerId"`
+	PlayerURL string    `json:"playerUrl"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	SignatureStrategy     SignatureStrategy `json:"signatureStrategy"`
+	SignatureTokens       []string          `json:"signatureTokens"`
+	SignatureFunctionCode string            `json:"signatureFunctionCode"`
+	NSolverMode           NSolverMode       `json:"nSolverMode"`
+	NFunctionCode         string            `json:"nFunctionCode"`
+	SignatureTimestamp    int               `json:"signatureTimestamp"`
+
+	// Raw player JavaScript, stored separately from the metadata
+	PlayerCode string `json:"-"`
+}
+
+// PlayerCache stores player scripts and their extracted artifacts keyed by player ID
+type PlayerCache interface {
+	// Get returns the artifacts for a player ID, or ErrCacheMiss if none are stored
+	Get(playerID string) (*PlayerArtifacts, error)
+
+	// Put stores the artifacts under their player ID
+	Put(artifacts *PlayerArtifacts) error
+}
+
+// playerIDRegex restricts cache keys to safe file names
+var playerIDRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// FileCache is a PlayerCache backed by a directory on disk
+// Each player is stored as <id>.js (raw code) and <id>.json (extracted artifacts)
+type FileCache struct {
+	Dir string
+}
+
+// NewFileCache creates a filesystem player cache rooted at dir, creating it if needed
+func NewFileCache(dir string) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	return &FileCache{Dir: dir}, nil
+}
+
+// Get loads the artifacts for a player ID from disk
+func (fc *FileCache) Get(playerID string) (*PlayerArtifacts, error) {
+	if !playerIDRegex.MatchString(playerID) {
+		return nil, ErrCacheMiss
+	}
+
+	meta, err := os.ReadFile(fc.path(playerID, ".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var artifacts PlayerArtifacts
+	if err := json.Unmarshal(meta, &artifacts); err != nil {
+		return nil, fmt.Errorf("failed to decode cached artifacts: %w", err)
+	}
+
+	if artifacts.Version != artifactsVersion || artifacts.PlayerID != playerID {
+		return nil, ErrCacheMiss
+	}
+
+	code, err := os.ReadFile(fc.path(playerID, ".js"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	artifacts.PlayerCode = string(code)
+
+	return &artifacts, nil
+}
+
+// Put writes the artifacts for a player ID to disk
+// Files are written to a temporary name and renamed so readers never see partial entries
+func (fc *FileCache) Put(artifacts *PlayerArtifacts) error {
+	if artifacts == nil {
+		return fmt.Errorf("no artifacts to cache")
+	}
+	if !playerIDRegex.MatchString(artifacts.PlayerID) {
+		return fmt.Errorf("invalid player ID for cache: %q", artifacts.PlayerID)
+	}
+
+	meta, err := json.Marshal(artifacts)
+	if err != nil {
+		return fmt.Errorf("failed to encode artifacts: %w", err)
+	}
+
+	// Code first, so a metadata file always has its code alongside
+	if err := writeFileAtomic(fc.path(artifacts.PlayerID, ".js"), []byte(artifacts.PlayerCode)); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(fc.path(artifacts.PlayerID, ".json"), meta)
+}
+
+// path returns the on-disk path for a player ID and extension
+func (fc *FileCache) path(playerID, ext string) string {
+	return filepath.Join(fc.Dir, playerID+ext)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and renames it into place
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
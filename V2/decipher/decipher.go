@@ -8,18 +8,21 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
+	"time"
 )
 
 // Decipherer handles signature and n-parameter challenges
+// Solving is delegated to a ChallengeSolver; results are memoized per player
+// It is safe for concurrent use
 type Decipherer struct {
 	playerCode    string
 	playerURL     string
+	playerID      string
+	sts           int
 
-	sigTokens     []string
-	nSolver       *NSolver
-
-	mu sync.Mutex
+	solver        ChallengeSolver
+	builtin       *GojaSolver
+	memo          *memo
 }
 
 // JavaScript regex patterns for signature function extraction
@@ -54,53 +57,193 @@ var (
 	nFuncBodyRegex = regexp.MustCompile(`(?s)var %s=\{.*?\};`)
 )
 
-// New creates a new Decipherer with the given player JS code
+// New creates a new Decipherer with the given player JS code, solved by the built-in GojaSolver
+// The player URL identifies the player in memoized results
 func New(playerCode, playerURL string) (*Decipherer, error) {
-	d := &Decipherer{
-		playerCode: playerCode,
-		playerURL:  playerURL,
+	playerID := ExtractPlayerID(playerURL)
+
+	builtin, err := NewGojaSolver(playerID, playerCode)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newDecipherer(playerCode, playerURL, playerID, GetSignatureTimestamp(playerCode))
+	d.solver = builtin
+	d.builtin = builtin
+
+	return d, nil
+}
+
+// NewWithSolver creates a new Decipherer that delegates all challenge solving to solver
+// Solvers implementing PlayerLoader are given the player code first
+func NewWithSolver(playerCode, playerURL string, solver ChallengeSolver) (*Decipherer, error) {
+	if solver == nil {
+		return nil, fmt.Errorf("no challenge solver")
 	}
 
-	if err := d.extractSignatureTokens(); err != nil {
-		return nil, fmt.Errorf("failed to extract signature tokens: %w", err)
+	playerID := ExtractPlayerID(playerURL)
+
+	if loader, ok := solver.(PlayerLoader); ok {
+		if err := loader.LoadPlayer(playerID, playerCode); err != nil {
+			return nil, fmt.Errorf("failed to load player into solver: %w", err)
+		}
 	}
 
+	d := newDecipherer(playerCode, playerURL, playerID, GetSignatureTimestamp(playerCode))
+	d.solver = solver
+
 	return d, nil
 }
 
 // NewDecipherer creates a new Decipherer from player code
 func NewDecipherer(playerCode string) (*Decipherer, error) {
-	d := &Decipherer{
-		playerCode: playerCode,
-	}
+	return New(playerCode, "")
+}
 
-	if err := d.extractSignatureTokens(); err != nil {
-		return nil, fmt.Errorf("failed to extract signature tokens: %w", err)
+// NewDeciphererFromArtifacts creates a Decipherer from previously extracted artifacts
+// No regex extraction is run against the player code
+func NewDeciphererFromArtifacts(a *PlayerArtifacts) (*Decipherer, error) {
+	if a == nil {
+		return nil, fmt.Errorf("no player artifacts")
 	}
 
-	// Initialize n-solver
-	nSolver, err := NewNSolver(playerCode)
+	builtin, err := newGojaSolverFromArtifacts(a)
 	if err != nil {
-		// N-solver is optional, some videos don't need it
-		d.nSolver = nil
-	} else {
-		d.nSolver = nSolver
+		return nil, err
 	}
 
+	d := newDecipherer(a.PlayerCode, a.PlayerURL, a.PlayerID, a.SignatureTimestamp)
+	d.solver = builtin
+	d.builtin = builtin
+
 	return d, nil
 }
 
+// newDecipherer creates a Decipherer without a solver
+func newDecipherer(playerCode, playerURL, playerID string, sts int) *Decipherer {
+	return &Decipherer{
+		playerCode: playerCode,
+		playerURL:  playerURL,
+		playerID:   playerID,
+		sts:        sts,
+		memo:       newMemo(DefaultMemoSize),
+	}
+}
+
+// Artifacts returns the player code and everything extracted from it, for caching
+// When an external solver is used nothing was extracted, so only the code and signature timestamp are set
+func (d *Decipherer) Artifacts(playerID, playerURL string) *PlayerArtifacts {
+	a := &PlayerArtifacts{
+		Version:   artifactsVersion,
+		PlayerID:  playerID,
+		PlayerURL: playerURL,
+		CreatedAt: time.Now(),
+
+		SignatureTimestamp: d.sts,
+
+		PlayerCode: d.playerCode,
+	}
+
+	g := d.builtin
+	if g == nil {
+		return a
+	}
+
+	a.SignatureStrategy = g.sigStrategy
+	a.SignatureTokens = g.sigTokens
+
+	if g.sigSolver != nil {
+		a.SignatureFunctionCode = g.sigSolver.sigFuncCode
+	}
+	if g.nSolver != nil {
+		a.NSolverMode = g.nSolver.mode
+		a.NFunctionCode = g.nSolver.nFuncCode
+	}
+
+	return a
+}
+
+// Solver returns the ChallengeSolver this Decipherer delegates to
+func (d *Decipherer) Solver() ChallengeSolver {
+	return d.solver
+}
+
+// NSolverMode returns how the n function is executed, or "" if no n function was found or an external solver is used
+func (d *Decipherer) NSolverMode() NSolverMode {
+	if d.builtin == nil || d.builtin.nSolver == nil {
+		return ""
+	}
+	return d.builtin.nSolver.mode
+}
+
+// SignatureStrategy returns how this Decipherer transforms signatures
+func (d *Decipherer) SignatureStrategy() SignatureStrategy {
+	if d.builtin == nil {
+		return SignatureStrategyExternal
+	}
+	return d.builtin.sigStrategy
+}
+
 // GetSignatureTimestamp returns the signature timestamp from the player code
 func (d *Decipherer) GetSignatureTimestamp() int {
-	return GetSignatureTimestamp(d.playerCode)
+	return d.sts
 }
 
-// SolveNChallenge solves the n-parameter challenge using the JS runtime
+// SolveNChallenge solves the n-parameter challenge
+// Results are memoized per player; the built-in solver pools its runtimes, so concurrent calls run in parallel
 func (d *Decipherer) SolveNChallenge(n string) (string, error) {
-	if d.nSolver == nil {
-		return n, nil
+	key := memoKey{playerID: d.playerID, kind: memoN, input: n}
+	if solved, ok := d.memo.get(key); ok {
+		return solved, nil
+	}
+
+	results, err := d.solver.SolveN(d.playerID, []string{n})
+	if err != nil {
+		return n, err
+	}
+
+	solved, ok := results[n]
+	if !ok {
+		return n, ErrNChallengeFailed
 	}
-	return d.nSolver.Solve(n)
+
+	d.memo.put(key, solved)
+	return solved, nil
+}
+
+// SolveNChallenges solves a batch of n challenges, solving each distinct uncached value once
+// Values that fail to solve map to themselves
+func (d *Decipherer) SolveNChallenges(challenges []string) map[string]string {
+	results := make(map[string]string, len(challenges))
+
+	var pending []string
+	for _, n := range challenges {
+		if _, ok := results[n]; ok {
+			continue
+		}
+		if solved, ok := d.memo.get(memoKey{playerID: d.playerID, kind: memoN, input: n}); ok {
+			results[n] = solved
+			continue
+		}
+		results[n] = n
+		pending = append(pending, n)
+	}
+
+	if len(pending) == 0 {
+		return results
+	}
+
+	solved, err := d.solver.SolveN(d.playerID, pending)
+	if err != nil {
+		return results
+	}
+
+	for n, out := range solved {
+		results[n] = out
+		d.memo.put(memoKey{playerID: d.playerID, kind: memoN, input: n}, out)
+	}
+
+	return results
 }
 
 // DecipherURL deciphers a stream URL by solving signature and n-parameter challenges
@@ -115,7 +258,10 @@ func (d *Decipherer) DecipherURL(streamURL string) (string, error) {
 	// Check for signature cipher in query
 	if sig := query.Get("s"); sig != "" {
 		// Decipher signature
-		decipheredSig := d.decipherSignature(sig)
+		decipheredSig, err := d.DecipherSignature(sig)
+		if err != nil {
+			return "", err
+		}
 
 		// Get signature parameter name (usually "sig" or "signature")
 		sp := query.Get("sp")
@@ -130,7 +276,7 @@ func (d *Decipherer) DecipherURL(streamURL string) (string, error) {
 
 	// Handle n-parameter for throttle bypass
 	if n := query.Get("n"); n != "" {
-		newN, err := d.solveNChallenge(n)
+		newN, err := d.SolveNChallenge(n)
 		if err == nil && newN != "" {
 			query.Set("n", newN)
 		}
@@ -140,16 +286,69 @@ func (d *Decipherer) DecipherURL(streamURL string) (string, error) {
 	return parsed.String(), nil
 }
 
-// DecipherSignature deciphers a signature using the extracted tokens
-func (d *Decipherer) DecipherSignature(sig string) string {
-	return d.decipherSignature(sig)
+// DecipherSignature deciphers a signature
+// Results are memoized per player
+func (d *Decipherer) DecipherSignature(sig string) (string, error) {
+	results, err := d.DecipherSignatures([]string{sig})
+	if err != nil {
+		return "", err
+	}
+	return results[sig], nil
 }
 
-// decipherSignature applies the signature transformation
-func (d *Decipherer) decipherSignature(sig string) string {
+// DecipherSignatures deciphers a batch of signatures, solving each distinct uncached value once
+func (d *Decipherer) DecipherSignatures(sigs []string) (map[string]string, error) {
+	results := make(map[string]string, len(sigs))
+
+	var pending []string
+	for _, sig := range sigs {
+		if _, ok := results[sig]; ok {
+			continue
+		}
+		if deciphered, ok := d.memo.get(memoKey{playerID: d.playerID, kind: memoSignature, input: sig}); ok {
+			results[sig] = deciphered
+			continue
+		}
+		results[sig] = ""
+		pending = append(pending, sig)
+	}
+
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	solved, err := d.solver.SolveSignatures(d.playerID, pending)
+	if err != nil {
+		for _, sig := range pending {
+			delete(results, sig)
+		}
+		return results, err
+	}
+
+	for _, sig := range pending {
+		deciphered, ok := solved[sig]
+		if !ok {
+			delete(results, sig)
+			err = fmt.Errorf("signature %q was not solved", sig)
+			continue
+		}
+		results[sig] = deciphered
+		d.memo.put(memoKey{playerID: d.playerID, kind: memoSignature, input: sig}, deciphered)
+	}
+
+	return results, err
+}
+
+// MemoStats returns hit/miss statistics for memoized signature and n results
+func (d *Decipherer) MemoStats() MemoStats {
+	return d.memo.stats()
+}
+
+// decipherSignature applies the signature transformation tokens
+func (g *GojaSolver) decipherSignature(sig string) string {
 	arr := strings.Split(sig, "")
 
-	for _, token := range d.sigTokens {
+	for _, token := range g.sigTokens {
 		if len(token) < 1 {
 			continue
 		}
@@ -185,14 +384,35 @@ func (d *Decipherer) decipherSignature(sig string) string {
 	return strings.Join(arr, "")
 }
 
+// initSignature picks a signature strategy: regex tokens first, then the JS runtime
+func (g *GojaSolver) initSignature() error {
+	tokenErr := g.extractSignatureTokens()
+	if tokenErr == nil && len(g.sigTokens) > 0 {
+		g.sigStrategy = SignatureStrategyTokens
+		return nil
+	}
+	if tokenErr == nil {
+		tokenErr = fmt.Errorf("no signature tokens matched")
+	}
+
+	solver, err := NewSigSolver(g.playerCode)
+	if err != nil {
+		return fmt.Errorf("%w (tokens: %v; js: %v)", ErrSignatureNotFound, tokenErr, err)
+	}
+
+	g.sigTokens = nil
+	g.sigSolver = solver
+	g.sigStrategy = SignatureStrategyJS
+	return nil
+}
+
 // extractSignatureTokens extracts signature transformation tokens from player code
-func (d *Decipherer) extractSignatureTokens() error {
-	objects := actionsObjRegex.FindStringSubmatch(d.playerCode)
-	functions := actionsFuncRegex.FindStringSubmatch(d.playerCode)
+func (g *GojaSolver) extractSignatureTokens() error {
+	objects := actionsObjRegex.FindStringSubmatch(g.playerCode)
+	functions := actionsFuncRegex.FindStringSubmatch(g.playerCode)
 
 	if len(objects) < 3 || len(functions) < 2 {
-		// Try alternative extraction methods
-		return d.extractSignatureTokensAlt()
+		return fmt.Errorf("signature action patterns not found")
 	}
 
 	obj := strings.ReplaceAll(objects[1], "$", "\\$")
@@ -226,43 +446,19 @@ func (d *Decipherer) extractSignatureTokens() error {
 
 		switch key {
 		case reverseKey:
-			d.sigTokens = append(d.sigTokens, "r")
+			g.sigTokens = append(g.sigTokens, "r")
 		case sliceKey:
-			d.sigTokens = append(d.sigTokens, "s"+result[4])
+			g.sigTokens = append(g.sigTokens, "s"+result[4])
 		case spliceKey:
-			d.sigTokens = append(d.sigTokens, "p"+result[4])
+			g.sigTokens = append(g.sigTokens, "p"+result[4])
 		case swapKey:
-			d.sigTokens = append(d.sigTokens, "w"+result[4])
+			g.sigTokens = append(g.sigTokens, "w"+result[4])
 		}
 	}
 
 	return nil
 }
 
-// extractSignatureTokensAlt tries alternative patterns for signature extraction
-func (d *Decipherer) extractSignatureTokensAlt() error {
-	// Alternative signature function pattern
-	altPattern := regexp.MustCompile(`\b[cs]\s*&&\s*[adf]\.set\([^,]+\s*,\s*encodeURIComponent\(([a-zA-Z0-9$]+)\(`)
-	match := altPattern.FindStringSubmatch(d.playerCode)
-
-	if len(match) < 2 {
-		// If no signature function found, the URL may not need deciphering
-		return nil
-	}
-
-	return nil
-}
-
-// solveNChallenge solves the n-parameter challenge to bypass throttling
-func (d *Decipherer) solveNChallenge(n string) (string, error) {
-	// The n-parameter solving requires JavaScript execution
-	// This is a simplified version that may need enhancement
-
-	// For now, we'll return the original n value
-	// Full implementation would use a JS runtime like goja
-	return n, nil
-}
-
 // extractKey extracts a key from the object body using the given regex
 func extractKey(re *regexp.Regexp, body string) string {
 	match := re.FindStringSubmatch(body)
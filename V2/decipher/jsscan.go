@@ -0,0 +1,176 @@
+package decipher
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Keywords after which a '/' starts a regex literal rather than a division
+var regexKeywords = map[string]bool{
+	"return": true, "typeof": true, "case": true, "do": true, "else": true,
+	"in": true, "instanceof": true, "new": true, "delete": true, "void": true,
+	"throw": true, "of": true, "yield": true, "await": true,
+}
+
+// matchBrace returns the index just past the brace closing the one at open
+// Braces inside strings, template literals, regex literals and comments are ignored
+func matchBrace(code string, open int) (int, error) {
+	if open >= len(code) || code[open] != '{' {
+		return 0, fmt.Errorf("no opening brace at offset %d", open)
+	}
+
+	depth := 0
+	regexOK := true
+
+	for i := open; i < len(code); i++ {
+		ch := code[i]
+
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			continue
+
+		case ch == '"' || ch == '\'':
+			end, err := skipString(code, i, ch)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+			regexOK = false
+
+		case ch == '`':
+			end, err := skipTemplate(code, i)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+			regexOK = false
+
+		case ch == '/' && i+1 < len(code) && code[i+1] == '/':
+			for i < len(code) && code[i] != '\n' {
+				i++
+			}
+
+		case ch == '/' && i+1 < len(code) && code[i+1] == '*':
+			end := indexFrom(code, "*/", i+2)
+			if end < 0 {
+				return 0, fmt.Errorf("unterminated comment at offset %d", i)
+			}
+			i = end + 1
+
+		case ch == '/' && regexOK:
+			end, err := skipRegex(code, i)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+			regexOK = false
+
+		case ch == '{':
+			depth++
+			regexOK = true
+
+		case ch == '}':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+			regexOK = true
+
+		case isIdentChar(ch):
+			start := i
+			for i+1 < len(code) && isIdentChar(code[i+1]) {
+				i++
+			}
+			regexOK = regexKeywords[code[start:i+1]]
+
+		case ch == ')' || ch == ']':
+			regexOK = false
+
+		default:
+			// Operators and punctuation: a following '/' starts a regex
+			regexOK = true
+		}
+	}
+
+	return 0, fmt.Errorf("unmatched braces")
+}
+
+// skipString returns the index of the closing quote of the string starting at start
+func skipString(code string, start int, quote byte) (int, error) {
+	for i := start + 1; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			i++
+		case quote:
+			return i, nil
+		case '\n':
+			return 0, fmt.Errorf("unterminated string at offset %d", start)
+		}
+	}
+	return 0, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+// skipTemplate returns the index of the closing backtick of the template literal starting at start
+func skipTemplate(code string, start int) (int, error) {
+	for i := start + 1; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			i++
+		case '`':
+			return i, nil
+		case '$':
+			if i+1 < len(code) && code[i+1] == '{' {
+				end, err := matchBrace(code, i+1)
+				if err != nil {
+					return 0, err
+				}
+				i = end - 1
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated template literal at offset %d", start)
+}
+
+// skipRegex returns the index of the last flag character of the regex literal starting at start
+func skipRegex(code string, start int) (int, error) {
+	inClass := false
+	for i := start + 1; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			i++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '\n':
+			return 0, fmt.Errorf("unterminated regex at offset %d", start)
+		case '/':
+			if inClass {
+				continue
+			}
+			for i+1 < len(code) && isIdentChar(code[i+1]) {
+				i++
+			}
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated regex at offset %d", start)
+}
+
+// isIdentChar reports whether ch can appear in a JavaScript identifier or number
+func isIdentChar(ch byte) bool {
+	return ch == '_' || ch == '$' ||
+		ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch >= 0x80
+}
+
+// indexFrom returns the index of sub in code at or after from, or -1
+func indexFrom(code, sub string, from int) int {
+	if from > len(code) {
+		return -1
+	}
+	idx := strings.Index(code[from:], sub)
+	if idx < 0 {
+		return -1
+	}
+	return from + idx
+}
@@ -0,0 +1,85 @@
+package decipher
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// testPlayer loads the synthetic player in testdata
+func testPlayer(t *testing.T) string {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/player.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestMatchBrace(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string // the matched block; empty if an error is expected
+	}{
+		{"nested", `{a{b}{c{d}}}tail`, `{a{b}{c{d}}}`},
+		{"strings", `{a="}";b='{\'}'}tail`, `{a="}";b='{\'}'}`},
+		{"template", "{a=`}${ {b:1}.b }{`}tail", "{a=`}${ {b:1}.b }{`}"},
+		{"regex", `{a=/[}{]\/}/g.test(b)}tail`, `{a=/[}{]\/}/g.test(b)}`},
+		{"regex after keyword", `{return /}/.test(a)}tail`, `{return /}/.test(a)}`},
+		{"division", `{a=b/c;d=e/2}tail`, `{a=b/c;d=e/2}`},
+		{"division after call", `{a=f(b)/2/c}tail`, `{a=f(b)/2/c}`},
+		{"comments", "{a=1// }\n/* } */}tail", "{a=1// }\n/* } */}"},
+		{"unterminated string", `{a="}`, ""},
+		{"unterminated regex", "{a=/}\n}", ""},
+		{"unterminated comment", `{a=1/* }`, ""},
+		{"unmatched", `{a{b}`, ""},
+	}
+
+	for _, tt := range tests {
+		end, err := matchBrace(tt.code, 0)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: matched %q, want an error", tt.name, tt.code[:end])
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := tt.code[:end]; got != tt.want {
+			t.Errorf("%s: matched %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := matchBrace("a{}", 0); err == nil {
+		t.Error("matched from a position without a brace")
+	}
+}
+
+func TestExtractFunctionWithBraceMatching(t *testing.T) {
+	player := testPlayer(t)
+
+	body, err := extractFunctionWithBraceMatching(player, "Wm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(body, "function(a){") || !strings.HasSuffix(body, `b.reverse();return b.join("")}`) {
+		t.Errorf("extracted %q", body)
+	}
+
+	// The helper object's methods end in braces too
+	helper, err := extractObjectWithBraceMatching(player, "Xy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(helper, "{ab:") || !strings.HasSuffix(helper, "a[b%a.length]=c}}") {
+		t.Errorf("extracted %q", helper)
+	}
+
+	if _, err := extractFunctionWithBraceMatching(player, "missing"); err == nil {
+		t.Error("extracted a function that does not exist")
+	}
+}
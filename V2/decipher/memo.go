@@ -0,0 +1,116 @@
+package decipher
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMemoSize is the number of solved challenges a Decipherer remembers
+var DefaultMemoSize = 1024
+
+// memoKind separates signature and n results in the memo
+type memoKind uint8
+
+const (
+	memoSignature memoKind = iota
+	memoN
+)
+
+// memoKey identifies a solved challenge
+type memoKey struct {
+	playerID string
+	kind     memoKind
+	input    string
+}
+
+// memoEntry is a list element payload
+type memoEntry struct {
+	key    memoKey
+	output string
+}
+
+// MemoStats reports memo effectiveness
+type MemoStats struct {
+	Hits   uint64
+	Misses uint64
+	Size   int
+}
+
+// memo is a fixed-size LRU of solved challenges, safe for concurrent use
+type memo struct {
+	size    int
+	order   *list.List
+	entries map[memoKey]*list.Element
+	mu      sync.Mutex
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// newMemo creates an LRU memo holding up to size results
+func newMemo(size int) *memo {
+	return &memo{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[memoKey]*list.Element),
+	}
+}
+
+// get returns a remembered output and records a hit or miss
+func (m *memo) get(key memoKey) (string, bool) {
+	m.mu.Lock()
+	var output string
+	el, ok := m.entries[key]
+	if ok {
+		m.order.MoveToFront(el)
+		// Read under the lock; put may overwrite the entry
+		output = el.Value.(*memoEntry).output
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		m.misses.Add(1)
+		return "", false
+	}
+
+	m.hits.Add(1)
+	return output, true
+}
+
+// put remembers an output, evicting the least recently used entry when full
+func (m *memo) put(key memoKey, output string) {
+	if m.size <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.entries[key]; ok {
+		el.Value.(*memoEntry).output = output
+		m.order.MoveToFront(el)
+		return
+	}
+
+	m.entries[key] = m.order.PushFront(&memoEntry{key: key, output: output})
+
+	if m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoEntry).key)
+	}
+}
+
+// stats returns the current hit/miss counters and size
+func (m *memo) stats() MemoStats {
+	m.mu.Lock()
+	size := m.order.Len()
+	m.mu.Unlock()
+
+	return MemoStats{
+		Hits:   m.hits.Load(),
+		Misses: m.misses.Load(),
+		Size:   size,
+	}
+}
@@ -0,0 +1,59 @@
+package decipher
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestMemoLRU(t *testing.T) {
+	m := newMemo(2)
+	a := memoKey{playerID: "p", kind: memoN, input: "a"}
+	b := memoKey{playerID: "p", kind: memoN, input: "b"}
+	c := memoKey{playerID: "p", kind: memoN, input: "c"}
+
+	m.put(a, "A")
+	m.put(b, "B")
+	if out, ok := m.get(a); !ok || out != "A" {
+		t.Fatalf("get(a) = %q, %v", out, ok)
+	}
+
+	// b is now the least recently used
+	m.put(c, "C")
+	if _, ok := m.get(b); ok {
+		t.Error("b should have been evicted")
+	}
+	if out, ok := m.get(c); !ok || out != "C" {
+		t.Errorf("get(c) = %q, %v", out, ok)
+	}
+
+	// The same input under another kind is a different entry
+	if _, ok := m.get(memoKey{playerID: "p", kind: memoSignature, input: "a"}); ok {
+		t.Error("signature and n results share an entry")
+	}
+
+	stats := m.stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Size != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestMemoConcurrent(t *testing.T) {
+	m := newMemo(16)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := memoKey{playerID: "p", kind: memoN, input: fmt.Sprint(i % 4)}
+				m.put(key, fmt.Sprint(g, i))
+				if out, ok := m.get(key); ok && out == "" {
+					t.Error("got an empty output")
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
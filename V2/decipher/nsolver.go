@@ -1,77 +1,294 @@
 package decipher
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dop251/goja"
 )
 
-// NSolver handles n-parameter solving using a JavaScript runtime
+// ErrNChallengeFailed is returned when the n function reports a failure instead of a solved value
+var ErrNChallengeFailed = errors.New("n challenge failed")
+
+// NSolverMode selects how the n function is executed
+type NSolverMode string
+
+const (
+	// NSolverModeExtract runs only the n function body, extracted from the player
+	NSolverModeExtract NSolverMode = "extract"
+
+	// NSolverModePlayer loads the whole player into a sandboxed runtime and calls the n function in place
+	NSolverModePlayer NSolverMode = "player"
+)
+
+// nProbe is solved once at construction to check the n function actually works
+const nProbe = "Fh8qFwdtYO9Eyw7B"
+
+// N function call site patterns; group 1 is the name and group 2 an optional array index
+var nFuncCallRegexes = []*regexp.Regexp{
+	regexp.MustCompile(`\.get\("n"\)\)&&\(b=([a-zA-Z0-9$]+)(?:\[(\d+)\])?\([a-zA-Z0-9]\)`),
+	regexp.MustCompile(`\(\s*([a-zA-Z0-9$]+)\s*=\s*String\.fromCharCode\(\s*110\s*\)\s*,\s*([a-zA-Z0-9$]+)\s*=\s*[a-zA-Z0-9$]+\.get\(\s*[a-zA-Z0-9$]+\s*\)\s*\)\s*&&\s*\(\s*[a-zA-Z0-9$]+\s*=\s*([a-zA-Z0-9$]+)(?:\[(\d+)\])?\(`),
+	regexp.MustCompile(`[a-zA-Z0-9$]+\.D&&\([a-zA-Z0-9$]+=[a-zA-Z0-9$]+\.get\("n"\)\)&&\([a-zA-Z0-9$]+=([a-zA-Z0-9$]+)(?:\[(\d+)\])?\(`),
+}
+
+// Known failure markers returned by the n function instead of throwing
+var nFailureRegex = regexp.MustCompile(`^enhanced_except_|_w8_$`)
+
+// Defaults applied to new solvers
+var (
+	// DefaultNPoolSize is the maximum number of JS runtimes an NSolver keeps
+	DefaultNPoolSize = 4
+
+	// DefaultNTimeout bounds a single n function call
+	DefaultNTimeout = 5 * time.Second
+)
+
+// NSolver handles n-parameter solving using a pool of JavaScript runtimes
+// It is safe for concurrent use; each call borrows its own runtime
 type NSolver struct {
-	vm         *goja.Runtime
 	playerCode string
 	nFuncCode  string
+	nFuncName  string
+	mode       NSolverMode
+
+	// Maximum time a single n function call may run; zero disables the limit
+	Timeout time.Duration
+
+	// Compiled once and run in every pooled runtime
+	program *goja.Program
+	pool    *runtimePool
+}
+
+// nRuntime is a pooled JS runtime with the n function loaded
+type nRuntime struct {
+	vm *goja.Runtime
+	fn goja.Callable
 }
 
-// NewNSolver creates a new n-parameter solver
+// NewNSolver creates a new n-parameter solver that runs the extracted n function
 func NewNSolver(playerCode string) (*NSolver, error) {
+	return NewNSolverWithMode(playerCode, NSolverModeExtract)
+}
+
+// NewNSolverWithMode creates a new n-parameter solver using the given execution mode
+func NewNSolverWithMode(playerCode string, mode NSolverMode) (*NSolver, error) {
+	solver := &NSolver{
+		playerCode: playerCode,
+		mode:       mode,
+		Timeout:    DefaultNTimeout,
+	}
+
+	switch mode {
+	case NSolverModeExtract:
+		if err := solver.extractNFunction(); err != nil {
+			return nil, fmt.Errorf("failed to extract n function: %w", err)
+		}
+	case NSolverModePlayer:
+		if err := solver.preparePlayer(); err != nil {
+			return nil, fmt.Errorf("failed to load player for n function: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown n solver mode %q", mode)
+	}
+
+	if err := solver.initPool(); err != nil {
+		return nil, err
+	}
+
+	return solver, nil
+}
+
+// newNSolverFromCode creates a solver from an already extracted n function wrapper
+func newNSolverFromCode(playerCode, nFuncCode string) (*NSolver, error) {
 	solver := &NSolver{
-		vm:         goja.New(),
 		playerCode: playerCode,
+		nFuncCode:  nFuncCode,
+		mode:       NSolverModeExtract,
+		Timeout:    DefaultNTimeout,
 	}
 
-	if err := solver.extractNFunction(); err != nil {
-		return nil, fmt.Errorf("failed to extract n function: %w", err)
+	if err := solver.initPool(); err != nil {
+		return nil, err
 	}
 
 	return solver, nil
 }
 
+// initPool compiles the n function program and pre-initializes one runtime
+func (s *NSolver) initPool() error {
+	if s.mode == NSolverModeExtract {
+		if s.nFuncCode == "" {
+			return nil
+		}
+
+		program, err := goja.Compile("nfunction.js", s.nFuncCode, false)
+		if err != nil {
+			return fmt.Errorf("failed to compile n function: %w", err)
+		}
+		s.program = program
+	}
+
+	s.pool = newRuntimePool(DefaultNPoolSize, s.newRuntime)
+
+	// Fail early rather than on the first Solve
+	rt, err := s.pool.get()
+	if err != nil {
+		return err
+	}
+	s.pool.put(rt)
+
+	return nil
+}
+
+// newRuntime creates a runtime with the n function loaded
+func (s *NSolver) newRuntime() (*nRuntime, error) {
+	vm := goja.New()
+
+	name := "nFunction"
+	if s.mode == NSolverModePlayer {
+		if _, err := vm.RunProgram(browserStubProgram); err != nil {
+			return nil, fmt.Errorf("failed to install browser stub: %w", err)
+		}
+		name = nExportName
+	}
+
+	if _, err := vm.RunProgram(s.program); err != nil {
+		return nil, fmt.Errorf("failed to load n function: %w", err)
+	}
+
+	fn, ok := goja.AssertFunction(vm.Get(name))
+	if !ok {
+		return nil, fmt.Errorf("n function %s is not callable", s.nFuncName)
+	}
+
+	return &nRuntime{vm: vm, fn: fn}, nil
+}
+
+// Mode returns how this solver executes the n function
+func (s *NSolver) Mode() NSolverMode {
+	return s.mode
+}
+
 // Solve solves the n-parameter challenge
 func (s *NSolver) Solve(n string) (string, error) {
-	if s.nFuncCode == "" {
+	if s.pool == nil {
 		return n, nil
 	}
 
-	// Execute the n function in the JS runtime
-	script := fmt.Sprintf(`
-		%s
-		nFunction("%s");
-	`, s.nFuncCode, n)
+	rt, err := s.pool.get()
+	if err != nil {
+		return n, err
+	}
+
+	solved, err := s.solveWith(rt, n)
+	s.release(rt, err)
+
+	return solved, err
+}
+
+// solveWith runs the n function in rt, interrupting it if it exceeds the timeout
+func (s *NSolver) solveWith(rt *nRuntime, n string) (string, error) {
+	var timer *time.Timer
+	var fired chan struct{}
+	if s.Timeout > 0 {
+		fired = make(chan struct{})
+		timer = time.AfterFunc(s.Timeout, func() {
+			rt.vm.Interrupt("n function timed out")
+			close(fired)
+		})
+	}
+
+	result, err := rt.fn(goja.Undefined(), rt.vm.ToValue(n))
+
+	// If the timer already fired, wait for its interrupt so it cannot land after the clear below
+	if timer != nil && !timer.Stop() {
+		<-fired
+	}
+
+	// Cleared on every path so a runtime never goes back to the pool with an interrupt pending
+	rt.vm.ClearInterrupt()
 
-	result, err := s.vm.RunString(script)
 	if err != nil {
 		return n, fmt.Errorf("failed to execute n function: %w", err)
 	}
 
-	if result == nil || result == goja.Undefined() || result == goja.Null() {
+	if result == nil || goja.IsUndefined(result) || goja.IsNull(result) {
 		return n, nil
 	}
 
-	return result.String(), nil
+	solved := result.String()
+	if nFailureRegex.MatchString(solved) {
+		return n, fmt.Errorf("%w: %s", ErrNChallengeFailed, solved)
+	}
+
+	return solved, nil
+}
+
+// release returns rt to the pool, discarding runtimes that were interrupted
+func (s *NSolver) release(rt *nRuntime, err error) {
+	if isInterrupted(err) {
+		s.pool.discard()
+		return
+	}
+	s.pool.put(rt)
+}
+
+// works reports whether the solver transforms a probe value without failing
+func (s *NSolver) works() bool {
+	if s.pool == nil {
+		return false
+	}
+
+	solved, err := s.Solve(nProbe)
+	return err == nil && solved != nProbe
+}
+
+// findNFunction locates the n function by its call site, returning its name and optional array index
+func findNFunction(playerCode string) (string, string) {
+	for _, re := range nFuncCallRegexes {
+		match := re.FindStringSubmatch(playerCode)
+		if match == nil {
+			continue
+		}
+
+		// The last two groups are always name and index
+		return match[len(match)-2], match[len(match)-1]
+	}
+
+	return "", ""
 }
 
 // extractNFunction extracts the n-parameter transformation function from player code
 func (s *NSolver) extractNFunction() error {
-	// Pattern to find the n function name
-	patterns := []string{
-		// Modern pattern
-		`\.get\("n"\)\)&&\(b=([a-zA-Z0-9$]+)(?:\[(\d+)\])?\([a-zA-Z0-9]\)`,
-		// Alternative pattern
-		`\b([a-zA-Z0-9]+)\s*=\s*function\([a-zA-Z]\)\s*\{\s*var\s+[a-zA-Z]=\[[^\]]+\]`,
-		// Another variant
-		`(?:^|[^a-zA-Z0-9$])([a-zA-Z0-9$]+)\s*=\s*function\([a-z]\)\s*\{(?:[^}]+\}){2,}[^}]+return\s+[a-z]\.join\(""\)`,
+	funcName, idx := findNFunction(s.playerCode)
+
+	// The call site may reference an array holding the function
+	if funcName != "" && idx != "" {
+		resolved, err := resolveFunctionArray(s.playerCode, funcName, idx)
+		if err != nil {
+			return err
+		}
+		funcName = resolved
 	}
 
-	var funcName string
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
-		match := re.FindStringSubmatch(s.playerCode)
-		if len(match) >= 2 {
-			funcName = match[1]
-			break
+	if funcName == "" {
+		// Fall back to matching the function definition itself
+		patterns := []string{
+			`\b([a-zA-Z0-9]+)\s*=\s*function\([a-zA-Z]\)\s*\{\s*var\s+[a-zA-Z]=\[[^\]]+\]`,
+			`(?:^|[^a-zA-Z0-9$])([a-zA-Z0-9$]+)\s*=\s*function\([a-z]\)\s*\{(?:[^}]+\}){2,}[^}]+return\s+[a-z]\.join\(""\)`,
+		}
+
+		for _, pattern := range patterns {
+			re := regexp.MustCompile(pattern)
+			match := re.FindStringSubmatch(s.playerCode)
+			if len(match) >= 2 {
+				funcName = match[1]
+				break
+			}
 		}
 	}
 
@@ -86,6 +303,8 @@ func (s *NSolver) extractNFunction() error {
 		return err
 	}
 
+	s.nFuncName = funcName
+
 	// Create wrapper for execution
 	s.nFuncCode = fmt.Sprintf(`
 		var nFunction = %s;
@@ -94,36 +313,42 @@ func (s *NSolver) extractNFunction() error {
 	return nil
 }
 
-// extractFunctionBody extracts a complete function body from the player code
-func (s *NSolver) extractFunctionBody(funcName string) (string, error) {
-	// Escape special regex characters in function name
-	escapedName := regexp.QuoteMeta(funcName)
+// resolveFunctionArray resolves name[idx] where name is declared as var name=[a,b,...]
+func resolveFunctionArray(playerCode, name, idx string) (string, error) {
+	re := regexp.MustCompile(fmt.Sprintf(`var %s\s*=\s*\[([^\]]+)\]`, regexp.QuoteMeta(name)))
+	match := re.FindStringSubmatch(playerCode)
+	if len(match) < 2 {
+		return "", fmt.Errorf("function array %s not found", name)
+	}
 
-	// Try different patterns to find the function
-	patterns := []string{
-		// Function expression: var name = function(...
-		fmt.Sprintf(`(?:var\s+)?%s\s*=\s*(function\([^)]*\)\s*\{[^}]+(?:\{[^}]*\}[^}]*)*\})`, escapedName),
-		// Function declaration: function name(...
-		fmt.Sprintf(`(function\s+%s\s*\([^)]*\)\s*\{[^}]+(?:\{[^}]*\}[^}]*)*\})`, escapedName),
+	i, err := strconv.Atoi(idx)
+	if err != nil {
+		return "", err
 	}
 
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
-		match := re.FindStringSubmatch(s.playerCode)
-		if len(match) >= 2 {
-			return match[1], nil
-		}
+	items := strings.Split(match[1], ",")
+	if i < 0 || i >= len(items) {
+		return "", fmt.Errorf("function array %s has no index %d", name, i)
 	}
 
-	// Try to extract using brace matching
+	return strings.TrimSpace(items[i]), nil
+}
+
+// extractFunctionBody extracts a complete function body from the player code
+func (s *NSolver) extractFunctionBody(funcName string) (string, error) {
 	return s.extractFunctionWithBraceMatching(funcName)
 }
 
 // extractFunctionWithBraceMatching extracts function using brace matching
 func (s *NSolver) extractFunctionWithBraceMatching(funcName string) (string, error) {
+	return extractFunctionWithBraceMatching(s.playerCode, funcName)
+}
+
+// extractFunctionWithBraceMatching extracts a named function expression or declaration from player code
+func extractFunctionWithBraceMatching(playerCode, funcName string) (string, error) {
 	// Find function start
 	patterns := []string{
-		fmt.Sprintf(`%s\s*=\s*function`, regexp.QuoteMeta(funcName)),
+		fmt.Sprintf(`(?:^|[^a-zA-Z0-9_$.])%s\s*=\s*function`, regexp.QuoteMeta(funcName)),
 		fmt.Sprintf(`function\s+%s\s*\(`, regexp.QuoteMeta(funcName)),
 	}
 
@@ -132,11 +357,11 @@ func (s *NSolver) extractFunctionWithBraceMatching(funcName string) (string, err
 
 	for _, pattern := range patterns {
 		re := regexp.MustCompile(pattern)
-		loc := re.FindStringIndex(s.playerCode)
+		loc := re.FindStringIndex(playerCode)
 		if loc != nil {
 			startIdx = loc[0]
 			// Find the actual function keyword
-			funcIdx := strings.Index(s.playerCode[startIdx:], "function")
+			funcIdx := strings.Index(playerCode[startIdx:], "function")
 			if funcIdx >= 0 {
 				funcStartOffset = funcIdx
 			}
@@ -150,43 +375,69 @@ func (s *NSolver) extractFunctionWithBraceMatching(funcName string) (string, err
 
 	// Find opening brace
 	funcStart := startIdx + funcStartOffset
-	braceStart := strings.Index(s.playerCode[funcStart:], "{")
+	braceStart := strings.Index(playerCode[funcStart:], "{")
 	if braceStart < 0 {
 		return "", fmt.Errorf("opening brace not found for function %s", funcName)
 	}
 
-	braceStart += funcStart + 1
-	braceCount := 1
-	endIdx := braceStart
+	endIdx, err := matchBrace(playerCode, funcStart+braceStart)
+	if err != nil {
+		return "", fmt.Errorf("function %s: %w", funcName, err)
+	}
+
+	return playerCode[funcStart:endIdx], nil
+}
 
-	for braceCount > 0 && endIdx < len(s.playerCode) {
-		switch s.playerCode[endIdx] {
-		case '{':
-			braceCount++
-		case '}':
-			braceCount--
+// BulkSolve solves multiple n-parameter challenges, reusing one pooled runtime where possible
+// Challenges that fail to solve map to themselves
+func (s *NSolver) BulkSolve(challenges []string) map[string]string {
+	results := s.solveBatch(challenges)
+	for _, n := range challenges {
+		if _, ok := results[n]; !ok {
+			results[n] = n // Return original on error
 		}
-		endIdx++
 	}
+	return results
+}
 
-	if braceCount != 0 {
-		return "", fmt.Errorf("unmatched braces in function %s", funcName)
+// solveBatch solves each distinct challenge once, omitting those that fail
+func (s *NSolver) solveBatch(challenges []string) map[string]string {
+	results := make(map[string]string, len(challenges))
+	if s.pool == nil {
+		for _, n := range challenges {
+			results[n] = n
+		}
+		return results
 	}
 
-	return s.playerCode[funcStart:endIdx], nil
-}
+	var rt *nRuntime
+	for _, n := range challenges {
+		if _, ok := results[n]; ok {
+			continue
+		}
 
-// BulkSolve solves multiple n-parameter challenges
-func (s *NSolver) BulkSolve(challenges []string) map[string]string {
-	results := make(map[string]string)
+		if rt == nil {
+			var err error
+			if rt, err = s.pool.get(); err != nil {
+				continue
+			}
+		}
 
-	for _, n := range challenges {
-		solved, err := s.Solve(n)
-		if err != nil {
-			results[n] = n // Return original on error
-		} else {
+		solved, err := s.solveWith(rt, n)
+		if err == nil {
 			results[n] = solved
+			continue
 		}
+
+		// An interrupted runtime is no longer trustworthy; the next challenge gets a fresh one
+		if isInterrupted(err) {
+			s.pool.discard()
+			rt = nil
+		}
+	}
+
+	if rt != nil {
+		s.pool.put(rt)
 	}
 
 	return results
@@ -0,0 +1,95 @@
+package decipher
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFindNFunction(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      string
+		wantName  string
+		wantIndex string
+	}{
+		{"array", `(c=a.get("n"))&&(b=Nf[0](c))`, "Nf", "0"},
+		{"direct", `(c=a.get("n"))&&(b=Wm(c))`, "Wm", ""},
+		{"fromCharCode", `(b=String.fromCharCode(110),c=a.get(b))&&(c=Nf[1](c))`, "Nf", "1"},
+		{"D flag", `a.D&&(b=a.get("n"))&&(b=Wm(b))`, "Wm", ""},
+		{"none", `var a=1;`, "", ""},
+	}
+
+	for _, tt := range tests {
+		name, index := findNFunction(tt.code)
+		if name != tt.wantName || index != tt.wantIndex {
+			t.Errorf("%s: got %q[%q], want %q[%q]", tt.name, name, index, tt.wantName, tt.wantIndex)
+		}
+	}
+}
+
+func TestNSolver(t *testing.T) {
+	player := testPlayer(t)
+
+	for _, mode := range []NSolverMode{NSolverModeExtract, NSolverModePlayer} {
+		s, err := NewNSolverWithMode(player, mode)
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if !s.works() {
+			t.Errorf("%s: probe not transformed", mode)
+		}
+
+		tests := []struct {
+			n    string
+			want string
+			err  error
+		}{
+			{"abcdef", "fedcba", nil},
+			// _w8_ inside a solved value is not a failure marker
+			{"ab_8w_cd", "dc_w8_ba", nil},
+			{"fail123", "fail123", ErrNChallengeFailed},
+			{"sentinel", "sentinel", ErrNChallengeFailed},
+		}
+
+		for _, tt := range tests {
+			got, err := s.Solve(tt.n)
+			if !errors.Is(err, tt.err) || got != tt.want {
+				t.Errorf("%s: Solve(%q) = %q, %v; want %q, %v", mode, tt.n, got, err, tt.want, tt.err)
+			}
+		}
+
+		results := s.BulkSolve([]string{"abc", "fail1"})
+		if results["abc"] != "cba" || results["fail1"] != "fail1" {
+			t.Errorf("%s: BulkSolve = %v", mode, results)
+		}
+	}
+}
+
+func TestNSolverExtractsByName(t *testing.T) {
+	s, err := NewNSolver(testPlayer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.nFuncName != "Wm" {
+		t.Errorf("n function %q, want the array element Wm", s.nFuncName)
+	}
+}
+
+func TestNSolverWithoutNFunction(t *testing.T) {
+	s, err := NewNSolver(`var a=1;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Players without an n function leave challenges unchanged
+	if got, err := s.Solve("abc"); err != nil || got != "abc" {
+		t.Errorf("Solve = %q, %v", got, err)
+	}
+	if s.works() {
+		t.Error("solver without an n function reported working")
+	}
+
+	if _, err := NewNSolverWithMode(`var a=1;`, NSolverModePlayer); err == nil {
+		t.Error("player mode accepted a player without an n call site")
+	}
+}
@@ -0,0 +1,55 @@
+package decipher
+
+// runtimePool hands out JS runtimes, creating them lazily up to a fixed size
+type runtimePool struct {
+	create func() (*nRuntime, error)
+
+	// Idle runtimes ready for use
+	idle chan *nRuntime
+	// One token per runtime that exists or is being created
+	slots chan struct{}
+}
+
+// newRuntimePool creates a pool holding at most size runtimes
+func newRuntimePool(size int, create func() (*nRuntime, error)) *runtimePool {
+	if size < 1 {
+		size = 1
+	}
+
+	return &runtimePool{
+		create: create,
+		idle:   make(chan *nRuntime, size),
+		slots:  make(chan struct{}, size),
+	}
+}
+
+// get returns an idle runtime, creates one if the pool has room, or waits for one to be returned
+func (p *runtimePool) get() (*nRuntime, error) {
+	select {
+	case rt := <-p.idle:
+		return rt, nil
+	default:
+	}
+
+	select {
+	case rt := <-p.idle:
+		return rt, nil
+	case p.slots <- struct{}{}:
+		rt, err := p.create()
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		return rt, nil
+	}
+}
+
+// put returns a runtime to the pool
+func (p *runtimePool) put(rt *nRuntime) {
+	p.idle <- rt
+}
+
+// discard drops a borrowed runtime, freeing its slot for a fresh one
+func (p *runtimePool) discard() {
+	<-p.slots
+}
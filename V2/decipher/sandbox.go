@@ -0,0 +1,107 @@
+package decipher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// nExportName is the global the sandboxed player assigns its n function to
+const nExportName = "__overtureNFunction"
+
+// browserStub provides the minimal browser globals the player touches while initializing
+// Everything is inert: timers never fire, network objects never send, DOM queries find nothing
+const browserStub = `
+var window = globalThis, self = globalThis, top = globalThis, parent = globalThis;
+var location = {
+	href: "https://www.youtube.com/watch?v=dQw4w9WgXcQ", origin: "https://www.youtube.com",
+	protocol: "https:", host: "www.youtube.com", hostname: "www.youtube.com",
+	pathname: "/watch", search: "?v=dQw4w9WgXcQ", hash: "", port: "",
+	replace: function() {}, assign: function() {}, reload: function() {}
+};
+var navigator = {
+	userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	platform: "Win32", language: "en-US", languages: ["en-US", "en"], vendor: "Google Inc.",
+	cookieEnabled: true, onLine: true, hardwareConcurrency: 4, maxTouchPoints: 0
+};
+var __overtureElement = function() {
+	return {
+		style: {}, dataset: {}, childNodes: [], children: [], classList: { add: function() {}, remove: function() {}, contains: function() { return false; } },
+		setAttribute: function() {}, getAttribute: function() { return null; }, removeAttribute: function() {},
+		appendChild: function(c) { return c; }, removeChild: function(c) { return c; }, insertBefore: function(c) { return c; },
+		addEventListener: function() {}, removeEventListener: function() {},
+		getElementsByTagName: function() { return []; }, querySelector: function() { return null; }, querySelectorAll: function() { return []; },
+		getContext: function() { return null; }, canPlayType: function() { return ""; }
+	};
+};
+var document = __overtureElement();
+document.location = location;
+document.cookie = "";
+document.referrer = "";
+document.readyState = "complete";
+document.documentElement = __overtureElement();
+document.head = __overtureElement();
+document.body = __overtureElement();
+document.createElement = __overtureElement;
+document.createElementNS = __overtureElement;
+document.createTextNode = __overtureElement;
+document.getElementById = function() { return null; };
+var __overtureNoop = function() { return 0; };
+var setTimeout = __overtureNoop, clearTimeout = __overtureNoop, setInterval = __overtureNoop, clearInterval = __overtureNoop;
+var requestAnimationFrame = __overtureNoop, cancelAnimationFrame = __overtureNoop;
+var XMLHttpRequest = function() { this.open = function() {}; this.send = function() {}; this.setRequestHeader = function() {}; };
+var addEventListener = function() {}, removeEventListener = function() {};
+var localStorage = { getItem: function() { return null; }, setItem: function() {}, removeItem: function() {} };
+var sessionStorage = localStorage;
+var performance = { now: function() { return Date.now(); } };
+var _yt_player = {};
+`
+
+// browserStubProgram is compiled once and shared by every sandboxed runtime
+var browserStubProgram = goja.MustCompile("stub.js", browserStub, false)
+
+// preparePlayer locates the n function and compiles the whole player with an export of it
+func (s *NSolver) preparePlayer() error {
+	name, idx := findNFunction(s.playerCode)
+	if name == "" {
+		return fmt.Errorf("n function call site not found")
+	}
+
+	expr := name
+	if idx != "" {
+		expr = fmt.Sprintf("%s[%s]", name, idx)
+	}
+	s.nFuncName = expr
+
+	program, err := goja.Compile("base.js", exportFromPlayer(s.playerCode, playerExport{global: nExportName, expr: expr}), false)
+	if err != nil {
+		return fmt.Errorf("failed to compile player: %w", err)
+	}
+	s.program = program
+
+	return nil
+}
+
+// playerExport assigns a player-internal expression to a global
+type playerExport struct {
+	global string
+	expr   string
+}
+
+// exportFromPlayer injects global assignments at the end of the player's wrapper function
+// The player body runs inside (function(g){...})(_yt_player), so its functions are not otherwise reachable
+func exportFromPlayer(playerCode string, exports ...playerExport) string {
+	var b strings.Builder
+	for _, e := range exports {
+		fmt.Fprintf(&b, ";try{globalThis.%s=%s}catch(e){}\n", e.global, e.expr)
+	}
+	export := b.String()
+
+	if idx := strings.LastIndex(playerCode, "})(_yt_player)"); idx >= 0 {
+		return playerCode[:idx] + export + playerCode[idx:]
+	}
+
+	// Not wrapped; the functions are globals already
+	return playerCode + "\n" + export
+}
@@ -0,0 +1,160 @@
+package decipher
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// ErrSignatureNotFound is returned when no strategy can decipher signatures for a player
+var ErrSignatureNotFound = errors.New("signature function not found")
+
+// SignatureStrategy identifies how a Decipherer transforms signatures
+type SignatureStrategy string
+
+const (
+	// SignatureStrategyTokens applies reverse/slice/splice/swap tokens extracted by regex
+	SignatureStrategyTokens SignatureStrategy = "tokens"
+
+	// SignatureStrategyJS runs the player's signature function in the JS runtime
+	SignatureStrategyJS SignatureStrategy = "js"
+
+	// SignatureStrategyExternal delegates signatures to a ChallengeSolver other than the built-in one
+	SignatureStrategyExternal SignatureStrategy = "external"
+)
+
+// sigProbe is deciphered once at construction to check the extracted function actually runs
+const sigProbe = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_=.,"
+
+var (
+	// Signature function name patterns, most specific first
+	sigFuncNameRegexes = []*regexp.Regexp{
+		regexp.MustCompile(`\b[cs]\s*&&\s*[adf]\.set\([^,]+\s*,\s*encodeURIComponent\s*\(\s*([a-zA-Z0-9$]+)\(`),
+		regexp.MustCompile(`\b[a-zA-Z0-9]+\s*&&\s*[a-zA-Z0-9]+\.set\([^,]+\s*,\s*encodeURIComponent\s*\(\s*([a-zA-Z0-9$]+)\(`),
+		regexp.MustCompile(`\bm=([a-zA-Z0-9$]{2,})\(decodeURIComponent\(h\.s\)\)`),
+		regexp.MustCompile(`(?:^|[^a-zA-Z0-9$])([a-zA-Z0-9$]{2,})\s*=\s*function\(\s*a\s*\)\s*\{\s*a\s*=\s*a\.split\(\s*(?:""|'')\s*\)`),
+		regexp.MustCompile(`function\s+([a-zA-Z0-9$]{2,})\s*\(\s*a\s*\)\s*\{\s*a\s*=\s*a\.split\(\s*(?:""|'')\s*\)`),
+	}
+
+	// Helper object calls inside the signature function, e.g. Xy.ab(a,3) or Xy["ab"](a,3)
+	sigHelperRegex = regexp.MustCompile(`([a-zA-Z_$][a-zA-Z0-9_$]*)(?:\.[a-zA-Z_$][a-zA-Z0-9_$]*|\[[^\]]+\])\(a,\d+\)`)
+)
+
+// SigSolver deciphers signatures by running the player's own signature function
+// It is not safe for concurrent use; Decipherer serializes calls
+type SigSolver struct {
+	vm          *goja.Runtime
+	fn          goja.Callable
+	sigFuncCode string
+}
+
+// NewSigSolver extracts the signature function and its helper object from player code
+func NewSigSolver(playerCode string) (*SigSolver, error) {
+	code, err := extractSigFunction(playerCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSigSolverFromCode(code)
+}
+
+// newSigSolverFromCode compiles an already extracted signature script
+func newSigSolverFromCode(sigFuncCode string) (*SigSolver, error) {
+	s := &SigSolver{
+		vm:          goja.New(),
+		sigFuncCode: sigFuncCode,
+	}
+
+	if _, err := s.vm.RunString(sigFuncCode); err != nil {
+		return nil, fmt.Errorf("failed to load signature function: %w", err)
+	}
+
+	fn, ok := goja.AssertFunction(s.vm.Get("sigFunction"))
+	if !ok {
+		return nil, fmt.Errorf("signature function is not callable")
+	}
+	s.fn = fn
+
+	// Make sure the function runs and actually transforms its input
+	out, err := s.Solve(sigProbe)
+	if err != nil {
+		return nil, err
+	}
+	if out == sigProbe {
+		return nil, fmt.Errorf("signature function returned its input unchanged")
+	}
+
+	return s, nil
+}
+
+// Solve deciphers a signature
+func (s *SigSolver) Solve(sig string) (string, error) {
+	result, err := s.fn(goja.Undefined(), s.vm.ToValue(sig))
+	if err != nil {
+		return "", fmt.Errorf("failed to execute signature function: %w", err)
+	}
+
+	if result == nil || goja.IsUndefined(result) || goja.IsNull(result) {
+		return "", fmt.Errorf("signature function returned no value")
+	}
+
+	return result.String(), nil
+}
+
+// extractSigFunction builds a standalone script defining sigFunction and the helper object it uses
+func extractSigFunction(playerCode string) (string, error) {
+	funcName := findSigFunctionName(playerCode)
+	if funcName == "" {
+		return "", fmt.Errorf("no name pattern matched")
+	}
+
+	funcCode, err := extractFunctionWithBraceMatching(playerCode, funcName)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+
+	// The helper object holds the reverse/splice/swap primitives
+	if match := sigHelperRegex.FindStringSubmatch(funcCode); len(match) >= 2 {
+		helper, err := extractObjectWithBraceMatching(playerCode, match[1])
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "var %s=%s;\n", match[1], helper)
+	}
+
+	fmt.Fprintf(&b, "var sigFunction=%s;\n", funcCode)
+
+	return b.String(), nil
+}
+
+// findSigFunctionName locates the signature function by its call site or definition
+func findSigFunctionName(playerCode string) string {
+	for _, re := range sigFuncNameRegexes {
+		if match := re.FindStringSubmatch(playerCode); len(match) >= 2 {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// extractObjectWithBraceMatching extracts an object literal assigned to name
+func extractObjectWithBraceMatching(playerCode, name string) (string, error) {
+	re := regexp.MustCompile(fmt.Sprintf(`(?:^|[^a-zA-Z0-9_$.])%s\s*=\s*\{`, regexp.QuoteMeta(name)))
+	loc := re.FindStringIndex(playerCode)
+	if loc == nil {
+		return "", fmt.Errorf("object %s not found", name)
+	}
+
+	start := loc[1] - 1
+	end, err := matchBrace(playerCode, start)
+	if err != nil {
+		return "", fmt.Errorf("object %s: %w", name, err)
+	}
+
+	return playerCode[start:end], nil
+}
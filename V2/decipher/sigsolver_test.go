@@ -0,0 +1,64 @@
+package decipher
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindSigFunctionName(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"call site", `c&&d.set(e,encodeURIComponent(Qz(f)))`, "Qz"},
+		{"long names", `ab&&cd.set(e,encodeURIComponent(Qz$1(f)))`, "Qz$1"},
+		{"decodeURIComponent", `m=Qz(decodeURIComponent(h.s))`, "Qz"},
+		{"definition", `;Qz=function(a){a=a.split("");return a.join("")}`, "Qz"},
+		{"declaration", `function Qz(a){a=a.split('');return a.join("")}`, "Qz"},
+		{"none", `var a=1;`, ""},
+	}
+
+	for _, tt := range tests {
+		if got := findSigFunctionName(tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSigSolver(t *testing.T) {
+	player := testPlayer(t)
+
+	code, err := extractSigFunction(player)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(code, "var Xy={ab:") || !strings.Contains(code, "var sigFunction=function(a){") {
+		t.Errorf("extracted script %q", code)
+	}
+
+	s, err := NewSigSolver(player)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Swap the first and fourth characters, reverse, then drop the first two
+	got, err := s.Solve("ABCDEFGHIJ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "HGFEACBD" {
+		t.Errorf("Solve = %q, want %q", got, "HGFEACBD")
+	}
+}
+
+func TestSigSolverRejectsIdentity(t *testing.T) {
+	player := `c&&d.set(e,encodeURIComponent(Qz(f)));var Qz=function(a){a=a.split("");return a.join("")};`
+
+	if _, err := NewSigSolver(player); err == nil {
+		t.Error("accepted a signature function that returns its input")
+	}
+	if _, err := NewSigSolver(`var a=1;`); err == nil {
+		t.Error("created a solver for a player without a signature function")
+	}
+}
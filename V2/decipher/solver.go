@@ -0,0 +1,176 @@
+package decipher
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// ChallengeSolver solves signature and n challenges for a player
+// Results map each input to its output; inputs that could not be solved are omitted
+// An error is returned only when the batch as a whole failed
+type ChallengeSolver interface {
+	SolveSignatures(playerID string, sigs []string) (map[string]string, error)
+	SolveN(playerID string, challenges []string) (map[string]string, error)
+}
+
+// PlayerLoader is implemented by solvers that need the player code before solving for it
+type PlayerLoader interface {
+	LoadPlayer(playerID, playerCode string) error
+}
+
+// GojaSolver is the built-in ChallengeSolver
+// Signatures use regex-extracted tokens or the player's signature function in goja;
+// n challenges use a pool of goja runtimes. It serves the single player it was built from
+type GojaSolver struct {
+	playerID   string
+	playerCode string
+
+	sigTokens   []string
+	sigSolver   *SigSolver
+	sigStrategy SignatureStrategy
+	nSolver     *NSolver
+
+	// Serializes the signature solver's single JS runtime
+	mu sync.Mutex
+}
+
+// NewGojaSolver extracts the signature and n functions from player code
+func NewGojaSolver(playerID, playerCode string) (*GojaSolver, error) {
+	g := &GojaSolver{
+		playerID:   playerID,
+		playerCode: playerCode,
+	}
+
+	if err := g.initSignature(); err != nil {
+		return nil, fmt.Errorf("failed to extract signature function: %w", err)
+	}
+
+	// Initialize n-solver; optional, since some videos don't need it
+	g.nSolver = newNSolverWithFallback(playerCode)
+
+	return g, nil
+}
+
+// newGojaSolverFromArtifacts rebuilds a GojaSolver from cached artifacts without regex extraction
+func newGojaSolverFromArtifacts(a *PlayerArtifacts) (*GojaSolver, error) {
+	g := &GojaSolver{
+		playerID:    a.PlayerID,
+		playerCode:  a.PlayerCode,
+		sigTokens:   a.SignatureTokens,
+		sigStrategy: a.SignatureStrategy,
+	}
+
+	switch a.SignatureStrategy {
+	case SignatureStrategyTokens:
+	case SignatureStrategyJS:
+		solver, err := newSigSolverFromCode(a.SignatureFunctionCode)
+		if err != nil {
+			return nil, err
+		}
+		g.sigSolver = solver
+	default:
+		return nil, fmt.Errorf("unknown signature strategy %q", a.SignatureStrategy)
+	}
+
+	switch a.NSolverMode {
+	case NSolverModeExtract:
+		if a.NFunctionCode != "" {
+			nSolver, err := newNSolverFromCode(a.PlayerCode, a.NFunctionCode)
+			if err != nil {
+				return nil, err
+			}
+			g.nSolver = nSolver
+		}
+	case NSolverModePlayer:
+		nSolver, err := NewNSolverWithMode(a.PlayerCode, NSolverModePlayer)
+		if err != nil {
+			return nil, err
+		}
+		g.nSolver = nSolver
+	}
+
+	return g, nil
+}
+
+// SolveSignatures deciphers a batch of signatures
+func (g *GojaSolver) SolveSignatures(playerID string, sigs []string) (map[string]string, error) {
+	if err := g.checkPlayer(playerID); err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]string, len(sigs))
+	switch g.sigStrategy {
+	case SignatureStrategyTokens:
+		for _, sig := range sigs {
+			results[sig] = g.decipherSignature(sig)
+		}
+	case SignatureStrategyJS:
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		for _, sig := range sigs {
+			out, err := g.sigSolver.Solve(sig)
+			if err != nil {
+				return nil, err
+			}
+			results[sig] = out
+		}
+	default:
+		return nil, ErrSignatureNotFound
+	}
+
+	return results, nil
+}
+
+// SolveN solves a batch of n challenges
+// Players without an n function return every challenge unchanged
+func (g *GojaSolver) SolveN(playerID string, challenges []string) (map[string]string, error) {
+	if err := g.checkPlayer(playerID); err != nil {
+		return nil, err
+	}
+
+	if g.nSolver == nil {
+		results := make(map[string]string, len(challenges))
+		for _, n := range challenges {
+			results[n] = n
+		}
+		return results, nil
+	}
+
+	return g.nSolver.solveBatch(challenges), nil
+}
+
+// checkPlayer rejects requests for a player other than the one this solver was built from
+func (g *GojaSolver) checkPlayer(playerID string) error {
+	if playerID != "" && g.playerID != "" && playerID != g.playerID {
+		return fmt.Errorf("solver holds player %s, not %s", g.playerID, playerID)
+	}
+	return nil
+}
+
+// newNSolverWithFallback tries the extracted n function first and falls back to running the whole player
+func newNSolverWithFallback(playerCode string) *NSolver {
+	nSolver, err := NewNSolver(playerCode)
+	if err == nil && nSolver.works() {
+		return nSolver
+	}
+
+	playerSolver, err := NewNSolverWithMode(playerCode, NSolverModePlayer)
+	if err == nil && playerSolver.works() {
+		return playerSolver
+	}
+
+	// Keep the extracted solver if it loaded, even though the probe was inconclusive
+	if nSolver != nil {
+		return nSolver
+	}
+	return nil
+}
+
+// isInterrupted reports whether err came from a runtime interrupted by a timeout
+func isInterrupted(err error) bool {
+	var interrupted *goja.InterruptedError
+	return errors.As(err, &interrupted)
+}
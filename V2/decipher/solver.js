@@ -0,0 +1,68 @@
+// Reference ChallengeSolver for Node-compatible runtimes (node, bun)
+// Speaks the SubprocessSolver protocol: one JSON request per stdin line, one JSON response per stdout line
+"use strict";
+
+const readline = require("readline");
+const vm = require("vm");
+
+const players = new Map();
+const failure = /^enhanced_except_|_w8_$/;
+
+function reply(msg) {
+	process.stdout.write(JSON.stringify(msg) + "\n");
+}
+
+function solve(req) {
+	const ctx = players.get(req.player_id);
+	if (!ctx) {
+		throw new Error("player not loaded: " + req.player_id);
+	}
+
+	const fn = req.type === "sig" ? ctx.__overtureSigFunction : ctx.__overtureNFunction;
+	if (typeof fn !== "function") {
+		throw new Error(req.type + " function not exported by player " + req.player_id);
+	}
+
+	const results = {};
+	for (const input of req.challenges || []) {
+		try {
+			const out = String(fn(input));
+			if (!failure.test(out)) {
+				results[input] = out;
+			}
+		} catch (e) {
+			// Omitted from results; the caller treats it as unsolved
+		}
+	}
+	return results;
+}
+
+readline.createInterface({ input: process.stdin, terminal: false }).on("line", (line) => {
+	let req;
+	try {
+		req = JSON.parse(line);
+	} catch (e) {
+		return reply({ id: 0, error: "invalid request: " + e.message });
+	}
+
+	try {
+		switch (req.type) {
+		case "load": {
+			const ctx = vm.createContext({});
+			vm.runInContext(req.player, ctx);
+			players.set(req.player_id, ctx);
+			return reply({ id: req.id, results: {} });
+		}
+		case "unload":
+			players.delete(req.player_id);
+			return reply({ id: req.id, results: {} });
+		case "sig":
+		case "n":
+			return reply({ id: req.id, results: solve(req) });
+		default:
+			throw new Error("unknown request type: " + req.type);
+		}
+	} catch (e) {
+		reply({ id: req.id, error: String((e && e.message) || e) });
+	}
+});
@@ -0,0 +1,358 @@
+package decipher
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// SolverScript is a reference solver for Node-compatible runtimes
+// Run it with NewSubprocessSolver("node", "-e", SolverScript) or ("bun", "-e", SolverScript)
+//
+//go:embed solver.js
+var SolverScript string
+
+// sigExportName is the global the instrumented player assigns its signature function to
+const sigExportName = "__overtureSigFunction"
+
+// DefaultSubprocessTimeout bounds a single request to an external solver
+var DefaultSubprocessTimeout = 30 * time.Second
+
+// maxSolverPlayers is how many players a SubprocessSolver keeps: the current one and the one
+// it replaced, which requests started before a player rotation may still be using
+const maxSolverPlayers = 2
+
+// SubprocessSolver is a ChallengeSolver that delegates to an external JS runtime
+//
+// The protocol is newline-delimited JSON over the process's stdin and stdout.
+// Each request is {"id", "type", "player_id", "player", "challenges"} and each
+// response is {"id", "results", "error"}. Types are:
+//   - "load": evaluate "player" for "player_id"; the player is prefixed with a browser
+//     stub and assigns its signature and n functions to the globals
+//     __overtureSigFunction and __overtureNFunction
+//   - "sig", "n": call the matching function on each of "challenges" and return
+//     {input: output}, omitting inputs that failed
+//   - "unload": drop the player for "player_id"; errors are ignored
+//
+// Requests are sent one at a time. Only the most recently used players are kept;
+// older ones are unloaded. A process that times out or exits is restarted on the
+// next request and only the player that request needs is sent to it again
+type SubprocessSolver struct {
+	Command string
+	Args    []string
+
+	// Maximum time to wait for a response before the process is killed
+	Timeout time.Duration
+
+	mu   sync.Mutex
+	proc *solverProcess
+	// Instrumented players, most recently used last
+	players []solverPlayer
+	nextID  int
+}
+
+// solverPlayer is an instrumented player kept for reloading
+type solverPlayer struct {
+	id   string
+	code string
+}
+
+// subprocessRequest is a single protocol request
+type subprocessRequest struct {
+	ID         int      `json:"id"`
+	Type       string   `json:"type"`
+	PlayerID   string   `json:"player_id"`
+	Player     string   `json:"player,omitempty"`
+	Challenges []string `json:"challenges,omitempty"`
+}
+
+// subprocessResponse is a single protocol response
+type subprocessResponse struct {
+	ID      int               `json:"id"`
+	Results map[string]string `json:"results"`
+	Error   string            `json:"error"`
+}
+
+// solverProcess is a running solver and its pipes
+type solverProcess struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	responses chan subprocessResponse
+	done      chan struct{}
+	quit      chan struct{}
+	err       error
+
+	// Players loaded into this process
+	loaded map[string]bool
+}
+
+// NewSubprocessSolver creates a solver that runs command with args
+// The process is started on first use
+func NewSubprocessSolver(command string, args ...string) *SubprocessSolver {
+	return &SubprocessSolver{
+		Command: command,
+		Args:    args,
+		Timeout: DefaultSubprocessTimeout,
+	}
+}
+
+// NewNodeSolver creates a solver running SolverScript under node
+func NewNodeSolver() *SubprocessSolver {
+	return NewSubprocessSolver("node", "-e", SolverScript)
+}
+
+// LoadPlayer instruments the player code and sends it to the external runtime
+func (s *SubprocessSolver) LoadPlayer(playerID, playerCode string) error {
+	player := instrumentPlayer(playerCode)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(playerID, player); err != nil {
+		return err
+	}
+
+	// Remembered so a restarted process can be reloaded
+	s.remember(playerID, player)
+	return nil
+}
+
+// SolveSignatures deciphers a batch of signatures in the external runtime
+func (s *SubprocessSolver) SolveSignatures(playerID string, sigs []string) (map[string]string, error) {
+	return s.solve("sig", playerID, sigs)
+}
+
+// SolveN solves a batch of n challenges in the external runtime
+func (s *SubprocessSolver) SolveN(playerID string, challenges []string) (map[string]string, error) {
+	return s.solve("n", playerID, challenges)
+}
+
+// Close stops the external process
+func (s *SubprocessSolver) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stop()
+	return nil
+}
+
+// solve sends a challenge batch of the given type
+func (s *SubprocessSolver) solve(kind, playerID string, inputs []string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	player, ok := s.player(playerID)
+	if !ok {
+		return nil, fmt.Errorf("player %s not loaded", playerID)
+	}
+
+	if s.proc == nil || !s.proc.loaded[playerID] {
+		if err := s.load(playerID, player); err != nil {
+			return nil, fmt.Errorf("failed to reload player %s: %w", playerID, err)
+		}
+	}
+	s.remember(playerID, player)
+
+	resp, err := s.roundTrip(subprocessRequest{Type: kind, PlayerID: playerID, Challenges: inputs})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Results, nil
+}
+
+// load sends a player to the process, starting it if needed; s.mu must be held
+func (s *SubprocessSolver) load(playerID, player string) error {
+	if _, err := s.roundTrip(subprocessRequest{Type: "load", PlayerID: playerID, Player: player}); err != nil {
+		return err
+	}
+
+	s.proc.loaded[playerID] = true
+	return nil
+}
+
+// player returns a remembered player's instrumented code; s.mu must be held
+func (s *SubprocessSolver) player(playerID string) (string, bool) {
+	for _, p := range s.players {
+		if p.id == playerID {
+			return p.code, true
+		}
+	}
+	return "", false
+}
+
+// remember marks a player as the most recently used, unloading the oldest beyond maxSolverPlayers; s.mu must be held
+func (s *SubprocessSolver) remember(playerID, player string) {
+	for i, p := range s.players {
+		if p.id == playerID {
+			s.players = append(s.players[:i], s.players[i+1:]...)
+			break
+		}
+	}
+	s.players = append(s.players, solverPlayer{id: playerID, code: player})
+
+	for len(s.players) > maxSolverPlayers {
+		evicted := s.players[0].id
+		s.players = append(s.players[:0], s.players[1:]...)
+
+		if s.proc != nil && s.proc.loaded[evicted] {
+			delete(s.proc.loaded, evicted)
+			// Best effort: a solver that cannot unload just keeps the player until it restarts
+			s.send(subprocessRequest{Type: "unload", PlayerID: evicted})
+		}
+	}
+}
+
+// roundTrip sends a request and waits for its response, starting the process if needed; s.mu must be held
+func (s *SubprocessSolver) roundTrip(req subprocessRequest) (*subprocessResponse, error) {
+	if s.proc == nil {
+		if err := s.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.send(req)
+}
+
+// send writes a request to the running process and waits for the matching response; s.mu must be held
+func (s *SubprocessSolver) send(req subprocessRequest) (*subprocessResponse, error) {
+	s.nextID++
+	req.ID = s.nextID
+
+	line, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	proc := s.proc
+	if _, err := proc.stdin.Write(append(line, '\n')); err != nil {
+		s.stop()
+		return nil, fmt.Errorf("failed to write to solver: %w", err)
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSubprocessTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case resp := <-proc.responses:
+			if resp.ID != req.ID {
+				// A late response to a request that already timed out
+				continue
+			}
+			if resp.Error != "" {
+				return nil, fmt.Errorf("solver error: %s", resp.Error)
+			}
+			return &resp, nil
+
+		case <-proc.done:
+			s.stop()
+			return nil, fmt.Errorf("solver exited: %w", proc.err)
+
+		case <-timer.C:
+			s.stop()
+			return nil, fmt.Errorf("solver did not respond within %s", timeout)
+		}
+	}
+}
+
+// start launches the process; players are loaded into it as requests need them; s.mu must be held
+func (s *SubprocessSolver) start() error {
+	cmd := exec.Command(s.Command, s.Args...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start solver: %w", err)
+	}
+
+	proc := &solverProcess{
+		cmd:       cmd,
+		stdin:     stdin,
+		responses: make(chan subprocessResponse, 1),
+		done:      make(chan struct{}),
+		quit:      make(chan struct{}),
+		loaded:    make(map[string]bool),
+	}
+	go proc.read(stdout)
+	s.proc = proc
+
+	return nil
+}
+
+// stop kills the running process, if any; s.mu must be held
+func (s *SubprocessSolver) stop() {
+	if s.proc == nil {
+		return
+	}
+
+	close(s.proc.quit)
+	s.proc.stdin.Close()
+	if s.proc.cmd.Process != nil {
+		s.proc.cmd.Process.Kill()
+	}
+	s.proc = nil
+}
+
+// read decodes responses until stdout closes, then reaps the process
+func (p *solverProcess) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		var resp subprocessResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			continue
+		}
+		select {
+		case p.responses <- resp:
+		case <-p.quit:
+			// Nobody is waiting; keep draining so the process can exit
+		}
+	}
+
+	p.err = scanner.Err()
+	if waitErr := p.cmd.Wait(); p.err == nil {
+		p.err = waitErr
+	}
+	if p.err == nil {
+		p.err = errors.New("stdout closed")
+	}
+	close(p.done)
+}
+
+// instrumentPlayer prefixes the browser stub and exports the signature and n functions as globals
+func instrumentPlayer(playerCode string) string {
+	var exports []playerExport
+
+	if name := findSigFunctionName(playerCode); name != "" {
+		exports = append(exports, playerExport{global: sigExportName, expr: name})
+	}
+
+	if name, idx := findNFunction(playerCode); name != "" {
+		expr := name
+		if idx != "" {
+			expr = fmt.Sprintf("%s[%s]", name, idx)
+		}
+		exports = append(exports, playerExport{global: nExportName, expr: expr})
+	}
+
+	return browserStub + "\n" + exportFromPlayer(playerCode, exports...)
+}
@@ -0,0 +1,218 @@
+package decipher
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess is not a real test: it runs a fake NDJSON solver when re-executed by newFakeSolver
+//
+// Signatures are reversed and n challenges uppercased. Special challenges make it misbehave:
+// "crash" exits without replying, "hang" never replies, "fail" is left unsolved and
+// "players" returns the IDs of the players loaded into this process
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	runFakeSolver(os.Stdin, os.Stdout)
+	os.Exit(0)
+}
+
+func runFakeSolver(r io.Reader, w io.Writer) {
+	players := make(map[string]bool)
+	enc := json.NewEncoder(w)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var req subprocessRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			enc.Encode(subprocessResponse{Error: err.Error()})
+			continue
+		}
+
+		resp := subprocessResponse{ID: req.ID, Results: make(map[string]string)}
+		switch req.Type {
+		case "load":
+			if !strings.Contains(req.Player, "fake player") {
+				resp.Error = "player was not sent"
+			}
+			players[req.PlayerID] = true
+		case "unload":
+			delete(players, req.PlayerID)
+		case "sig", "n":
+			if !players[req.PlayerID] {
+				resp.Error = "player not loaded: " + req.PlayerID
+				break
+			}
+			for _, c := range req.Challenges {
+				switch c {
+				case "crash":
+					os.Exit(3)
+				case "hang":
+					time.Sleep(time.Hour)
+				case "fail":
+				case "players":
+					var ids []string
+					for id := range players {
+						ids = append(ids, id)
+					}
+					sort.Strings(ids)
+					resp.Results[c] = strings.Join(ids, ",")
+				default:
+					if req.Type == "sig" {
+						resp.Results[c] = reverse(c)
+					} else {
+						resp.Results[c] = strings.ToUpper(c)
+					}
+				}
+			}
+		default:
+			resp.Error = "unknown request type: " + req.Type
+		}
+		enc.Encode(resp)
+	}
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+// newFakeSolver returns a SubprocessSolver running the test binary as a fake solver
+func newFakeSolver(t *testing.T) *SubprocessSolver {
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+
+	s := NewSubprocessSolver(os.Args[0], "-test.run=^TestHelperProcess$")
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// loadedPlayers asks the running process which players it holds
+func loadedPlayers(t *testing.T, s *SubprocessSolver, playerID string) string {
+	t.Helper()
+
+	out, err := s.SolveN(playerID, []string{"players"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out["players"]
+}
+
+func TestSubprocessSolverRoundTrip(t *testing.T) {
+	s := newFakeSolver(t)
+
+	if _, err := s.SolveN("p1", []string{"abc"}); err == nil {
+		t.Error("solving before the player is loaded should fail")
+	}
+
+	if err := s.LoadPlayer("p1", "var fake player;"); err != nil {
+		t.Fatal(err)
+	}
+
+	sigs, err := s.SolveSignatures("p1", []string{"abc", "xyz12", "fail"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sigs) != 2 || sigs["abc"] != "cba" || sigs["xyz12"] != "21zyx" {
+		t.Errorf("signatures = %v", sigs)
+	}
+
+	ns, err := s.SolveN("p1", []string{"abc", "def"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ns) != 2 || ns["abc"] != "ABC" || ns["def"] != "DEF" {
+		t.Errorf("n = %v", ns)
+	}
+
+	// The batch goes through a Decipherer the same way
+	d, err := NewWithSolver("var fake player;", "https://www.youtube.com/s/player/p2/player_ias.vflset/en_US/base.js", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.SolveNChallenges([]string{"qrs", "tuv"}); got["qrs"] != "QRS" || got["tuv"] != "TUV" {
+		t.Errorf("decipherer n = %v", got)
+	}
+}
+
+func TestSubprocessSolverRestart(t *testing.T) {
+	s := newFakeSolver(t)
+
+	if err := s.LoadPlayer("p1", "var fake player;"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.SolveN("p1", []string{"crash"}); err == nil || !strings.Contains(err.Error(), "exited") {
+		t.Fatalf("crash: got %v, want a solver exited error", err)
+	}
+
+	// The next request restarts the process and reloads the player it needs
+	ns, err := s.SolveN("p1", []string{"abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns["abc"] != "ABC" {
+		t.Errorf("after restart n = %v", ns)
+	}
+
+	// Only the two most recent players are kept; the oldest is unloaded from the process
+	for _, id := range []string{"p2", "p3"} {
+		if err := s.LoadPlayer(id, "var fake player;"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := loadedPlayers(t, s, "p3"); got != "p2,p3" {
+		t.Errorf("loaded players = %q, want p2,p3", got)
+	}
+	if _, err := s.SolveN("p1", []string{"abc"}); err == nil {
+		t.Error("an evicted player should no longer be solvable")
+	}
+
+	// After a crash only the player being solved for is sent again
+	if _, err := s.SolveN("p3", []string{"crash"}); err == nil {
+		t.Fatal("expected the crash to fail the request")
+	}
+	if got := loadedPlayers(t, s, "p3"); got != "p3" {
+		t.Errorf("loaded players after restart = %q, want p3", got)
+	}
+	if got := loadedPlayers(t, s, "p2"); got != "p2,p3" {
+		t.Errorf("loaded players after solving for p2 = %q, want p2,p3", got)
+	}
+}
+
+func TestSubprocessSolverTimeout(t *testing.T) {
+	s := newFakeSolver(t)
+	s.Timeout = 200 * time.Millisecond
+
+	if err := s.LoadPlayer("p1", "var fake player;"); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err := s.SolveSignatures("p1", []string{"hang"})
+	if err == nil || !strings.Contains(err.Error(), "did not respond") {
+		t.Fatalf("hang: got %v, want a timeout error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timeout took %v", elapsed)
+	}
+
+	// The hung process was killed; a new one serves the next request
+	sigs, err := s.SolveSignatures("p1", []string{"abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sigs["abc"] != "cba" {
+		t.Errorf("after timeout signatures = %v", sigs)
+	}
+}
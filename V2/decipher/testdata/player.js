@@ -0,0 +1,18 @@
+var _yt_player={};(function(g){var window=this;
+/* Synthetic player: just enough structure for the n and signature extraction paths */
+var Xy={ab:function(a){a.reverse()},
+cd:function(a,b){a.splice(0,b)},
+ef:function(a,b){var c=a[0];a[0]=a[b%a.length];a[b%a.length]=c}};
+var Qz=function(a){a=a.split("");Xy.ef(a,3);Xy.ab(a,0);Xy.cd(a,2);return a.join("")};
+var Wm=function(a){var b=a.split(""),c=["}",'{',/[{}\/]/g,`${"{"}}`];
+// a stray } in a comment
+/* and { in a block comment */
+if(a.indexOf("fail")===0)return"enhanced_except_"+a;
+if(a==="sentinel")return a+"_w8_";
+if(c[3]!=="{}"||"}{/".replace(c[2],"")!=="")throw Error("bad literals");
+b.reverse();return b.join("")};
+var Nf=[Wm];
+g.load=function(a,d,e,f){var b,c;if((c=a.get("n"))&&(b=Nf[0](c))){a.set("n",b)}
+c&&d.set(e,encodeURIComponent(Qz(f)))};
+g.sts={signatureTimestamp:20000};
+})(_yt_player);
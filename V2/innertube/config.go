@@ -86,13 +86,39 @@ func (c *ClientConfig) GetContextWithVisitor(visitorData string) InnertubeContex
 	return ctx
 }
 
-// RequiresPoToken returns true if this client requires a PO token
+// RequiresPoToken returns true if this client requires a PO token of any kind without exemptions
+// It ignores the streaming protocol and any exemption that applies to the session
+//
+// Deprecated: use GVSPoTokenPolicy(protocol).IsRequired(state) for the format being fetched.
 func (c *ClientConfig) RequiresPoToken() bool {
-	// Check if any GVS policy requires a token
 	for _, policy := range c.GVSPoTokenPolicies {
 		if policy.Required {
 			return true
 		}
 	}
-	return c.PlayerPoTokenPolicy.Required
+	return c.PlayerPoTokenPolicy.Required || c.SubsPoTokenPolicy.Required
+}
+
+// GVSPoTokenPolicy returns the GVS policy for a streaming protocol
+// Protocols without a policy need no token
+func (c *ClientConfig) GVSPoTokenPolicy(protocol types.StreamingProtocol) types.PoTokenPolicy {
+	return c.GVSPoTokenPolicies[protocol]
+}
+
+// ShouldFetchPoToken reports whether a token for the context should be fetched under the given state
+// A GVS token is fetched if the policy of any of protocols, the ones the streams will be fetched over, asks for one
+func (c *ClientConfig) ShouldFetchPoToken(context types.PoTokenContext, state types.PoTokenState, protocols ...types.StreamingProtocol) bool {
+	switch context {
+	case types.PoTokenContextPlayer:
+		return c.PlayerPoTokenPolicy.ShouldFetch(state)
+	case types.PoTokenContextSubs:
+		return c.SubsPoTokenPolicy.ShouldFetch(state)
+	case types.PoTokenContextGVS:
+		for _, protocol := range protocols {
+			if c.GVSPoTokenPolicy(protocol).ShouldFetch(state) {
+				return true
+			}
+		}
+	}
+	return false
 }
@@ -0,0 +1,153 @@
+// Package ebml reads the EBML elements that WebM and Matroska files are built from.
+package ebml
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Matroska element IDs, with their length marker bits
+const (
+	IDEBML    = 0x1A45DFA3
+	IDSegment = 0x18538067
+	IDInfo    = 0x1549A966
+	IDTracks  = 0x1654AE6B
+	IDCluster = 0x1F43B675
+	IDCues    = 0x1C53BB6B
+
+	IDTimecodeScale = 0x2AD7B1
+	IDDuration      = 0x4489
+
+	IDTimecode      = 0xE7
+	IDBlockGroup    = 0xA0
+	IDBlock         = 0xA1
+	IDBlockDuration = 0x9B
+	IDSimpleBlock   = 0xA3
+
+	IDTrackEntry   = 0xAE
+	IDTrackNumber  = 0xD7
+	IDTrackType    = 0x83
+	IDCodecID      = 0x86
+	IDCodecPrivate = 0x63A2
+	IDCodecDelay   = 0x56AA
+	IDSeekPreRoll  = 0x56BB
+	IDAudio        = 0xE1
+	IDSampleRate   = 0xB5
+	IDChannels     = 0x9F
+
+	IDCuePoint           = 0xBB
+	IDCueTime            = 0xB3
+	IDCueTrackPositions  = 0xB7
+	IDCueClusterPosition = 0xF1
+)
+
+// UnknownSize marks an element whose size is not stored, as in live or streamed files
+const UnknownSize = -1
+
+// DefaultTimecodeScale is the Matroska default of one millisecond per tick
+const DefaultTimecodeScale = 1000000
+
+// Error reports malformed EBML
+// Callers rewrap it in their own package's error so only that error is exposed
+type Error struct {
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return "invalid ebml: " + e.Msg
+}
+
+// errorf builds an *Error
+func errorf(format string, args ...any) error {
+	return &Error{Msg: fmt.Sprintf(format, args...)}
+}
+
+// ParseVint reads a variable-length integer from the start of data, returning it and its length
+// IDs keep their length marker bit; sizes have it stripped
+func ParseVint(data []byte, keepMarker bool) (uint64, int, error) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0, errorf("bad variable-length integer")
+	}
+
+	length := 1
+	for mask := byte(0x80); data[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if len(data) < length {
+		return 0, 0, errorf("truncated variable-length integer")
+	}
+
+	value := uint64(data[0])
+	if !keepMarker {
+		value &= uint64(0xFF >> length)
+	}
+	for _, b := range data[1:length] {
+		value = value<<8 | uint64(b)
+	}
+
+	return value, length, nil
+}
+
+// ParseHeader reads an element ID and size from the start of data, returning the header length
+func ParseHeader(data []byte) (id uint64, size int64, header int, err error) {
+	id, idLen, err := ParseVint(data, true)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	raw, sizeLen, err := ParseVint(data[idLen:], false)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return id, vintSize(raw, sizeLen), idLen + sizeLen, nil
+}
+
+// vintSize maps the all-ones size value to UnknownSize
+func vintSize(raw uint64, length int) int64 {
+	if raw == 1<<(7*length)-1 {
+		return UnknownSize
+	}
+	return int64(raw)
+}
+
+// EachElement calls fn for every child element in data
+func EachElement(data []byte, fn func(id uint64, data []byte) error) error {
+	for pos := 0; pos < len(data); {
+		id, size, header, err := ParseHeader(data[pos:])
+		if err != nil {
+			return err
+		}
+		if size == UnknownSize || int64(len(data)-pos-header) < size {
+			return errorf("element %#x overruns its parent", id)
+		}
+
+		start := pos + header
+		if err := fn(id, data[start:start+int(size)]); err != nil {
+			return err
+		}
+		pos = start + int(size)
+	}
+	return nil
+}
+
+// ReadUint reads a big-endian unsigned integer of up to 8 bytes
+func ReadUint(data []byte) uint64 {
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+// ReadFloat reads a 4 or 8 byte big-endian float
+func ReadFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
@@ -0,0 +1,118 @@
+package ebml
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestParseVint(t *testing.T) {
+	tests := []struct {
+		data       string
+		keepMarker bool
+		want       uint64
+		length     int
+	}{
+		{"\x81", false, 1, 1},
+		{"\x81", true, 0x81, 1},
+		{"\x40\x02", false, 2, 2},
+		{"\x1A\x45\xDF\xA3", true, IDEBML, 4},
+		{"\x01\x00\x00\x00\x00\x00\x01\x00", false, 256, 8},
+	}
+
+	for _, tt := range tests {
+		got, n, err := ParseVint([]byte(tt.data), tt.keepMarker)
+		if err != nil || got != tt.want || n != tt.length {
+			t.Errorf("ParseVint(%q, %v) = %#x, %d, %v; want %#x, %d", tt.data, tt.keepMarker, got, n, err, tt.want, tt.length)
+		}
+	}
+
+	for _, data := range []string{"", "\x00", "\x40"} {
+		var ebmlErr *Error
+		if _, _, err := ParseVint([]byte(data), false); !errors.As(err, &ebmlErr) {
+			t.Errorf("ParseVint(%q): got %v, want *Error", data, err)
+		}
+	}
+}
+
+func TestParseHeaderUnknownSize(t *testing.T) {
+	id, size, header, err := ParseHeader([]byte("\x18\x53\x80\x67\x01\xFF\xFF\xFF\xFF\xFF\xFF\xFF"))
+	if err != nil || id != IDSegment || size != UnknownSize || header != 12 {
+		t.Errorf("ParseHeader = %#x, %d, %d, %v", id, size, header, err)
+	}
+}
+
+func TestEachElement(t *testing.T) {
+	// TrackNumber 1, CodecID "A_OPUS", Channels 2
+	data := []byte("\xD7\x81\x01\x86\x86A_OPUS\x9F\x81\x02")
+
+	var ids []uint64
+	err := EachElement(data, func(id uint64, data []byte) error {
+		ids = append(ids, id)
+		if id == IDCodecID && string(data) != "A_OPUS" {
+			t.Errorf("CodecID = %q", data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[0] != IDTrackNumber || ids[1] != IDCodecID || ids[2] != IDChannels {
+		t.Errorf("ids = %#x", ids)
+	}
+
+	var ebmlErr *Error
+	if err := EachElement(data[:6], func(uint64, []byte) error { return nil }); !errors.As(err, &ebmlErr) {
+		t.Errorf("overrunning element: got %v, want *Error", err)
+	}
+}
+
+func TestReadUintFloat(t *testing.T) {
+	if got := ReadUint([]byte{0x0F, 0x42, 0x40}); got != DefaultTimecodeScale {
+		t.Errorf("ReadUint = %d", got)
+	}
+	if got := ReadFloat([]byte{0x47, 0x3B, 0x80, 0x00}); got != 48000 {
+		t.Errorf("ReadFloat(float32) = %v", got)
+	}
+	if got := ReadFloat([]byte{0x40, 0xE7, 0x70, 0, 0, 0, 0, 0}); got != 48000 {
+		t.Errorf("ReadFloat(float64) = %v", got)
+	}
+	if got := ReadFloat([]byte{1, 2}); got != 0 {
+		t.Errorf("ReadFloat(2 bytes) = %v", got)
+	}
+}
+
+func TestReader(t *testing.T) {
+	// Segment of unknown size holding Info, then a truncated Cluster
+	stream := []byte("\x18\x53\x80\x67\xFF" + "\x15\x49\xA9\x66\x84\x2A\xD7\xB1\x80" + "\x1F\x43\xB6\x75\x85ab")
+	r := NewReader(bytes.NewReader(stream))
+
+	id, size, err := r.ReadHeader()
+	if err != nil || id != IDSegment || size != UnknownSize {
+		t.Fatalf("segment header = %#x, %d, %v", id, size, err)
+	}
+	if _, err := r.ReadBody(id, size); err == nil {
+		t.Error("read an unknown-size body")
+	}
+
+	id, size, err = r.ReadHeader()
+	if err != nil || id != IDInfo || size != 4 {
+		t.Fatalf("info header = %#x, %d, %v", id, size, err)
+	}
+	body, err := r.ReadBody(id, size)
+	if err != nil || !bytes.Equal(body, []byte("\x2A\xD7\xB1\x80")) {
+		t.Fatalf("info body = %q, %v", body, err)
+	}
+
+	id, size, err = r.ReadHeader()
+	if err != nil || id != IDCluster || size != 5 {
+		t.Fatalf("cluster header = %#x, %d, %v", id, size, err)
+	}
+	if err := r.Skip(id, size); err != io.ErrUnexpectedEOF {
+		t.Errorf("skipping truncated cluster: got %v, want io.ErrUnexpectedEOF", err)
+	}
+	if _, _, err := r.ReadHeader(); err != io.EOF {
+		t.Errorf("at end: got %v, want io.EOF", err)
+	}
+}
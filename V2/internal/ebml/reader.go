@@ -0,0 +1,96 @@
+package ebml
+
+import (
+	"bufio"
+	"io"
+)
+
+// maxElementSize bounds elements read into memory so corrupt sizes cannot exhaust memory
+const maxElementSize = 32 << 20
+
+// Reader reads element headers and bodies from a stream, forward only
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a Reader that buffers r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// ReadHeader reads the next element ID and size
+// It returns io.EOF only when the stream ends between elements
+func (e *Reader) ReadHeader() (id uint64, size int64, err error) {
+	id, _, err = e.readVint(true)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	raw, length, err := e.readVint(false)
+	if err != nil {
+		return 0, 0, unexpectedEOF(err)
+	}
+
+	return id, vintSize(raw, length), nil
+}
+
+// ReadBody reads an element body of known size
+func (e *Reader) ReadBody(id uint64, size int64) ([]byte, error) {
+	if size == UnknownSize || size > maxElementSize {
+		return nil, errorf("element %#x has unusable size %d", id, size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(e.r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return data, nil
+}
+
+// Skip discards an element body of known size
+func (e *Reader) Skip(id uint64, size int64) error {
+	if size == UnknownSize {
+		return errorf("cannot skip element %#x of unknown size", id)
+	}
+
+	_, err := e.r.Discard(int(size))
+	return unexpectedEOF(err)
+}
+
+// readVint reads a variable-length integer from the stream
+func (e *Reader) readVint(keepMarker bool) (uint64, int, error) {
+	first, err := e.r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	if first == 0 {
+		return 0, 0, errorf("bad variable-length integer")
+	}
+
+	length := 1
+	for mask := byte(0x80); first&mask == 0; mask >>= 1 {
+		length++
+	}
+
+	value := uint64(first)
+	if !keepMarker {
+		value &= uint64(0xFF >> length)
+	}
+	for i := 1; i < length; i++ {
+		b, err := e.r.ReadByte()
+		if err != nil {
+			return 0, 0, unexpectedEOF(err)
+		}
+		value = value<<8 | uint64(b)
+	}
+
+	return value, length, nil
+}
+
+// unexpectedEOF turns an EOF in the middle of an element into io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,353 @@
+package pot
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// DefaultTokenCacheSize is the number of tokens a TokenCache holds before evicting
+var DefaultTokenCacheSize = 4096
+
+// DefaultSweepInterval is how often expired tokens are removed from a TokenCache
+var DefaultSweepInterval = time.Minute
+
+// CacheKey identifies a cached token
+// Tokens are only reused for the same context, client, content binding and proxy
+type CacheKey struct {
+	Context    types.PoTokenContext `json:"context"`
+	ClientName string               `json:"client,omitempty"`
+	Binding    string               `json:"binding"`
+
+	// Hash of the proxy URL, so credentials in it never reach the disk
+	Proxy string `json:"proxy,omitempty"`
+}
+
+// cacheKey builds the cache key for a request
+func cacheKey(req TokenRequest) CacheKey {
+	return CacheKey{
+		Context:    req.Context,
+		ClientName: req.ClientName,
+		Binding:    req.Binding(),
+		Proxy:      proxyKey(req.Proxy),
+	}
+}
+
+// proxyKey hashes a proxy URL for use in a cache key
+func proxyKey(proxy string) string {
+	if proxy == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(proxy))
+	return hex.EncodeToString(sum[:8])
+}
+
+// cachedToken stores a token with its expiration
+type cachedToken struct {
+	Key       CacheKey  `json:"key"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// TokenCache is a size-bounded LRU of PO tokens that drops entries once they expire
+// A background sweeper runs while the cache holds tokens; with a file set, the cache survives restarts
+type TokenCache struct {
+	size     int
+	interval time.Duration
+	path     string
+
+	order   *list.List
+	entries map[CacheKey]*list.Element
+	mu      sync.Mutex
+
+	sweeping bool
+	stop     chan struct{}
+
+	// Serializes file writes so an older snapshot never replaces a newer one
+	writeMu sync.Mutex
+}
+
+// NewTokenCache creates a cache holding up to size tokens; size <= 0 disables the bound
+func NewTokenCache(size int) *TokenCache {
+	return &TokenCache{
+		size:     size,
+		interval: DefaultSweepInterval,
+		order:    list.New(),
+		entries:  make(map[CacheKey]*list.Element),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Get returns an unexpired token for key
+func (tc *TokenCache) Get(key CacheKey) (string, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	el, ok := tc.entries[key]
+	if !ok {
+		return "", false
+	}
+
+	entry := el.Value.(*cachedToken)
+	if !time.Now().Before(entry.ExpiresAt) {
+		tc.removeLocked(el)
+		return "", false
+	}
+
+	tc.order.MoveToFront(el)
+	return entry.Token, true
+}
+
+// Put stores a token until expiresAt, evicting the least recently used token when full
+func (tc *TokenCache) Put(key CacheKey, token string, expiresAt time.Time) {
+	tc.mu.Lock()
+
+	if el, ok := tc.entries[key]; ok {
+		entry := el.Value.(*cachedToken)
+		entry.Token = token
+		entry.ExpiresAt = expiresAt
+		tc.order.MoveToFront(el)
+	} else {
+		tc.entries[key] = tc.order.PushFront(&cachedToken{Key: key, Token: token, ExpiresAt: expiresAt})
+		for tc.size > 0 && tc.order.Len() > tc.size {
+			tc.removeLocked(tc.order.Back())
+		}
+	}
+
+	tc.startSweeperLocked()
+	tc.mu.Unlock()
+
+	tc.persist()
+}
+
+// Len returns the number of cached tokens, including expired ones not yet swept
+func (tc *TokenCache) Len() int {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.order.Len()
+}
+
+// Clear removes every token
+func (tc *TokenCache) Clear() {
+	tc.mu.Lock()
+	tc.order.Init()
+	tc.entries = make(map[CacheKey]*list.Element)
+	tc.mu.Unlock()
+
+	tc.persist()
+}
+
+// Sweep removes expired tokens and returns how many were removed
+func (tc *TokenCache) Sweep() int {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.sweepLocked(time.Now())
+}
+
+// SetFile persists the cache to path, loading any unexpired tokens already stored there
+func (tc *TokenCache) SetFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	stored, err := readTokens(path)
+	if err != nil {
+		return err
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.path = path
+
+	now := time.Now()
+	for _, entry := range stored {
+		if _, ok := tc.entries[entry.Key]; ok || !now.Before(entry.ExpiresAt) {
+			continue
+		}
+		if tc.size > 0 && tc.order.Len() >= tc.size {
+			break
+		}
+		tc.entries[entry.Key] = tc.order.PushBack(&cachedToken{Key: entry.Key, Token: entry.Token, ExpiresAt: entry.ExpiresAt})
+	}
+
+	if tc.order.Len() > 0 {
+		tc.startSweeperLocked()
+	}
+
+	return nil
+}
+
+// SetSweepInterval changes how often expired tokens are swept; it applies from the next sweeper start
+func (tc *TokenCache) SetSweepInterval(interval time.Duration) {
+	tc.mu.Lock()
+	tc.interval = interval
+	tc.mu.Unlock()
+}
+
+// Close stops the background sweeper
+func (tc *TokenCache) Close() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	select {
+	case <-tc.stop:
+	default:
+		close(tc.stop)
+	}
+}
+
+// removeLocked drops an entry; tc.mu must be held
+func (tc *TokenCache) removeLocked(el *list.Element) {
+	tc.order.Remove(el)
+	delete(tc.entries, el.Value.(*cachedToken).Key)
+}
+
+// sweepLocked removes tokens expired at now; tc.mu must be held
+func (tc *TokenCache) sweepLocked(now time.Time) int {
+	removed := 0
+	for el := tc.order.Front(); el != nil; {
+		next := el.Next()
+		if !now.Before(el.Value.(*cachedToken).ExpiresAt) {
+			tc.removeLocked(el)
+			removed++
+		}
+		el = next
+	}
+	return removed
+}
+
+// startSweeperLocked runs the sweeper unless it is running or the cache is closed; tc.mu must be held
+// The sweeper exits once the cache is empty, so idle caches hold no goroutine
+func (tc *TokenCache) startSweeperLocked() {
+	if tc.sweeping || tc.interval <= 0 {
+		return
+	}
+	select {
+	case <-tc.stop:
+		return
+	default:
+	}
+
+	tc.sweeping = true
+	go tc.sweep(tc.interval)
+}
+
+// sweep periodically removes expired tokens
+func (tc *TokenCache) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tc.stop:
+			tc.mu.Lock()
+			tc.sweeping = false
+			tc.mu.Unlock()
+			return
+		case now := <-ticker.C:
+			tc.mu.Lock()
+			removed := tc.sweepLocked(now)
+			empty := tc.order.Len() == 0
+			if empty {
+				tc.sweeping = false
+			}
+			tc.mu.Unlock()
+
+			if removed > 0 {
+				tc.persist()
+			}
+			if empty {
+				return
+			}
+		}
+	}
+}
+
+// persist writes the cache to its file, if one is set
+// Persisting is best effort; the in-memory cache stays authoritative
+func (tc *TokenCache) persist() {
+	tc.writeMu.Lock()
+	defer tc.writeMu.Unlock()
+
+	tc.mu.Lock()
+	path, snapshot := tc.path, tc.snapshotLocked()
+	tc.mu.Unlock()
+
+	if path != "" {
+		_ = writeTokens(path, snapshot)
+	}
+}
+
+// snapshotLocked copies the cached tokens, most recently used first; tc.mu must be held
+func (tc *TokenCache) snapshotLocked() []cachedToken {
+	if tc.path == "" {
+		return nil
+	}
+
+	snapshot := make([]cachedToken, 0, tc.order.Len())
+	for el := tc.order.Front(); el != nil; el = el.Next() {
+		snapshot = append(snapshot, *el.Value.(*cachedToken))
+	}
+	return snapshot
+}
+
+// readTokens loads persisted tokens; a missing file is an empty cache
+func readTokens(path string) ([]cachedToken, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []cachedToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to decode token cache: %w", err)
+	}
+	return tokens, nil
+}
+
+// writeTokens writes the tokens to a temporary file and renames it into place
+func writeTokens(path string, tokens []cachedToken) error {
+	if tokens == nil {
+		tokens = []cachedToken{}
+	}
+
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
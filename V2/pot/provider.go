@@ -4,13 +4,17 @@ package pot
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/elucid503/overture-play/v2/types"
 )
 
 // DefaultServerURL is the default bgutil HTTP server URL
@@ -18,19 +22,42 @@ import (
 const DefaultServerURL = "http://127.0.0.1:4416"
 
 // Provider generates PO tokens using a bgutil HTTP server
+// It implements TokenProvider
 type Provider struct {
 	serverURL  string
 	httpClient *http.Client
 
-	cache      map[string]*cachedToken
-	cacheLock  sync.RWMutex
 	cacheTTL   time.Duration
+
+	// Tokens keyed by context, client, content binding and proxy; may be shared between providers
+	Cache *TokenCache
+
+	// How long a successful ping is trusted before the server is probed again
+	HealthTTL time.Duration
+	// Timeout for background availability probes
+	ProbeTimeout time.Duration
+	// Backoff after a failed ping, doubled per consecutive failure up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	health     Health
+	healthLock sync.Mutex
+	probe      chan struct{}
 }
 
-// cachedToken stores a token with its expiration
-type cachedToken struct {
-	Token     string
-	ExpiresAt time.Time
+// Health describes the last known state of the bgutil server
+type Health struct {
+	Available bool
+	CheckedAt time.Time
+
+	// Reported by the server's /ping endpoint
+	Uptime  time.Duration
+	Version string
+
+	// Set while the server is failing
+	LastError           string
+	ConsecutiveFailures int
+	RetryAt             time.Time
 }
 
 // Request represents a request to the bgutil server
@@ -72,20 +99,160 @@ func NewProvider(serverURL string, httpClient *http.Client) *Provider {
 	return &Provider{
 		serverURL:  serverURL,
 		httpClient: httpClient,
-		cache:      make(map[string]*cachedToken),
 		cacheTTL:   5 * time.Hour,
+		Cache:      NewTokenCache(DefaultTokenCacheSize),
+
+		HealthTTL:    time.Minute,
+		ProbeTimeout: 5 * time.Second,
+		MinBackoff:   5 * time.Second,
+		MaxBackoff:   5 * time.Minute,
 	}
 }
 
 // IsAvailable checks if the bgutil server is reachable
 func (p *Provider) IsAvailable() bool {
-	_, err := p.Ping()
-	return err == nil
+	return p.IsAvailableContext(context.Background())
+}
+
+// IsAvailableContext reports whether the bgutil server is reachable
+// The last ping result is reused; only the first call waits for a ping, later checks refresh in the background
+// While the server is down, probes back off exponentially so requests do not wait on a dead server
+func (p *Provider) IsAvailableContext(ctx context.Context) bool {
+	p.healthLock.Lock()
+	h := p.health
+
+	if h.CheckedAt.IsZero() {
+		done := p.startProbeLocked()
+		p.healthLock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-done:
+		}
+		return p.Health().Available
+	}
+
+	if p.probeDueLocked(time.Now()) {
+		p.startProbeLocked()
+	}
+	p.healthLock.Unlock()
+
+	return h.Available
+}
+
+// Health returns the last known state of the bgutil server without contacting it
+func (p *Provider) Health() Health {
+	p.healthLock.Lock()
+	defer p.healthLock.Unlock()
+	return p.health
+}
+
+// probeDueLocked reports whether the cached health has expired; p.healthLock must be held
+func (p *Provider) probeDueLocked(now time.Time) bool {
+	if p.health.Available {
+		return now.Sub(p.health.CheckedAt) >= p.HealthTTL
+	}
+	return !now.Before(p.health.RetryAt)
+}
+
+// startProbeLocked pings the server in the background unless a probe is already running; p.healthLock must be held
+// The returned channel is closed once the probe has recorded its result
+func (p *Provider) startProbeLocked() <-chan struct{} {
+	if p.probe != nil {
+		return p.probe
+	}
+
+	done := make(chan struct{})
+	p.probe = done
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), p.ProbeTimeout)
+		p.PingContext(ctx)
+		cancel()
+
+		p.healthLock.Lock()
+		p.probe = nil
+		p.healthLock.Unlock()
+		close(done)
+	}()
+
+	return done
+}
+
+// recordSuccess marks the server healthy, keeping the last reported uptime and version if ping is nil
+func (p *Provider) recordSuccess(ping *PingResponse) {
+	p.healthLock.Lock()
+	defer p.healthLock.Unlock()
+
+	h := Health{
+		Available: true,
+		CheckedAt: time.Now(),
+		Uptime:    p.health.Uptime,
+		Version:   p.health.Version,
+	}
+	if ping != nil {
+		h.Uptime = time.Duration(ping.ServerUptime * float64(time.Second))
+		h.Version = ping.Version
+	}
+	p.health = h
+}
+
+// recordFailure marks the server down and schedules the next probe
+func (p *Provider) recordFailure(err error) {
+	p.healthLock.Lock()
+	defer p.healthLock.Unlock()
+
+	now := time.Now()
+	failures := p.health.ConsecutiveFailures + 1
+
+	backoff := p.MinBackoff
+	for i := 1; i < failures && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	p.health = Health{
+		Available:           false,
+		CheckedAt:           now,
+		Uptime:              p.health.Uptime,
+		Version:             p.health.Version,
+		LastError:           err.Error(),
+		ConsecutiveFailures: failures,
+		RetryAt:             now.Add(backoff),
+	}
 }
 
 // Ping checks if the bgutil server is running
 func (p *Provider) Ping() (*PingResponse, error) {
-	resp, err := p.httpClient.Get(p.serverURL + "/ping")
+	return p.PingContext(context.Background())
+}
+
+// PingContext checks if the bgutil server is running within the given context
+// The result updates the provider's health state unless the context was cancelled
+func (p *Provider) PingContext(ctx context.Context) (*PingResponse, error) {
+	pingResp, err := p.ping(ctx)
+	if err != nil {
+		if !errors.Is(ctx.Err(), context.Canceled) {
+			p.recordFailure(err)
+		}
+		return nil, err
+	}
+
+	p.recordSuccess(pingResp)
+	return pingResp, nil
+}
+
+// ping makes the /ping request
+func (p *Provider) ping(ctx context.Context) (*PingResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.serverURL+"/ping", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("bgutil server unreachable: %w", err)
 	}
@@ -107,34 +274,48 @@ func (p *Provider) Ping() (*PingResponse, error) {
 // Content binding is typically visitor_data for logged-out users
 // or the session ID (first part of data_sync_id) for logged-in users
 func (p *Provider) GetToken(contentBinding string) (string, error) {
-	return p.GetTokenWithOptions(contentBinding, nil)
+	return p.GetTokenContext(context.Background(), contentBinding)
+}
+
+// GetTokenContext fetches a PO token for the given content binding within the given context
+func (p *Provider) GetTokenContext(ctx context.Context, contentBinding string) (string, error) {
+	return p.GetTokenWithOptionsContext(ctx, contentBinding, nil)
 }
 
 // GetTokenWithOptions fetches a PO token with custom options
 func (p *Provider) GetTokenWithOptions(contentBinding string, opts *Request) (string, error) {
+	return p.GetTokenWithOptionsContext(context.Background(), contentBinding, opts)
+}
+
+// GetTokenWithOptionsContext fetches a PO token with custom options within the given context
+// Tokens fetched this way are cached without a PO token context; prefer GetPOToken
+func (p *Provider) GetTokenWithOptionsContext(ctx context.Context, contentBinding string, opts *Request) (string, error) {
+	key := CacheKey{Binding: contentBinding}
+	if opts != nil {
+		key.Proxy = proxyKey(opts.Proxy)
+	}
+
+	return p.getToken(ctx, key, opts)
+}
+
+// getToken returns a cached token for key or generates and caches a new one
+func (p *Provider) getToken(ctx context.Context, key CacheKey, opts *Request) (string, error) {
+	bypass := opts != nil && opts.BypassCache
+
 	// Check cache first
-	p.cacheLock.RLock()
-	if cached, ok := p.cache[contentBinding]; ok {
-		if time.Now().Before(cached.ExpiresAt) {
-			p.cacheLock.RUnlock()
-			return cached.Token, nil
+	if !bypass {
+		if token, ok := p.Cache.Get(key); ok {
+			return token, nil
 		}
 	}
-	p.cacheLock.RUnlock()
 
 	// Generate new token
-	token, expiresAt, err := p.generateToken(contentBinding, opts)
+	token, expiresAt, err := p.generateToken(ctx, key.Binding, opts)
 	if err != nil {
 		return "", err
 	}
 
-	// Cache the token
-	p.cacheLock.Lock()
-	p.cache[contentBinding] = &cachedToken{
-		Token:     token,
-		ExpiresAt: expiresAt,
-	}
-	p.cacheLock.Unlock()
+	p.Cache.Put(key, token, expiresAt)
 
 	return token, nil
 }
@@ -142,6 +323,11 @@ func (p *Provider) GetTokenWithOptions(contentBinding string, opts *Request) (st
 // GetGVSToken generates a GVS context PO token for video streaming
 // Use visitor_data for logged-out users or data_sync_id for logged-in users
 func (p *Provider) GetGVSToken(visitorData, dataSyncID string) (string, error) {
+	return p.GetGVSTokenContext(context.Background(), visitorData, dataSyncID)
+}
+
+// GetGVSTokenContext generates a GVS context PO token within the given context
+func (p *Provider) GetGVSTokenContext(ctx context.Context, visitorData, dataSyncID string) (string, error) {
 	contentBinding := visitorData
 
 	// If logged in, use session ID from DataSyncID
@@ -149,22 +335,48 @@ func (p *Provider) GetGVSToken(visitorData, dataSyncID string) (string, error) {
 		contentBinding = extractSessionID(dataSyncID)
 	}
 
-	return p.GetToken(contentBinding)
+	return p.getToken(ctx, CacheKey{Context: types.PoTokenContextGVS, Binding: contentBinding}, nil)
+}
+
+// Name identifies the provider
+func (p *Provider) Name() string {
+	return "bgutil-http"
+}
+
+// GetPOToken fetches a token for any PO token context from the bgutil server
+func (p *Provider) GetPOToken(ctx context.Context, req TokenRequest) (string, error) {
+	key := cacheKey(req)
+
+	// A cached token stays usable while the server is down
+	if token, ok := p.Cache.Get(key); ok {
+		return token, nil
+	}
+
+	if !p.IsAvailableContext(ctx) {
+		if h := p.Health(); h.LastError != "" {
+			return "", fmt.Errorf("bgutil server unavailable at %s: %s", p.serverURL, h.LastError)
+		}
+		return "", fmt.Errorf("bgutil server unavailable at %s", p.serverURL)
+	}
+
+	return p.getToken(ctx, key, &Request{Proxy: req.Proxy})
 }
 
 // generateToken makes the actual HTTP request to the bgutil server
-func (p *Provider) generateToken(contentBinding string, opts *Request) (string, time.Time, error) {
-	if opts == nil {
-		opts = &Request{}
+func (p *Provider) generateToken(ctx context.Context, contentBinding string, opts *Request) (string, time.Time, error) {
+	// Copy the options so callers can share them across goroutines
+	var r Request
+	if opts != nil {
+		r = *opts
 	}
-	opts.ContentBinding = contentBinding
+	r.ContentBinding = contentBinding
 
-	reqBody, err := json.Marshal(opts)
+	reqBody, err := json.Marshal(r)
 	if err != nil {
 		return "", time.Time{}, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", p.serverURL+"/get_pot", bytes.NewReader(reqBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", p.serverURL+"/get_pot", bytes.NewReader(reqBody))
 	if err != nil {
 		return "", time.Time{}, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -172,6 +384,10 @@ func (p *Provider) generateToken(contentBinding string, opts *Request) (string,
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
+		// A transport failure means the server is down; skip pings until the backoff expires
+		if ctx.Err() == nil {
+			p.recordFailure(err)
+		}
 		return "", time.Time{}, fmt.Errorf("request to bgutil server failed: %w", err)
 	}
 	defer resp.Body.Close()
@@ -194,6 +410,8 @@ func (p *Provider) generateToken(contentBinding string, opts *Request) (string,
 		return "", time.Time{}, fmt.Errorf("bgutil returned empty token")
 	}
 
+	p.recordSuccess(nil)
+
 	// Use expiry from response if available, otherwise use cache TTL
 	expiresAt := bgResp.ExpiresAt
 	if expiresAt.IsZero() {
@@ -205,9 +423,13 @@ func (p *Provider) generateToken(contentBinding string, opts *Request) (string,
 
 // ClearCache clears the token cache
 func (p *Provider) ClearCache() {
-	p.cacheLock.Lock()
-	p.cache = make(map[string]*cachedToken)
-	p.cacheLock.Unlock()
+	p.Cache.Clear()
+}
+
+// PersistCache stores the token cache in path so tokens survive restarts
+// Unexpired tokens already in the file are loaded immediately
+func (p *Provider) PersistCache(path string) error {
+	return p.Cache.SetFile(path)
 }
 
 // extractSessionID extracts the session ID from a DataSyncID
@@ -0,0 +1,200 @@
+package pot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// fakeBgutil stands in for a bgutil server that can be taken down
+// While down, /ping answers 503 and /get_pot is not expected to be called
+type fakeBgutil struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	down     bool
+	pings    int
+	requests []Request
+}
+
+func newFakeBgutil(t *testing.T) *fakeBgutil {
+	f := &fakeBgutil{}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeBgutil) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/ping":
+		f.pings++
+		if f.down {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(PingResponse{ServerUptime: 12.5, Version: "1.2.3"})
+	case "/get_pot":
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		f.requests = append(f.requests, req)
+		json.NewEncoder(w).Encode(Response{PoToken: "token-" + req.ContentBinding, ContentBinding: req.ContentBinding})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeBgutil) setDown(down bool) {
+	f.mu.Lock()
+	f.down = down
+	f.mu.Unlock()
+}
+
+func (f *fakeBgutil) counts() (pings, requests int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pings, len(f.requests)
+}
+
+// testProvider returns a provider for the fake server with short backoffs
+func testProvider(f *fakeBgutil) *Provider {
+	p := NewProvider(f.URL, nil)
+	p.MinBackoff = 40 * time.Millisecond
+	p.MaxBackoff = 100 * time.Millisecond
+	p.Cache.SetSweepInterval(0)
+	return p
+}
+
+// waitForProbe waits until a probe started after checked has recorded its result
+func waitForProbe(t *testing.T, p *Provider, checked time.Time) Health {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if h := p.Health(); h.CheckedAt.After(checked) {
+			return h
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("no probe recorded its result")
+	return Health{}
+}
+
+// waitUntil sleeps until at
+func waitUntil(at time.Time) {
+	time.Sleep(time.Until(at) + 5*time.Millisecond)
+}
+
+func TestProviderHealth(t *testing.T) {
+	f := newFakeBgutil(t)
+	p := testProvider(f)
+
+	if !p.IsAvailable() {
+		t.Fatal("server reported unavailable")
+	}
+	h := p.Health()
+	if !h.Available || h.Version != "1.2.3" || h.Uptime != 12500*time.Millisecond {
+		t.Errorf("health = %+v", h)
+	}
+
+	// A healthy result is trusted for HealthTTL without pinging again
+	for range 5 {
+		p.IsAvailable()
+	}
+	if pings, _ := f.counts(); pings != 1 {
+		t.Errorf("%d pings within the health TTL, want 1", pings)
+	}
+}
+
+func TestProviderCircuitBreaker(t *testing.T) {
+	f := newFakeBgutil(t)
+	f.setDown(true)
+	p := testProvider(f)
+	req := TokenRequest{Context: types.PoTokenContextGVS, VisitorData: "visitor"}
+
+	// The first check waits for the ping, which opens the breaker
+	if p.IsAvailable() {
+		t.Fatal("down server reported available")
+	}
+	h := p.Health()
+	if h.ConsecutiveFailures != 1 || h.LastError == "" {
+		t.Fatalf("health after one failure = %+v", h)
+	}
+	if backoff := h.RetryAt.Sub(h.CheckedAt); backoff != p.MinBackoff {
+		t.Errorf("first backoff = %v, want %v", backoff, p.MinBackoff)
+	}
+
+	// While open, requests fail fast without contacting the server
+	_, err := p.GetPOToken(context.Background(), req)
+	if err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("GetPOToken while down = %v", err)
+	}
+	if pings, requests := f.counts(); pings != 1 || requests != 0 {
+		t.Errorf("%d pings and %d token requests while the breaker is open, want 1 and 0", pings, requests)
+	}
+
+	// Each failed probe doubles the backoff, up to MaxBackoff
+	for _, want := range []time.Duration{80 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond} {
+		waitUntil(h.RetryAt)
+		if p.IsAvailable() {
+			t.Fatal("down server reported available")
+		}
+		h = waitForProbe(t, p, h.CheckedAt)
+		if backoff := h.RetryAt.Sub(h.CheckedAt); backoff != want {
+			t.Errorf("backoff after %d failures = %v, want %v", h.ConsecutiveFailures, backoff, want)
+		}
+	}
+	if h.ConsecutiveFailures != 4 {
+		t.Errorf("consecutive failures = %d, want 4", h.ConsecutiveFailures)
+	}
+
+	// Once the backoff expires, a half-open probe finds the server back and closes the breaker
+	f.setDown(false)
+	waitUntil(h.RetryAt)
+	if p.IsAvailable() {
+		t.Error("the probe's result was awaited instead of returning the last known state")
+	}
+	h = waitForProbe(t, p, h.CheckedAt)
+	if !h.Available || h.ConsecutiveFailures != 0 || h.LastError != "" {
+		t.Errorf("health after recovery = %+v", h)
+	}
+
+	token, err := p.GetPOToken(context.Background(), req)
+	if err != nil || token != "token-visitor" {
+		t.Errorf("GetPOToken after recovery = %q, %v", token, err)
+	}
+}
+
+func TestProviderServesCachedTokenWhileDown(t *testing.T) {
+	f := newFakeBgutil(t)
+	p := testProvider(f)
+	req := TokenRequest{Context: types.PoTokenContextPlayer, VideoID: "dQw4w9WgXcQ"}
+
+	if _, err := p.GetPOToken(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing the server makes token requests fail at the transport, which also opens the breaker
+	f.Close()
+	token, err := p.GetPOToken(context.Background(), req)
+	if err != nil || token != "token-dQw4w9WgXcQ" {
+		t.Errorf("cached token while down = %q, %v", token, err)
+	}
+
+	_, err = p.GetPOToken(context.Background(), TokenRequest{Context: types.PoTokenContextPlayer, VideoID: "jNQXAC9IVRw"})
+	if err == nil {
+		t.Fatal("uncached token generated while the server is down")
+	}
+	if h := p.Health(); h.Available || h.ConsecutiveFailures != 1 {
+		t.Errorf("health after a failed token request = %+v", h)
+	}
+}
@@ -0,0 +1,135 @@
+package pot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ScriptProvider generates PO tokens by running a bgutil-style script once per token
+// The script is invoked as `<command> <args...> -c <content binding> [-p <proxy>]`
+// and must print a JSON object with a poToken field as its last line of output
+type ScriptProvider struct {
+	Command string
+	Args    []string
+
+	// Environment for the script; nil inherits the current process environment
+	Env []string
+
+	// Tokens keyed by context, client, content binding and proxy; may be shared between providers
+	Cache *TokenCache
+
+	cacheTTL time.Duration
+}
+
+// NewScriptProvider creates a provider for a bgutil generate_once script
+// e.g. NewScriptProvider("node", "/path/to/bgutil/server/build/generate_once.js")
+func NewScriptProvider(command string, args ...string) *ScriptProvider {
+	return &ScriptProvider{
+		Command:  command,
+		Args:     args,
+		Cache:    NewTokenCache(DefaultTokenCacheSize),
+		cacheTTL: 5 * time.Hour,
+	}
+}
+
+// Name identifies the provider
+func (p *ScriptProvider) Name() string {
+	return "bgutil-script"
+}
+
+// GetPOToken runs the script for the request's content binding, reusing unexpired tokens
+func (p *ScriptProvider) GetPOToken(ctx context.Context, req TokenRequest) (string, error) {
+	key := cacheKey(req)
+
+	if token, ok := p.Cache.Get(key); ok {
+		return token, nil
+	}
+
+	token, expiresAt, err := p.run(ctx, key.Binding, req.Proxy)
+	if err != nil {
+		return "", err
+	}
+
+	p.Cache.Put(key, token, expiresAt)
+
+	return token, nil
+}
+
+// run invokes the script and parses its output
+func (p *ScriptProvider) run(ctx context.Context, binding, proxy string) (string, time.Time, error) {
+	if p.Command == "" {
+		return "", time.Time{}, fmt.Errorf("no script command configured")
+	}
+
+	args := append([]string{}, p.Args...)
+	args = append(args, "-c", binding)
+	if proxy != "" {
+		args = append(args, "-p", proxy)
+	}
+
+	cmd := exec.CommandContext(ctx, p.Command, args...)
+	cmd.Env = p.Env
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", time.Time{}, fmt.Errorf("script failed: %w: %s", err, msg)
+		}
+		return "", time.Time{}, fmt.Errorf("script failed: %w", err)
+	}
+
+	// The script may log before its result; the JSON is the last non-empty line
+	line := lastLine(stdout.String())
+	if line == "" {
+		return "", time.Time{}, fmt.Errorf("script produced no output")
+	}
+
+	var resp Response
+	if err := json.Unmarshal([]byte(line), &resp); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to decode script output: %w (output: %s)", err, line)
+	}
+
+	if resp.Error != "" {
+		return "", time.Time{}, fmt.Errorf("script error: %s", resp.Error)
+	}
+
+	if resp.PoToken == "" {
+		return "", time.Time{}, fmt.Errorf("script returned empty token")
+	}
+
+	expiresAt := resp.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(p.cacheTTL)
+	}
+
+	return resp.PoToken, expiresAt, nil
+}
+
+// ClearCache clears the token cache
+func (p *ScriptProvider) ClearCache() {
+	p.Cache.Clear()
+}
+
+// PersistCache stores the token cache in path so tokens survive restarts
+func (p *ScriptProvider) PersistCache(path string) error {
+	return p.Cache.SetFile(path)
+}
+
+// lastLine returns the last non-empty line of output
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return ""
+}
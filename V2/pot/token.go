@@ -0,0 +1,152 @@
+package pot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// ErrNoToken is returned when a provider has no token for a request
+var ErrNoToken = errors.New("no PO token available")
+
+// TokenRequest describes the PO token a caller needs
+type TokenRequest struct {
+	Context types.PoTokenContext
+
+	// Bound for player and subs tokens
+	VideoID string
+	// Bound for GVS tokens; DataSyncID takes precedence when logged in
+	VisitorData string
+	DataSyncID  string
+
+	// Innertube client the token is for, e.g. "WEB"
+	ClientName string
+	Proxy      string
+
+	// Overrides the binding derived from the fields above
+	ContentBinding string
+}
+
+// Binding returns the content binding for the request's context
+// Player and subs tokens are bound to the video ID; GVS tokens to the session ID or visitor data
+func (r TokenRequest) Binding() string {
+	if r.ContentBinding != "" {
+		return r.ContentBinding
+	}
+
+	switch r.Context {
+	case types.PoTokenContextPlayer, types.PoTokenContextSubs:
+		return r.VideoID
+	default:
+		if r.DataSyncID != "" {
+			return extractSessionID(r.DataSyncID)
+		}
+		return r.VisitorData
+	}
+}
+
+// TokenProvider supplies PO tokens for player, GVS and subs contexts
+type TokenProvider interface {
+	// Name identifies the provider in errors and logs
+	Name() string
+
+	// GetPOToken returns a token for the request, or an error if this provider cannot supply one
+	GetPOToken(ctx context.Context, req TokenRequest) (string, error)
+}
+
+// Chain tries each provider in order; the first that returns a token wins
+type Chain struct {
+	Providers []TokenProvider
+}
+
+// NewChain creates a provider chain
+func NewChain(providers ...TokenProvider) *Chain {
+	return &Chain{Providers: providers}
+}
+
+// Name identifies the chain
+func (c *Chain) Name() string {
+	return "chain"
+}
+
+// GetPOToken returns the first token any provider supplies
+// If every provider fails, the error joins each provider's failure
+func (c *Chain) GetPOToken(ctx context.Context, req TokenRequest) (string, error) {
+	var errs []error
+	for _, p := range c.Providers {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		token, err := p.GetPOToken(ctx, req)
+		if err == nil && token != "" {
+			return token, nil
+		}
+		if err == nil {
+			err = ErrNoToken
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+
+	if len(errs) == 0 {
+		return "", ErrNoToken
+	}
+	return "", errors.Join(errs...)
+}
+
+// StaticProvider serves tokens supplied by the operator
+// Tokens can be set per context, optionally for a specific content binding
+type StaticProvider struct {
+	tokens map[staticKey]string
+	mu     sync.RWMutex
+}
+
+// staticKey identifies a static token; an empty binding matches any binding
+type staticKey struct {
+	context types.PoTokenContext
+	binding string
+}
+
+// NewStaticProvider creates a provider with one token per context
+func NewStaticProvider(tokens map[types.PoTokenContext]string) *StaticProvider {
+	p := &StaticProvider{tokens: make(map[staticKey]string)}
+	for context, token := range tokens {
+		p.SetToken(context, token)
+	}
+	return p
+}
+
+// Name identifies the provider
+func (p *StaticProvider) Name() string {
+	return "static"
+}
+
+// SetToken sets the token used for a context regardless of binding
+func (p *StaticProvider) SetToken(context types.PoTokenContext, token string) {
+	p.SetBoundToken(context, "", token)
+}
+
+// SetBoundToken sets the token used for a context and a specific content binding
+func (p *StaticProvider) SetBoundToken(context types.PoTokenContext, binding, token string) {
+	p.mu.Lock()
+	p.tokens[staticKey{context: context, binding: binding}] = token
+	p.mu.Unlock()
+}
+
+// GetPOToken returns the token bound to the request, falling back to the context-wide token
+func (p *StaticProvider) GetPOToken(ctx context.Context, req TokenRequest) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if token, ok := p.tokens[staticKey{context: req.Context, binding: req.Binding()}]; ok && token != "" {
+		return token, nil
+	}
+	if token, ok := p.tokens[staticKey{context: req.Context}]; ok && token != "" {
+		return token, nil
+	}
+
+	return "", ErrNoToken
+}
@@ -0,0 +1,144 @@
+package pot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// funcProvider is a TokenProvider backed by a function
+type funcProvider struct {
+	name  string
+	calls int
+	fn    func(req TokenRequest) (string, error)
+}
+
+func (p *funcProvider) Name() string {
+	return p.name
+}
+
+func (p *funcProvider) GetPOToken(ctx context.Context, req TokenRequest) (string, error) {
+	p.calls++
+	return p.fn(req)
+}
+
+func TestTokenRequestBinding(t *testing.T) {
+	tests := []struct {
+		name string
+		req  TokenRequest
+		want string
+	}{
+		{"player", TokenRequest{Context: types.PoTokenContextPlayer, VideoID: "dQw4w9WgXcQ", VisitorData: "visitor"}, "dQw4w9WgXcQ"},
+		{"subs", TokenRequest{Context: types.PoTokenContextSubs, VideoID: "dQw4w9WgXcQ"}, "dQw4w9WgXcQ"},
+		{"gvs logged out", TokenRequest{Context: types.PoTokenContextGVS, VideoID: "dQw4w9WgXcQ", VisitorData: "visitor"}, "visitor"},
+		{"gvs logged in", TokenRequest{Context: types.PoTokenContextGVS, VisitorData: "visitor", DataSyncID: "session||user"}, "session"},
+		{"override", TokenRequest{Context: types.PoTokenContextPlayer, VideoID: "dQw4w9WgXcQ", ContentBinding: "custom"}, "custom"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.req.Binding(); got != tt.want {
+			t.Errorf("%s: Binding() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestChainFallsThrough(t *testing.T) {
+	failing := &funcProvider{name: "failing", fn: func(TokenRequest) (string, error) {
+		return "", errors.New("server down")
+	}}
+	empty := &funcProvider{name: "empty", fn: func(TokenRequest) (string, error) {
+		return "", nil
+	}}
+	working := &funcProvider{name: "working", fn: func(req TokenRequest) (string, error) {
+		return "token-" + req.Binding(), nil
+	}}
+	unused := &funcProvider{name: "unused", fn: func(TokenRequest) (string, error) {
+		return "unused", nil
+	}}
+
+	chain := NewChain(failing, empty, working, unused)
+	token, err := chain.GetPOToken(context.Background(), TokenRequest{Context: types.PoTokenContextPlayer, VideoID: "dQw4w9WgXcQ"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token-dQw4w9WgXcQ" {
+		t.Errorf("token = %q", token)
+	}
+	if failing.calls != 1 || empty.calls != 1 || working.calls != 1 || unused.calls != 0 {
+		t.Errorf("calls = %d, %d, %d, %d; want 1, 1, 1, 0", failing.calls, empty.calls, working.calls, unused.calls)
+	}
+}
+
+func TestChainJoinsErrors(t *testing.T) {
+	failing := &funcProvider{name: "failing", fn: func(TokenRequest) (string, error) {
+		return "", errors.New("server down")
+	}}
+	static := NewStaticProvider(nil)
+
+	_, err := NewChain(failing, static).GetPOToken(context.Background(), TokenRequest{Context: types.PoTokenContextGVS})
+	if err == nil {
+		t.Fatal("chain of failing providers returned a token")
+	}
+	if !errors.Is(err, ErrNoToken) {
+		t.Errorf("error %v does not wrap ErrNoToken", err)
+	}
+	for _, want := range []string{"failing: server down", "static: "} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	if _, err := NewChain().GetPOToken(context.Background(), TokenRequest{}); !errors.Is(err, ErrNoToken) {
+		t.Errorf("empty chain returned %v, want ErrNoToken", err)
+	}
+}
+
+func TestChainStopsOnCancel(t *testing.T) {
+	provider := &funcProvider{name: "provider", fn: func(TokenRequest) (string, error) {
+		return "token", nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := NewChain(provider).GetPOToken(ctx, TokenRequest{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if provider.calls != 0 {
+		t.Errorf("provider called %d times after cancel", provider.calls)
+	}
+}
+
+func TestStaticProvider(t *testing.T) {
+	p := NewStaticProvider(map[types.PoTokenContext]string{types.PoTokenContextGVS: "gvs-any"})
+	p.SetBoundToken(types.PoTokenContextGVS, "visitor-a", "gvs-a")
+	p.SetBoundToken(types.PoTokenContextPlayer, "dQw4w9WgXcQ", "player-bound")
+
+	tests := []struct {
+		name string
+		req  TokenRequest
+		want string
+	}{
+		{"bound", TokenRequest{Context: types.PoTokenContextGVS, VisitorData: "visitor-a"}, "gvs-a"},
+		{"context-wide", TokenRequest{Context: types.PoTokenContextGVS, VisitorData: "visitor-b"}, "gvs-any"},
+		{"bound without fallback", TokenRequest{Context: types.PoTokenContextPlayer, VideoID: "dQw4w9WgXcQ"}, "player-bound"},
+		{"other binding", TokenRequest{Context: types.PoTokenContextPlayer, VideoID: "jNQXAC9IVRw"}, ""},
+		{"other context", TokenRequest{Context: types.PoTokenContextSubs, VideoID: "dQw4w9WgXcQ"}, ""},
+	}
+
+	for _, tt := range tests {
+		token, err := p.GetPOToken(context.Background(), tt.req)
+		if tt.want == "" {
+			if !errors.Is(err, ErrNoToken) {
+				t.Errorf("%s: got %q, %v; want ErrNoToken", tt.name, token, err)
+			}
+			continue
+		}
+		if err != nil || token != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, token, err, tt.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/elucid503/overture-play/v2/types"
@@ -18,6 +19,34 @@ type Handler struct {
 
 	ChunkSize  int64
 	MaxRetries int
+
+	// Called when YouTube rejects a stream URL with 403 Forbidden
+	// Client.NewStreamHandler wires it to Client.MarkPlayerStale so a rotated player is picked up
+	OnForbidden func(format types.Format)
+
+	// Optional; swaps in a fresh URL when a stream URL has expired or is rejected with 403/410
+	// Client.NewStreamHandler wires it to Client.FormatResolver
+	Resolver Resolver
+	// URLs expiring within this margin are re-resolved before a request is made
+	ExpiryMargin time.Duration
+
+	// Number of connections fetching chunks concurrently; 1 downloads sequentially
+	Workers int
+	// Maximum chunks held in memory, in flight or waiting to be written in order; 0 means 2*Workers
+	MaxBufferedChunks int
+
+	// Number of ChunkSize blocks each reader returned by Open keeps in memory
+	CacheBlocks int
+}
+
+// StatusError is returned when a stream request gets an unexpected HTTP status
+type StatusError struct {
+	StatusCode int
+}
+
+// Error returns the unexpected status
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status: %d", e.StatusCode)
 }
 
 // NewHandler creates a new stream handler with default settings
@@ -30,6 +59,11 @@ func NewHandler() *Handler {
 
 		ChunkSize:  10 * 1024 * 1024, // 10MB chunks
 		MaxRetries: 3,
+
+		ExpiryMargin: 30 * time.Second,
+
+		Workers:     4,
+		CacheBlocks: 4,
 	}
 }
 
@@ -52,7 +86,7 @@ func (h *Handler) Download(ctx context.Context, format types.Format, w io.Writer
 	}
 
 	// Otherwise, simple download
-	return h.downloadSimple(ctx, format.URL, w)
+	return h.downloadSimple(ctx, format, w)
 }
 
 // DownloadRange downloads a specific byte range
@@ -75,11 +109,26 @@ func (h *Handler) GetStreamRange(ctx context.Context, format types.Format, start
 		return nil, 0, fmt.Errorf("format has no URL")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", format.URL, nil)
+	var resp *http.Response
+	err := h.withFreshURL(ctx, newFormatRef(format), func(format types.Format) error {
+		var err error
+		resp, err = h.openRange(ctx, format, start, end)
+		return err
+	})
 	if err != nil {
 		return nil, 0, err
 	}
 
+	return resp.Body, resp.ContentLength, nil
+}
+
+// openRange issues a GET for a byte range; end <= 0 requests everything from start
+func (h *Handler) openRange(ctx context.Context, format types.Format, start, end int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", format.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	h.setHeaders(req)
 
 	// Set range header
@@ -91,19 +140,26 @@ func (h *Handler) GetStreamRange(ctx context.Context, format types.Format, start
 
 	resp, err := h.HTTPClient.Do(req)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
 		resp.Body.Close()
-		return nil, 0, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+		return nil, h.statusError(format, resp.StatusCode)
 	}
 
-	return resp.Body, resp.ContentLength, nil
+	return resp, nil
 }
 
 // downloadWithRanges downloads using chunked range requests
+// With more than one worker, chunks are fetched concurrently
 func (h *Handler) downloadWithRanges(ctx context.Context, format types.Format, w io.Writer, start, end int64) error {
+	ref := newFormatRef(format)
+
+	if h.Workers > 1 && end-start > h.ChunkSize {
+		return h.downloadParallel(ctx, ref, w, start, end)
+	}
+
 	var downloaded int64 = start
 
 	for downloaded < end {
@@ -118,7 +174,7 @@ func (h *Handler) downloadWithRanges(ctx context.Context, format types.Format, w
 			chunkEnd = end - 1
 		}
 
-		err := h.downloadChunk(ctx, format.URL, w, downloaded, chunkEnd)
+		err := h.downloadChunk(ctx, ref, w, downloaded, chunkEnd)
 		if err != nil {
 			return err
 		}
@@ -129,23 +185,38 @@ func (h *Handler) downloadWithRanges(ctx context.Context, format types.Format, w
 	return nil
 }
 
-// downloadChunk downloads a single chunk with retries
-func (h *Handler) downloadChunk(ctx context.Context, url string, w io.Writer, start, end int64) error {
+// downloadChunk downloads a single chunk with retries and writes it to w
+// The chunk is buffered so a failed attempt never leaves partial data in w to be written again by the retry
+func (h *Handler) downloadChunk(ctx context.Context, ref *formatRef, w io.Writer, start, end int64) error {
+	data, err := h.fetchChunk(ctx, ref, chunkRange{start: start, end: end})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// retry runs attempt up to MaxRetries times with a linear backoff
+func (h *Handler) retry(ctx context.Context, attempt func() error) error {
 	var lastErr error
 
-	for attempt := 0; attempt < h.MaxRetries; attempt++ {
-		if attempt > 0 {
+	for i := 0; i < h.MaxRetries; i++ {
+		if i > 0 {
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
-			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-time.After(time.Duration(i) * time.Second):
 			}
 		}
 
-		err := h.doChunkRequest(ctx, url, w, start, end)
+		err := attempt()
 		if err == nil {
 			return nil
 		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 
 		lastErr = err
 	}
@@ -154,8 +225,8 @@ func (h *Handler) downloadChunk(ctx context.Context, url string, w io.Writer, st
 }
 
 // doChunkRequest performs a single chunk request
-func (h *Handler) doChunkRequest(ctx context.Context, url string, w io.Writer, start, end int64) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+func (h *Handler) doChunkRequest(ctx context.Context, format types.Format, w io.Writer, start, end int64) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", format.URL, nil)
 	if err != nil {
 		return err
 	}
@@ -170,16 +241,32 @@ func (h *Handler) doChunkRequest(ctx context.Context, url string, w io.Writer, s
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+		return h.statusError(format, resp.StatusCode)
 	}
 
-	_, err = io.Copy(w, resp.Body)
+	// A 200 carries the whole stream, which is only the requested chunk if it starts at 0
+	if resp.StatusCode == http.StatusOK && start > 0 {
+		return fmt.Errorf("server ignored range request")
+	}
+
+	want := end - start + 1
+	n, err := io.Copy(w, io.LimitReader(resp.Body, want))
+	if err == nil && n < want {
+		err = io.ErrUnexpectedEOF
+	}
 	return err
 }
 
 // downloadSimple performs a simple download without range requests
-func (h *Handler) downloadSimple(ctx context.Context, url string, w io.Writer) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+func (h *Handler) downloadSimple(ctx context.Context, format types.Format, w io.Writer) error {
+	return h.withFreshURL(ctx, newFormatRef(format), func(format types.Format) error {
+		return h.doSimpleRequest(ctx, format, w)
+	})
+}
+
+// doSimpleRequest performs a single full download request
+func (h *Handler) doSimpleRequest(ctx context.Context, format types.Format, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", format.URL, nil)
 	if err != nil {
 		return err
 	}
@@ -193,13 +280,21 @@ func (h *Handler) downloadSimple(ctx context.Context, url string, w io.Writer) e
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+		return h.statusError(format, resp.StatusCode)
 	}
 
 	_, err = io.Copy(w, resp.Body)
 	return err
 }
 
+// statusError builds a StatusError and notifies OnForbidden for 403 responses
+func (h *Handler) statusError(format types.Format, statusCode int) error {
+	if statusCode == http.StatusForbidden && h.OnForbidden != nil {
+		h.OnForbidden(format)
+	}
+	return &StatusError{StatusCode: statusCode}
+}
+
 // setHeaders sets required headers for requests
 func (h *Handler) setHeaders(req *http.Request) {
 	req.Header.Set("User-Agent", h.UserAgent)
@@ -222,6 +317,17 @@ func (h *Handler) GetStreamInfo(ctx context.Context, format types.Format) (*Stre
 		return nil, fmt.Errorf("format has no URL")
 	}
 
+	var info *StreamInfo
+	err := h.withFreshURL(ctx, newFormatRef(format), func(format types.Format) error {
+		var err error
+		info, err = h.headStream(ctx, format)
+		return err
+	})
+	return info, err
+}
+
+// headStream performs a single HEAD request for stream metadata
+func (h *Handler) headStream(ctx context.Context, format types.Format) (*StreamInfo, error) {
 	req, err := http.NewRequestWithContext(ctx, "HEAD", format.URL, nil)
 	if err != nil {
 		return nil, err
@@ -236,7 +342,7 @@ func (h *Handler) GetStreamInfo(ctx context.Context, format types.Format) (*Stre
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+		return nil, h.statusError(format, resp.StatusCode)
 	}
 
 	info := &StreamInfo{
@@ -290,16 +396,19 @@ func (h *Handler) DownloadWithProgress(ctx context.Context, format types.Format,
 		return h.downloadWithRanges(ctx, format, pw, 0, total)
 	}
 
-	return h.downloadSimple(ctx, format.URL, pw)
+	return h.downloadSimple(ctx, format, pw)
 }
 
 // progressWriter wraps a writer to track progress
+// Safe for concurrent use; the callback is never called concurrently
 type progressWriter struct {
 	writer     io.Writer
 	total      int64
 	downloaded int64
 	callback   ProgressCallback
 	startTime  time.Time
+
+	mu sync.Mutex
 }
 
 func (pw *progressWriter) Write(p []byte) (int, error) {
@@ -308,6 +417,15 @@ func (pw *progressWriter) Write(p []byte) (int, error) {
 		return n, err
 	}
 
+	pw.report(n)
+	return n, nil
+}
+
+// report adds n downloaded bytes and notifies the callback
+func (pw *progressWriter) report(n int) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
 	pw.downloaded += int64(n)
 
 	if pw.callback != nil {
@@ -320,6 +438,4 @@ func (pw *progressWriter) Write(p []byte) (int, error) {
 			Speed:      speed,
 		})
 	}
-
-	return n, nil
 }
@@ -0,0 +1,219 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// rangeServer serves data with byte range support
+// truncate, if set, is asked before each request whether to cut its body short
+type rangeServer struct {
+	*httptest.Server
+	data []byte
+
+	mu       sync.Mutex
+	requests int
+	starts   []int64
+	truncate func(request int, start int64) bool
+}
+
+func newRangeServer(t *testing.T, size int) *rangeServer {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	s := &rangeServer{data: data}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *rangeServer) serve(w http.ResponseWriter, r *http.Request) {
+	// Stands in for a stream URL that has expired
+	if r.URL.Path == "/expired" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	start, end := int64(0), int64(len(s.data)-1)
+	if spec, ok := strings.CutPrefix(r.Header.Get("Range"), "bytes="); ok {
+		from, to, _ := strings.Cut(spec, "-")
+		start, _ = strconv.ParseInt(from, 10, 64)
+		if to != "" {
+			end, _ = strconv.ParseInt(to, 10, 64)
+		}
+		if end >= int64(len(s.data)) {
+			end = int64(len(s.data)) - 1
+		}
+	}
+
+	s.mu.Lock()
+	s.requests++
+	s.starts = append(s.starts, start)
+	cut := s.truncate != nil && s.truncate(s.requests, start)
+	s.mu.Unlock()
+
+	body := s.data[start : end+1]
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(s.data)))
+	w.WriteHeader(http.StatusPartialContent)
+
+	if cut {
+		// Send half the body, then drop the connection
+		w.Write(body[:len(body)/2])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	w.Write(body)
+}
+
+func (s *rangeServer) format() types.Format {
+	return types.Format{ITag: 251, VideoID: "dQw4w9WgXcQ", URL: s.URL + "/videoplayback", ContentLength: len(s.data)}
+}
+
+// testHandler returns a handler with small chunks so downloads span many requests
+func testHandler(workers int) *Handler {
+	h := NewHandler()
+	h.ChunkSize = 4096
+	h.Workers = workers
+	return h
+}
+
+func TestDownloadParallelToFile(t *testing.T) {
+	server := newRangeServer(t, 100_000)
+	h := testHandler(4)
+
+	// Writes go after existing content and advance the file offset, not to absolute stream offsets
+	path := filepath.Join(t.TempDir(), "out")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	header := []byte("existing header")
+	if _, err := file.Write(header); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Download(context.Background(), server.format(), file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte("trailer")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(append(append([]byte(nil), header...), server.data...), "trailer"...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("file holds %d bytes, want %d matching the header, stream and trailer", len(got), len(want))
+	}
+}
+
+func TestDownloadParallelToAppendFile(t *testing.T) {
+	server := newRangeServer(t, 50_000)
+	h := testHandler(4)
+
+	path := filepath.Join(t.TempDir(), "out")
+	if err := os.WriteFile(path, []byte("prefix"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// WriteAt fails on O_APPEND files, so they must get ordered writes
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := h.Download(context.Background(), server.format(), file); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, append([]byte("prefix"), server.data...)) {
+		t.Errorf("appended file holds %d bytes, want %d", len(got), len(server.data)+6)
+	}
+}
+
+func TestDownloadWithProgressParallel(t *testing.T) {
+	server := newRangeServer(t, 30_000)
+	h := testHandler(3)
+
+	var buf bytes.Buffer
+	var last Progress
+	err := h.DownloadWithProgress(context.Background(), server.format(), &buf, func(p Progress) {
+		last = p
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), server.data) {
+		t.Error("downloaded data does not match")
+	}
+	if last.Downloaded != int64(len(server.data)) || last.Total != int64(len(server.data)) {
+		t.Errorf("last progress = %+v", last)
+	}
+}
+
+func TestDownloadToFile(t *testing.T) {
+	server := newRangeServer(t, 40_000)
+	h := testHandler(4)
+
+	path := filepath.Join(t.TempDir(), "audio.webm")
+	if err := h.DownloadToFile(context.Background(), server.format(), path); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, server.data) {
+		t.Error("downloaded file does not match")
+	}
+	for _, leftover := range []string{path + ".part", path + ".part.json"} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("%s was left behind", filepath.Base(leftover))
+		}
+	}
+}
+
+func TestDownloadRetriesWithoutDuplicating(t *testing.T) {
+	server := newRangeServer(t, 20_000)
+	// Cut the second chunk short on its first attempt
+	server.truncate = func(request int, start int64) bool {
+		return request == 2
+	}
+	h := testHandler(1)
+
+	var buf bytes.Buffer
+	if err := h.Download(context.Background(), server.format(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), server.data) {
+		t.Errorf("downloaded %d bytes, want %d matching the stream", buf.Len(), len(server.data))
+	}
+	if server.requests != 6 {
+		t.Errorf("server got %d requests, want 5 chunks and one retry", server.requests)
+	}
+}
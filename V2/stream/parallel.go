@@ -0,0 +1,174 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// chunkRange is an inclusive byte range fetched by one request
+type chunkRange struct {
+	start int64
+	end   int64
+}
+
+// chunkResult is a fetched chunk, or the error that stopped it
+type chunkResult struct {
+	index int
+	data  []byte
+	err   error
+}
+
+// splitRange divides [start, end) into chunks of at most size bytes
+func splitRange(start, end, size int64) []chunkRange {
+	var chunks []chunkRange
+	for offset := start; offset < end; offset += size {
+		chunkEnd := offset + size - 1
+		if chunkEnd >= end {
+			chunkEnd = end - 1
+		}
+		chunks = append(chunks, chunkRange{start: offset, end: chunkEnd})
+	}
+	return chunks
+}
+
+// downloadParallel fetches [start, end) over Workers connections
+func (h *Handler) downloadParallel(ctx context.Context, ref *formatRef, w io.Writer, start, end int64) error {
+	return h.downloadChunks(ctx, ref, w, nil, splitRange(start, end, h.ChunkSize), nil)
+}
+
+// downloadChunks fetches chunks concurrently over up to Workers connections
+// Chunks are reordered and written to w sequentially, unless writerAt is set: then each chunk is
+// written at its offset in the stream as soon as it arrives. writerAt must be a sink the handler owns,
+// such as DownloadToFile's part file; a caller's writer is never written out of order.
+// At most MaxBufferedChunks chunks are held in memory
+// done, if set, is called from a single goroutine after each chunk is written
+func (h *Handler) downloadChunks(ctx context.Context, ref *formatRef, w io.Writer, writerAt io.WriterAt, chunks []chunkRange, done func(chunkRange) error) error {
+	workers := h.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	buffered := h.MaxBufferedChunks
+	if buffered <= 0 {
+		buffered = 2 * workers
+	}
+	if buffered < workers {
+		buffered = workers
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	// A slot is taken before a chunk is dispatched and released once it is written
+	// Chunks are dispatched in order, so the next chunk to write always holds a slot
+	slots := make(chan struct{}, buffered)
+	jobs := make(chan int)
+	results := make(chan chunkResult)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for i := range chunks {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				chunk := chunks[i]
+				data, err := h.fetchChunk(ctx, ref, chunk)
+				if err == nil && writerAt != nil {
+					_, err = writerAt.WriteAt(data, chunk.start)
+					data = nil
+				}
+
+				select {
+				case results <- chunkResult{index: i, data: data, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	pending := make(map[int][]byte)
+	next := 0
+	for written := 0; written < len(chunks); {
+		var result chunkResult
+		select {
+		case result = <-results:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if result.err != nil {
+			return result.err
+		}
+
+		if writerAt != nil {
+			if done != nil {
+				if err := done(chunks[result.index]); err != nil {
+					return err
+				}
+			}
+			<-slots
+			written++
+			continue
+		}
+
+		pending[result.index] = result.data
+		for data, ok := pending[next]; ok; data, ok = pending[next] {
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			if done != nil {
+				if err := done(chunks[next]); err != nil {
+					return err
+				}
+			}
+			delete(pending, next)
+			<-slots
+			next++
+			written++
+		}
+	}
+
+	return nil
+}
+
+// fetchChunk downloads one chunk into memory with retries
+func (h *Handler) fetchChunk(ctx context.Context, ref *formatRef, chunk chunkRange) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(int(chunk.end - chunk.start + 1))
+
+	err := h.retry(ctx, func() error {
+		buf.Reset()
+		return h.withFreshURL(ctx, ref, func(format types.Format) error {
+			return h.doChunkRequest(ctx, format, &buf, chunk.start, chunk.end)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,276 @@
+package stream
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// ErrReaderClosed is returned by a Reader after Close
+var ErrReaderClosed = errors.New("stream reader closed")
+
+// Reader is a seekable view of a remote format, fetched in ChunkSize blocks
+// Blocks are requested lazily, the next block is read ahead during sequential reads,
+// and the most recently used blocks are cached. ReadAt is safe for concurrent use
+type Reader struct {
+	h    *Handler
+	ref  *formatRef
+	size int64
+
+	blockSize int64
+	maxBlocks int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	offset  int64
+	closed  bool
+	blocks  map[int64]*list.Element
+	lru     *list.List
+	loading map[int64]*blockLoad
+}
+
+// cachedBlock is one cached block of the stream
+type cachedBlock struct {
+	index int64
+	data  []byte
+}
+
+// blockLoad tracks an in-flight block fetch shared by concurrent readers
+type blockLoad struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// Open returns a seekable reader over the format
+// ctx bounds every request made by the reader; Close releases its cached blocks
+func (h *Handler) Open(ctx context.Context, format types.Format) (*Reader, error) {
+	if format.URL == "" {
+		return nil, fmt.Errorf("format has no URL")
+	}
+
+	size := int64(format.ContentLength)
+	if size <= 0 {
+		info, err := h.GetStreamInfo(ctx, format)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get content length: %w", err)
+		}
+		if info.ContentLength <= 0 {
+			return nil, fmt.Errorf("content length unknown; cannot seek")
+		}
+		size = info.ContentLength
+	}
+
+	// Room for the block being read and the one read ahead
+	maxBlocks := h.CacheBlocks
+	if maxBlocks < 2 {
+		maxBlocks = 2
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &Reader{
+		h:    h,
+		ref:  newFormatRef(format),
+		size: size,
+
+		blockSize: h.ChunkSize,
+		maxBlocks: maxBlocks,
+
+		ctx:    ctx,
+		cancel: cancel,
+
+		blocks:  make(map[int64]*list.Element),
+		lru:     list.New(),
+		loading: make(map[int64]*blockLoad),
+	}, nil
+}
+
+// Size returns the length of the stream in bytes
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// Read reads from the current offset, reading the next block ahead in the background
+func (r *Reader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	off := r.offset
+	r.mu.Unlock()
+
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	index := off / r.blockSize
+	data, err := r.block(index)
+	if err != nil {
+		return 0, err
+	}
+	if next := index + 1; next*r.blockSize < r.size {
+		r.prefetch(next)
+	}
+
+	n := copy(p, data[off-index*r.blockSize:])
+
+	r.mu.Lock()
+	r.offset = off + int64(n)
+	r.mu.Unlock()
+
+	return n, nil
+}
+
+// ReadAt reads len(p) bytes at off without moving the Read offset
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset")
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.size {
+			return n, io.EOF
+		}
+
+		index := pos / r.blockSize
+		data, err := r.block(index)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], data[pos-index*r.blockSize:])
+	}
+
+	return n, nil
+}
+
+// Seek sets the offset for the next Read; no request is made until then
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, ErrReaderClosed
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position")
+	}
+
+	r.offset = offset
+	return offset, nil
+}
+
+// Close cancels pending requests and drops the cached blocks
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+	r.cancel()
+	r.blocks = make(map[int64]*list.Element)
+	r.lru.Init()
+
+	return nil
+}
+
+// block returns the block at index, fetching it if it is not cached
+func (r *Reader) block(index int64) ([]byte, error) {
+	load, data, err := r.startLoad(index)
+	if load == nil {
+		return data, err
+	}
+
+	select {
+	case <-load.done:
+	case <-r.ctx.Done():
+		if r.isClosed() {
+			return nil, ErrReaderClosed
+		}
+		return nil, r.ctx.Err()
+	}
+
+	return load.data, load.err
+}
+
+// prefetch starts fetching the block at index without waiting for it
+func (r *Reader) prefetch(index int64) {
+	r.startLoad(index)
+}
+
+// startLoad returns the cached block at index, or the load fetching it
+func (r *Reader) startLoad(index int64) (*blockLoad, []byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, nil, ErrReaderClosed
+	}
+
+	if elem, ok := r.blocks[index]; ok {
+		r.lru.MoveToFront(elem)
+		return nil, elem.Value.(*cachedBlock).data, nil
+	}
+
+	load, ok := r.loading[index]
+	if !ok {
+		load = &blockLoad{done: make(chan struct{})}
+		r.loading[index] = load
+		go r.load(index, load)
+	}
+
+	return load, nil, nil
+}
+
+// load fetches one block, reconnecting and re-resolving the URL as needed, and caches it
+func (r *Reader) load(index int64, load *blockLoad) {
+	start := index * r.blockSize
+	end := min(start+r.blockSize, r.size) - 1
+
+	data, err := r.h.fetchChunk(r.ctx, r.ref, chunkRange{start: start, end: end})
+
+	r.mu.Lock()
+	delete(r.loading, index)
+	if err == nil && !r.closed {
+		r.blocks[index] = r.lru.PushFront(&cachedBlock{index: index, data: data})
+		for r.lru.Len() > r.maxBlocks {
+			oldest := r.lru.Back()
+			r.lru.Remove(oldest)
+			delete(r.blocks, oldest.Value.(*cachedBlock).index)
+		}
+	}
+	r.mu.Unlock()
+
+	load.data, load.err = data, err
+	close(load.done)
+}
+
+// isClosed reports whether Close has been called
+func (r *Reader) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
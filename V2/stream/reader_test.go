@@ -0,0 +1,197 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+// openReader opens a reader over the server's data with 4096 byte blocks
+func openReader(t *testing.T, server *rangeServer, cacheBlocks int) *Reader {
+	t.Helper()
+
+	h := testHandler(1)
+	h.CacheBlocks = cacheBlocks
+
+	r, err := h.Open(context.Background(), server.format())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+// requestCount returns how many requests the server has answered
+func (s *rangeServer) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func TestReaderSeek(t *testing.T) {
+	server := newRangeServer(t, 20_000)
+	r := openReader(t, server, 4)
+
+	tests := []struct {
+		name   string
+		offset int64
+		whence int
+		want   int64
+	}{
+		{"start", 5000, io.SeekStart, 5000},
+		{"current forward", 100, io.SeekCurrent, 5200},
+		{"current back", -3000, io.SeekCurrent, 2300},
+		{"end", -50, io.SeekEnd, 19_950},
+		{"start of stream", 0, io.SeekStart, 0},
+	}
+
+	buf := make([]byte, 100)
+	for _, tt := range tests {
+		pos, err := r.Seek(tt.offset, tt.whence)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if pos != tt.want {
+			t.Fatalf("%s: position %d, want %d", tt.name, pos, tt.want)
+		}
+
+		n := min(len(buf), int(r.Size()-pos))
+		if _, err := io.ReadFull(r, buf[:n]); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !bytes.Equal(buf[:n], server.data[pos:pos+int64(n)]) {
+			t.Errorf("%s: read the wrong bytes at %d", tt.name, pos)
+		}
+	}
+
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Error("seek to a negative position succeeded")
+	}
+	if _, err := r.Seek(0, 42); err == nil {
+		t.Error("seek with an invalid whence succeeded")
+	}
+}
+
+func TestReaderAcrossBlocks(t *testing.T) {
+	server := newRangeServer(t, 20_000)
+	r := openReader(t, server, 4)
+
+	// ReadAt spans the boundary between the first and second blocks
+	buf := make([]byte, 1000)
+	n, err := r.ReadAt(buf, 4096-300)
+	if err != nil || n != len(buf) {
+		t.Fatalf("ReadAt = %d, %v", n, err)
+	}
+	if !bytes.Equal(buf, server.data[4096-300:4096+700]) {
+		t.Error("ReadAt across a block boundary returned the wrong bytes")
+	}
+
+	// Read stops at the end of a block; sequential reads continue into the next one
+	if _, err := r.Seek(8192-100, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Read(buf); err != nil || n != 100 {
+		t.Fatalf("Read at the end of a block = %d, %v; want 100", n, err)
+	}
+	if n, err := r.Read(buf); err != nil || n != len(buf) {
+		t.Fatalf("Read at the start of a block = %d, %v", n, err)
+	}
+	if !bytes.Equal(buf, server.data[8192:8192+1000]) {
+		t.Error("Read after a block boundary returned the wrong bytes")
+	}
+
+	// Reading everything sequentially returns the whole stream
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	all, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(all, server.data) {
+		t.Errorf("read %d bytes, want the %d byte stream", len(all), len(server.data))
+	}
+}
+
+func TestReaderCacheEviction(t *testing.T) {
+	server := newRangeServer(t, 20_000)
+	r := openReader(t, server, 2)
+
+	// ReadAt does not read ahead, so each uncached block is exactly one request
+	buf := make([]byte, 10)
+	steps := []struct {
+		block    int64
+		requests int
+	}{
+		{0, 1},
+		{1, 2},
+		{0, 2}, // cached
+		{2, 3}, // evicts block 1, the least recently used
+		{0, 3}, // still cached
+		{1, 4}, // fetched again
+	}
+
+	for i, step := range steps {
+		off := step.block * 4096
+		if _, err := r.ReadAt(buf, off); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if !bytes.Equal(buf, server.data[off:off+10]) {
+			t.Errorf("step %d: read the wrong bytes from block %d", i, step.block)
+		}
+		if got := server.requestCount(); got != step.requests {
+			t.Errorf("step %d: %d requests after reading block %d, want %d", i, got, step.block, step.requests)
+		}
+	}
+}
+
+func TestReaderEOF(t *testing.T) {
+	server := newRangeServer(t, 10_000)
+	r := openReader(t, server, 4)
+
+	// The server clamps ranges past its data; the reader must stop at the content length
+	buf := make([]byte, 100)
+	n, err := r.ReadAt(buf, 9_950)
+	if n != 50 || err != io.EOF {
+		t.Fatalf("ReadAt past the end = %d, %v; want 50, EOF", n, err)
+	}
+	if !bytes.Equal(buf[:n], server.data[9_950:]) {
+		t.Error("ReadAt past the end returned the wrong bytes")
+	}
+
+	if n, err := r.ReadAt(buf, 20_000); n != 0 || err != io.EOF {
+		t.Errorf("ReadAt beyond the stream = %d, %v; want 0, EOF", n, err)
+	}
+
+	if _, err := r.Seek(5, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Read(buf); n != 0 || err != io.EOF {
+		t.Errorf("Read beyond the stream = %d, %v; want 0, EOF", n, err)
+	}
+
+	if _, err := r.Seek(-10, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(rest, server.data[9_990:]) {
+		t.Errorf("ReadAll at the end = %d bytes, %v; want the last 10", len(rest), err)
+	}
+}
+
+func TestReaderClosed(t *testing.T) {
+	server := newRangeServer(t, 10_000)
+	r := openReader(t, server, 4)
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(make([]byte, 10)); !errors.Is(err, ErrReaderClosed) {
+		t.Errorf("Read after Close = %v, want ErrReaderClosed", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); !errors.Is(err, ErrReaderClosed) {
+		t.Errorf("Seek after Close = %v, want ErrReaderClosed", err)
+	}
+}
@@ -0,0 +1,90 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// Resolver returns a fresh copy of a format whose stream URL has expired or was rejected
+type Resolver func(ctx context.Context, format types.Format) (types.Format, error)
+
+// formatRef holds the format of one download so a re-resolved URL is shared by every request of it
+type formatRef struct {
+	mu     sync.Mutex
+	format types.Format
+}
+
+// newFormatRef wraps a format for a single download
+func newFormatRef(format types.Format) *formatRef {
+	return &formatRef{format: format}
+}
+
+// get returns the current format
+func (r *formatRef) get() types.Format {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.format
+}
+
+// refresh replaces stale with a freshly resolved format
+// Callers are serialized, so when several requests fail on the same URL only the first one resolves it
+func (r *formatRef) refresh(ctx context.Context, resolver Resolver, stale types.Format) (types.Format, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.format.URL != stale.URL {
+		return r.format, nil
+	}
+
+	fresh, err := resolver(ctx, stale)
+	if err != nil {
+		return stale, fmt.Errorf("failed to re-resolve stream URL: %w", err)
+	}
+	if fresh.URL == "" {
+		return stale, fmt.Errorf("failed to re-resolve stream URL: format has no URL")
+	}
+
+	r.format = fresh
+	return fresh, nil
+}
+
+// withFreshURL runs do with the format's URL, re-resolving it first if it has expired
+// and once more if the URL is rejected
+func (h *Handler) withFreshURL(ctx context.Context, ref *formatRef, do func(types.Format) error) error {
+	format := ref.get()
+	if h.Resolver == nil {
+		return do(format)
+	}
+
+	if format.ExpiresWithin(h.ExpiryMargin) {
+		var err error
+		if format, err = ref.refresh(ctx, h.Resolver, format); err != nil {
+			return err
+		}
+	}
+
+	err := do(format)
+	if !isURLRejected(err) {
+		return err
+	}
+
+	format, rerr := ref.refresh(ctx, h.Resolver, format)
+	if rerr != nil {
+		return fmt.Errorf("%w (%v)", err, rerr)
+	}
+	return do(format)
+}
+
+// isURLRejected reports whether err means the stream URL itself is no longer valid
+func isURLRejected(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return statusErr.StatusCode == http.StatusForbidden || statusErr.StatusCode == http.StatusGone
+}
@@ -0,0 +1,209 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// ErrSizeMismatch is returned when a finished download does not match the format's content length
+var ErrSizeMismatch = errors.New("downloaded size does not match content length")
+
+// partState is the sidecar recording which ranges of a .part file are complete
+type partState struct {
+	VideoID       string      `json:"videoId"`
+	ITag          int         `json:"itag"`
+	ContentLength int64       `json:"contentLength"`
+	Completed     []byteRange `json:"completed"`
+}
+
+// byteRange is a half-open byte range [Start, End)
+type byteRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// DownloadToFile downloads a format to path, resuming an earlier interrupted download if possible
+// Data goes to path+".part" and progress to path+".part.json"; on success the part file is
+// checked against the content length and renamed to path. Expired URLs are re-resolved through Resolver
+func (h *Handler) DownloadToFile(ctx context.Context, format types.Format, path string) error {
+	if format.URL == "" {
+		return fmt.Errorf("format has no URL")
+	}
+
+	total := int64(format.ContentLength)
+	if total <= 0 {
+		info, err := h.GetStreamInfo(ctx, format)
+		if err != nil {
+			return fmt.Errorf("failed to get content length: %w", err)
+		}
+		if info.ContentLength <= 0 {
+			return fmt.Errorf("content length unknown; cannot download resumably")
+		}
+		total = info.ContentLength
+	}
+
+	partPath := path + ".part"
+	statePath := partPath + ".json"
+
+	state := partState{VideoID: format.VideoID, ITag: format.ITag, ContentLength: total}
+	if saved, err := readPartState(statePath); err == nil && saved.matches(state) {
+		if fi, err := os.Stat(partPath); err == nil && fi.Size() == total {
+			state.Completed = saved.Completed
+		}
+	}
+
+	flags := os.O_RDWR | os.O_CREATE
+	if len(state.Completed) == 0 {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(partPath, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Size the file up front so chunks can be written at their offsets in any order
+	if err := file.Truncate(total); err != nil {
+		return err
+	}
+
+	var chunks []chunkRange
+	for _, missing := range state.missing() {
+		chunks = append(chunks, splitRange(missing.Start, missing.End, h.ChunkSize)...)
+	}
+
+	if len(chunks) > 0 {
+		record := func(chunk chunkRange) error {
+			// Data must be on disk before the sidecar claims it
+			if err := file.Sync(); err != nil {
+				return err
+			}
+			state.add(byteRange{Start: chunk.start, End: chunk.end + 1})
+			return writePartState(statePath, state)
+		}
+
+		if err := h.downloadChunks(ctx, newFormatRef(format), nil, file, chunks, record); err != nil {
+			return err
+		}
+	}
+
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	// Every byte must have been fetched, not merely allocated by Truncate
+	var fetched int64
+	for _, r := range state.Completed {
+		fetched += r.End - r.Start
+	}
+	if fetched != total || fi.Size() != total {
+		os.Remove(partPath)
+		os.Remove(statePath)
+		return fmt.Errorf("%w: got %d of %d bytes in a %d byte file", ErrSizeMismatch, fetched, total, fi.Size())
+	}
+
+	if err := os.Rename(partPath, path); err != nil {
+		return err
+	}
+	os.Remove(statePath)
+
+	return nil
+}
+
+// matches reports whether a saved state describes the same stream as other
+func (s partState) matches(other partState) bool {
+	return s.VideoID == other.VideoID && s.ITag == other.ITag && s.ContentLength == other.ContentLength
+}
+
+// add marks r complete, keeping Completed sorted and merged
+func (s *partState) add(r byteRange) {
+	ranges := append(s.Completed, r)
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+
+	merged := ranges[:1]
+	for _, next := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if next.Start <= last.End {
+			last.End = max(last.End, next.End)
+			continue
+		}
+		merged = append(merged, next)
+	}
+	s.Completed = merged
+}
+
+// missing returns the ranges of [0, ContentLength) not yet complete
+func (s partState) missing() []byteRange {
+	var gaps []byteRange
+	var offset int64
+
+	for _, r := range s.Completed {
+		if r.Start > offset {
+			gaps = append(gaps, byteRange{Start: offset, End: min(r.Start, s.ContentLength)})
+		}
+		offset = max(offset, r.End)
+	}
+	if offset < s.ContentLength {
+		gaps = append(gaps, byteRange{Start: offset, End: s.ContentLength})
+	}
+
+	return gaps
+}
+
+// readPartState loads a sidecar file
+func readPartState(path string) (partState, error) {
+	var state partState
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// writePartState atomically replaces a sidecar file
+func writePartState(path string, state partState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// writePart leaves an interrupted download at path: a full-size part file holding data up to done,
+// and a sidecar claiming the ranges in completed
+func writePart(t *testing.T, path string, data []byte, done int, state partState) {
+	t.Helper()
+
+	part := make([]byte, len(data))
+	copy(part, data[:done])
+	if err := os.WriteFile(path+".part", part, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePartState(path+".part.json", state); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkDownloaded checks that path holds data and that the part files are gone
+func checkDownloaded(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("file holds %d bytes, want %d matching the stream", len(got), len(data))
+	}
+	for _, leftover := range []string{path + ".part", path + ".part.json"} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("%s was left behind", filepath.Base(leftover))
+		}
+	}
+}
+
+func TestDownloadToFileResumes(t *testing.T) {
+	server := newRangeServer(t, 40_000)
+	format := server.format()
+	h := testHandler(2)
+
+	path := filepath.Join(t.TempDir(), "audio.webm")
+	writePart(t, path, server.data, 16384, partState{
+		VideoID: format.VideoID, ITag: format.ITag, ContentLength: 40_000,
+		Completed: []byteRange{{Start: 0, End: 16384}},
+	})
+
+	if err := h.DownloadToFile(context.Background(), format, path); err != nil {
+		t.Fatal(err)
+	}
+	checkDownloaded(t, path, server.data)
+
+	// Only the missing chunks are fetched
+	for _, start := range server.starts {
+		if start < 16384 {
+			t.Errorf("refetched completed data from offset %d", start)
+		}
+	}
+	if len(server.starts) != 6 {
+		t.Errorf("server got %d requests, want 6 for the missing 23616 bytes", len(server.starts))
+	}
+}
+
+func TestDownloadToFileIgnoresOtherSidecar(t *testing.T) {
+	server := newRangeServer(t, 20_000)
+	format := server.format()
+	h := testHandler(2)
+
+	// A part left by another format must not be trusted, even though its size matches
+	path := filepath.Join(t.TempDir(), "audio.webm")
+	writePart(t, path, bytes.Repeat([]byte{0xEE}, 20_000), 20_000, partState{
+		VideoID: format.VideoID, ITag: 140, ContentLength: 20_000,
+		Completed: []byteRange{{Start: 0, End: 20_000}},
+	})
+
+	if err := h.DownloadToFile(context.Background(), format, path); err != nil {
+		t.Fatal(err)
+	}
+	checkDownloaded(t, path, server.data)
+
+	if len(server.starts) != 5 {
+		t.Errorf("server got %d requests, want 5 for the whole stream", len(server.starts))
+	}
+}
+
+func TestDownloadToFileSizeMismatch(t *testing.T) {
+	server := newRangeServer(t, 20_000)
+	format := server.format()
+	h := testHandler(2)
+
+	// Overlapping ranges in a corrupt sidecar add up to more than the stream
+	path := filepath.Join(t.TempDir(), "audio.webm")
+	writePart(t, path, server.data, 20_000, partState{
+		VideoID: format.VideoID, ITag: format.ITag, ContentLength: 20_000,
+		Completed: []byteRange{{Start: 0, End: 12_000}, {Start: 8_000, End: 20_000}},
+	})
+
+	err := h.DownloadToFile(context.Background(), format, path)
+	if !errors.Is(err, ErrSizeMismatch) {
+		t.Fatalf("got %v, want ErrSizeMismatch", err)
+	}
+	for _, leftover := range []string{path, path + ".part", path + ".part.json"} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("%s was left behind", filepath.Base(leftover))
+		}
+	}
+}
+
+func TestDownloadToFileRefreshesURL(t *testing.T) {
+	server := newRangeServer(t, 30_000)
+	fresh := server.format()
+	h := testHandler(2)
+
+	var resolved atomic.Int32
+	h.Resolver = func(ctx context.Context, format types.Format) (types.Format, error) {
+		resolved.Add(1)
+		return fresh, nil
+	}
+
+	// Resuming with the URL from the first attempt, which has since been rejected
+	stale := fresh
+	stale.URL = server.URL + "/expired"
+
+	path := filepath.Join(t.TempDir(), "audio.webm")
+	writePart(t, path, server.data, 8192, partState{
+		VideoID: fresh.VideoID, ITag: fresh.ITag, ContentLength: 30_000,
+		Completed: []byteRange{{Start: 0, End: 8192}},
+	})
+
+	if err := h.DownloadToFile(context.Background(), stale, path); err != nil {
+		t.Fatal(err)
+	}
+	checkDownloaded(t, path, server.data)
+
+	// Workers that hit the rejected URL together share one refresh
+	if n := resolved.Load(); n != 1 {
+		t.Errorf("resolver called %d times, want 1", n)
+	}
+}
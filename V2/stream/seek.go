@@ -0,0 +1,103 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/elucid503/overture-play/v2/dash"
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+var (
+	// ErrNoIndex is returned when a format has no init or index range to seek with
+	ErrNoIndex = errors.New("format has no segment index")
+
+	// ErrSeekOutOfRange is returned when the seek time is past the end of the stream
+	ErrSeekOutOfRange = errors.New("seek time past end of stream")
+)
+
+// SegmentIndex fetches and parses the format's sidx box (mp4/m4a) or Cues element (webm)
+func (h *Handler) SegmentIndex(ctx context.Context, format types.Format) (*dash.SegmentIndex, error) {
+	_, idx, err := h.loadIndex(ctx, format)
+	return idx, err
+}
+
+// SeekTime returns a stream starting at the segment containing t, with the init segment prepended
+// so it can be decoded on its own. Also returns the start time of that segment, which is at or before t
+func (h *Handler) SeekTime(ctx context.Context, format types.Format, t time.Duration) (io.ReadCloser, time.Duration, error) {
+	init, idx, err := h.loadIndex(ctx, format)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	seg, ok := idx.Find(t)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s of %s", ErrSeekOutOfRange, t, idx.Duration())
+	}
+
+	body, _, err := h.GetStreamRange(ctx, format, seg.Offset, -1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &seekStream{Reader: io.MultiReader(bytes.NewReader(init), body), Closer: body}, seg.Start, nil
+}
+
+// seekStream reads the init segment followed by the stream body
+type seekStream struct {
+	io.Reader
+	io.Closer
+}
+
+// loadIndex fetches the init segment and parses the segment index
+// Init and index ranges are normally adjacent and are then fetched in one request
+func (h *Handler) loadIndex(ctx context.Context, format types.Format) ([]byte, *dash.SegmentIndex, error) {
+	if format.URL == "" {
+		return nil, nil, fmt.Errorf("format has no URL")
+	}
+	if format.InitRange == nil || format.IndexRange == nil {
+		return nil, nil, ErrNoIndex
+	}
+
+	initRange := chunkRange{start: int64(format.InitRange.Start), end: int64(format.InitRange.End)}
+	indexRange := chunkRange{start: int64(format.IndexRange.Start), end: int64(format.IndexRange.End)}
+	ref := newFormatRef(format)
+
+	var init, index []byte
+	if initRange.end+1 == indexRange.start {
+		data, err := h.fetchChunk(ctx, ref, chunkRange{start: initRange.start, end: indexRange.end})
+		if err != nil {
+			return nil, nil, err
+		}
+		split := indexRange.start - initRange.start
+		init, index = data[:split], data[split:]
+	} else {
+		var err error
+		if init, err = h.fetchChunk(ctx, ref, initRange); err != nil {
+			return nil, nil, err
+		}
+		if index, err = h.fetchChunk(ctx, ref, indexRange); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var idx *dash.SegmentIndex
+	var err error
+	if format.Extension() == "webm" {
+		if initRange.start != 0 {
+			return nil, nil, fmt.Errorf("%w: WebM init range does not start at 0", ErrNoIndex)
+		}
+		idx, err = dash.ParseCues(init, index, indexRange.start, int64(format.ContentLength))
+	} else {
+		idx, err = dash.ParseSidx(index, indexRange.start)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return init, idx, nil
+}
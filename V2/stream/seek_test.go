@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/elucid503/overture-play/v2/types"
+)
+
+// fmp4Format lays out the server's data as an fMP4 file: a 32 byte init segment, a version 0 sidx
+// box with timescale 1000 and one reference per segment size, then the segments
+// Every segment lasts two seconds and the first starts at 500ms
+func fmp4Format(server *rangeServer, sizes ...int) types.Format {
+	body := []byte{0, 0, 0, 0}
+	body = binary.BigEndian.AppendUint32(body, 1)    // reference_ID
+	body = binary.BigEndian.AppendUint32(body, 1000) // timescale
+	body = binary.BigEndian.AppendUint32(body, 500)  // earliest_presentation_time
+	body = binary.BigEndian.AppendUint32(body, 0)    // first_offset
+	body = binary.BigEndian.AppendUint16(body, 0)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(sizes)))
+	for _, size := range sizes {
+		body = binary.BigEndian.AppendUint32(body, uint32(size))
+		body = binary.BigEndian.AppendUint32(body, 2000)
+		body = binary.BigEndian.AppendUint32(body, 0x90000000)
+	}
+
+	sidx := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	sidx = append(append(sidx, "sidx"...), body...)
+	copy(server.data[32:], sidx)
+
+	format := server.format()
+	format.MimeType = `audio/mp4; codecs="mp4a.40.2"`
+	format.InitRange = &types.Range{Start: 0, End: 31}
+	format.IndexRange = &types.Range{Start: 32, End: 32 + len(sidx) - 1}
+	return format
+}
+
+func TestSeekTime(t *testing.T) {
+	server := newRangeServer(t, 32+68+1000+1500+800)
+	format := fmp4Format(server, 1000, 1500, 800)
+	h := testHandler(1)
+
+	first := format.IndexRange.End + 1
+	tests := []struct {
+		name   string
+		t      time.Duration
+		start  time.Duration
+		offset int
+	}{
+		{"before first reference", 200 * time.Millisecond, 500 * time.Millisecond, first},
+		{"at first reference", 500 * time.Millisecond, 500 * time.Millisecond, first},
+		{"between references", 3 * time.Second, 2500 * time.Millisecond, first + 1000},
+		{"last reference", 6 * time.Second, 4500 * time.Millisecond, first + 2500},
+	}
+
+	for _, tt := range tests {
+		stream, start, err := h.SeekTime(context.Background(), format, tt.t)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := io.ReadAll(stream)
+		stream.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if start != tt.start {
+			t.Errorf("%s: segment starts at %v, want %v", tt.name, start, tt.start)
+		}
+		want := append(append([]byte(nil), server.data[:32]...), server.data[tt.offset:]...)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: got %d bytes, want the init segment and %d bytes from offset %d", tt.name, len(got), len(server.data)-tt.offset, tt.offset)
+		}
+	}
+}
+
+func TestSeekTimePastEnd(t *testing.T) {
+	server := newRangeServer(t, 32+68+1000+1500+800)
+	format := fmp4Format(server, 1000, 1500, 800)
+
+	_, _, err := testHandler(1).SeekTime(context.Background(), format, 6500*time.Millisecond)
+	if !errors.Is(err, ErrSeekOutOfRange) {
+		t.Errorf("got %v, want ErrSeekOutOfRange", err)
+	}
+}
+
+func TestSeekTimeNoIndex(t *testing.T) {
+	server := newRangeServer(t, 1000)
+
+	_, _, err := testHandler(1).SeekTime(context.Background(), server.format(), time.Second)
+	if !errors.Is(err, ErrNoIndex) {
+		t.Errorf("got %v, want ErrNoIndex", err)
+	}
+}
@@ -0,0 +1,118 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CaptionKind distinguishes uploaded captions from automatic speech recognition
+type CaptionKind string
+
+const (
+	CaptionKindManual CaptionKind = "manual"
+	CaptionKindASR    CaptionKind = "asr"
+)
+
+// CaptionTrack describes a caption track available for a video
+type CaptionTrack struct {
+	LanguageCode   string
+	Name           string
+	Kind           CaptionKind
+	IsTranslatable bool
+
+	// Internal: timed text URL and track identifier
+	BaseURL string
+	VssID   string
+
+	// Client that provided this track, and that client's subs PO token policy
+	ClientName        string
+	SubsPoTokenPolicy PoTokenPolicy
+}
+
+// IsAutoGenerated returns true if the track was produced by speech recognition
+func (t *CaptionTrack) IsAutoGenerated() bool {
+	return t.Kind == CaptionKindASR
+}
+
+// CaptionLanguage is a language caption tracks can be translated into
+type CaptionLanguage struct {
+	Code string
+	Name string
+}
+
+// CaptionFormat is the timed text format requested from YouTube
+type CaptionFormat string
+
+const (
+	CaptionFormatJSON3 CaptionFormat = "json3"
+	CaptionFormatSRV3  CaptionFormat = "srv3"
+)
+
+// CaptionCue is a single timed caption
+type CaptionCue struct {
+	Start    time.Duration
+	Duration time.Duration
+	Text     string
+}
+
+// End returns when the cue stops being displayed
+func (c CaptionCue) End() time.Duration {
+	return c.Start + c.Duration
+}
+
+// Transcript holds the cues of a downloaded caption track
+type Transcript struct {
+	Track CaptionTrack
+	// Language the cues were translated into, empty if untranslated
+	TranslatedTo string
+
+	Cues []CaptionCue
+}
+
+// SRT renders the cues as SubRip
+func (t *Transcript) SRT() string {
+	var b strings.Builder
+	for i, cue := range t.Cues {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(cue.Start, ','), formatTimestamp(cue.End(), ','), compactLines(cue.Text))
+	}
+	return b.String()
+}
+
+// WebVTT renders the cues as WebVTT
+func (t *Transcript) WebVTT() string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, cue := range t.Cues {
+		// "-->" inside a cue would be read as a timing line
+		text := strings.ReplaceAll(compactLines(cue.Text), "-->", "->")
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", formatTimestamp(cue.Start, '.'), formatTimestamp(cue.End(), '.'), text)
+	}
+	return b.String()
+}
+
+// Text renders the cues as plain text, one cue per line
+func (t *Transcript) Text() string {
+	lines := make([]string, 0, len(t.Cues))
+	for _, cue := range t.Cues {
+		lines = append(lines, strings.ReplaceAll(cue.Text, "\n", " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// compactLines removes blank lines, which would end a cue early in SRT and WebVTT
+func compactLines(text string) string {
+	for strings.Contains(text, "\n\n") {
+		text = strings.ReplaceAll(text, "\n\n", "\n")
+	}
+	return text
+}
+
+// formatTimestamp formats a duration as HH:MM:SS followed by sep and milliseconds
+func formatTimestamp(d time.Duration, sep byte) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
@@ -0,0 +1,70 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func testTranscript() *Transcript {
+	return &Transcript{Cues: []CaptionCue{
+		{Start: 1200 * time.Millisecond, Duration: 2800 * time.Millisecond, Text: "we're no strangers"},
+		{Start: 10 * time.Second, Duration: 2500 * time.Millisecond, Text: "you know the rules\n\n\nand so do I"},
+		{Start: time.Hour + 2*time.Minute + 3*time.Second, Duration: 1500 * time.Millisecond, Text: "A --> B"},
+	}}
+}
+
+func TestTranscriptSRT(t *testing.T) {
+	want := "1\n00:00:01,200 --> 00:00:04,000\nwe're no strangers\n\n" +
+		"2\n00:00:10,000 --> 00:00:12,500\nyou know the rules\nand so do I\n\n" +
+		"3\n01:02:03,000 --> 01:02:04,500\nA --> B\n\n"
+
+	if got := testTranscript().SRT(); got != want {
+		t.Errorf("SRT:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTranscriptWebVTT(t *testing.T) {
+	want := "WEBVTT\n\n" +
+		"00:00:01.200 --> 00:00:04.000\nwe're no strangers\n\n" +
+		"00:00:10.000 --> 00:00:12.500\nyou know the rules\nand so do I\n\n" +
+		"01:02:03.000 --> 01:02:04.500\nA -> B\n\n"
+
+	if got := testTranscript().WebVTT(); got != want {
+		t.Errorf("WebVTT:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTranscriptText(t *testing.T) {
+	want := "we're no strangers\nyou know the rules   and so do I\nA --> B"
+
+	if got := testTranscript().Text(); got != want {
+		t.Errorf("Text:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestTranscriptEmpty(t *testing.T) {
+	empty := &Transcript{}
+	if empty.SRT() != "" || empty.WebVTT() != "WEBVTT\n\n" || empty.Text() != "" {
+		t.Errorf("empty transcript rendered as %q, %q, %q", empty.SRT(), empty.WebVTT(), empty.Text())
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		sep  byte
+		want string
+	}{
+		{0, ',', "00:00:00,000"},
+		{-time.Second, '.', "00:00:00.000"},
+		{999 * time.Millisecond, '.', "00:00:00.999"},
+		{59*time.Minute + 59*time.Second + 1500*time.Microsecond, ',', "00:59:59,001"},
+		{100 * time.Hour, '.', "100:00:00.000"},
+	}
+
+	for _, tt := range tests {
+		if got := formatTimestamp(tt.d, tt.sep); got != tt.want {
+			t.Errorf("formatTimestamp(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
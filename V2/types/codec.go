@@ -0,0 +1,101 @@
+package types
+
+import (
+	"strings"
+)
+
+// ParseMimeType splits a mimeType such as `video/mp4; codecs="avc1.640028, mp4a.40.2"`
+// into its media type and codec list
+func ParseMimeType(mimeType string) (string, []string) {
+	parts := strings.Split(mimeType, ";")
+	mediaType := strings.TrimSpace(parts[0])
+
+	var codecs []string
+	for _, param := range parts[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "codecs") {
+			continue
+		}
+
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		for _, codec := range strings.Split(value, ",") {
+			if codec = strings.TrimSpace(codec); codec != "" {
+				codecs = append(codecs, codec)
+			}
+		}
+	}
+
+	return mediaType, codecs
+}
+
+// CodecFamily returns the normalized family of a codec identifier
+// e.g. "avc1.640028" -> "avc1", "vp09.00.51.08" -> "vp9", "av01.0.08M.08" -> "av01", "mp4a.40.2" -> "mp4a"
+func CodecFamily(codec string) string {
+	family, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(codec)), ".")
+
+	switch family {
+	case "avc1", "avc3", "h264":
+		return "avc1"
+	case "hev1", "hvc1", "h265":
+		return "hevc"
+	case "vp9", "vp09":
+		return "vp9"
+	case "vp8", "vp08":
+		return "vp8"
+	case "ac-3", "ac3":
+		return "ac-3"
+	case "ec-3", "ec3":
+		return "ec-3"
+	default:
+		return family
+	}
+}
+
+// IsAudioCodec reports whether a codec identifier names an audio codec
+func IsAudioCodec(codec string) bool {
+	switch CodecFamily(codec) {
+	case "mp4a", "opus", "vorbis", "ac-3", "ec-3", "flac", "mp3", "alac":
+		return true
+	}
+	return false
+}
+
+// splitCodecs assigns a mimeType's codecs to video and audio
+func splitCodecs(mediaType string, codecs []string) (video, audio string) {
+	for _, codec := range codecs {
+		switch {
+		case strings.HasPrefix(mediaType, "audio/") || IsAudioCodec(codec):
+			if audio == "" {
+				audio = codec
+			}
+		default:
+			if video == "" {
+				video = codec
+			}
+		}
+	}
+	return video, audio
+}
+
+// SetCodecsFromMimeType fills Codec, VideoCodec and AudioCodec from the format's mimeType
+func (f *Format) SetCodecsFromMimeType() {
+	mediaType, codecs := ParseMimeType(f.MimeType)
+	f.Codec = strings.Join(codecs, ", ")
+	f.VideoCodec, f.AudioCodec = splitCodecs(mediaType, codecs)
+}
+
+// VideoCodecFamily returns the normalized video codec family, e.g. "vp9"
+func (f *Format) VideoCodecFamily() string {
+	if f.VideoCodec == "" {
+		return ""
+	}
+	return CodecFamily(f.VideoCodec)
+}
+
+// AudioCodecFamily returns the normalized audio codec family, e.g. "opus"
+func (f *Format) AudioCodecFamily() string {
+	if f.AudioCodec == "" {
+		return ""
+	}
+	return CodecFamily(f.AudioCodec)
+}
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Format represents a video/audio format available for streaming
 type Format struct {
 	ITag         int
+	VideoID      string
 	URL          string
 	MimeType     string
 	Quality      string
@@ -26,16 +28,33 @@ type Format struct {
 	AudioChannels  int
 	AudioSampleRate int
 
+	// Codecs from the mimeType, e.g. "avc1.640028, mp4a.40.2", and the video and audio codec among them
 	Codec      string
 	VideoCodec string
 	AudioCodec string
 
+	// Approximate stream duration and when the stream was last modified
+	ApproxDuration time.Duration
+	LastModified   time.Time
+
+	// Video presentation
+	Projection Projection
+	ColorInfo  *ColorInfo
+
+	// Audio normalization in dB relative to YouTube's target loudness; nil if not reported
+	LoudnessDB *float64
+	// Audio track of a multi-audio video; nil for single-track videos
+	AudioTrack *AudioTrack
+	// Whether the audio is dynamic range compressed
+	IsDRC bool
+
 	// For DASH/HLS formats
 	IndexRange  *Range
 	InitRange   *Range
 
-	// Whether this format has DRM protection
-	HasDRM bool
+	// Whether this format has DRM protection, and the DRM systems it uses
+	HasDRM      bool
+	DRMFamilies []string
 
 	// Internal: signature cipher data if URL needs deciphering
 	SignatureCipher string
@@ -47,6 +66,54 @@ type Format struct {
 
 	// Client that provided this format
 	ClientName string
+
+	// When the stream URL stops working, from its expire parameter or the response's expiresInSeconds
+	// Zero if unknown
+	ExpiresAt time.Time
+
+	// Protocol the format is streamed over
+	Protocol StreamingProtocol
+	// Set when the client's policy requires a GVS PO token that could not be obtained; the URL will likely 403
+	MissingPoToken bool
+}
+
+// Projection describes how video frames map onto the display
+type Projection string
+
+const (
+	ProjectionRectangular     Projection = "RECTANGULAR"
+	ProjectionEquirectangular Projection = "EQUIRECTANGULAR"
+	ProjectionStereo3D        Projection = "EQUIRECTANGULAR_THREED_TOP_BOTTOM"
+	ProjectionMesh            Projection = "MESH"
+)
+
+// ColorInfo describes the color space of a video format
+type ColorInfo struct {
+	Primaries               string
+	TransferCharacteristics string
+	MatrixCoefficients      string
+}
+
+// IsHDR reports whether the transfer characteristics are PQ or HLG
+func (c *ColorInfo) IsHDR() bool {
+	if c == nil {
+		return false
+	}
+	switch c.TransferCharacteristics {
+	case "COLOR_TRANSFER_CHARACTERISTICS_SMPTEST2084", "COLOR_TRANSFER_CHARACTERISTICS_ARIB_STD_B67":
+		return true
+	}
+	return false
+}
+
+// AudioTrack identifies one audio track of a multi-audio video
+type AudioTrack struct {
+	// Track ID, e.g. "en.4"
+	ID string
+	// Language code from the track ID, e.g. "en"
+	Language    string
+	DisplayName string
+	IsDefault   bool
 }
 
 // Range represents a byte range (used for DASH initialization/index)
@@ -75,11 +142,31 @@ func (f *Format) IsVideoOnly() bool {
 	return f.HasVideo() && !f.HasAudio()
 }
 
+// IsExpired reports whether the stream URL has passed its expiry time
+func (f *Format) IsExpired() bool {
+	return f.ExpiresWithin(0)
+}
+
+// ExpiresWithin reports whether the stream URL expires within d; false if the expiry is unknown
+func (f *Format) ExpiresWithin(d time.Duration) bool {
+	return !f.ExpiresAt.IsZero() && time.Until(f.ExpiresAt) <= d
+}
+
 // IsAdaptive returns true if this is an adaptive (separate audio/video) format
 func (f *Format) IsAdaptive() bool {
 	return f.IsAudioOnly() || f.IsVideoOnly()
 }
 
+// IsHDR returns true if this format is HDR video
+func (f *Format) IsHDR() bool {
+	return f.ColorInfo.IsHDR()
+}
+
+// IsSpherical returns true if this format is 360 or VR video
+func (f *Format) IsSpherical() bool {
+	return f.Projection != "" && f.Projection != ProjectionRectangular
+}
+
 // SupportsRange returns true if this format supports HTTP range requests
 func (f *Format) SupportsRange() bool {
 	return f.ContentLength > 0
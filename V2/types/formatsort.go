@@ -0,0 +1,416 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFormatSort ranks formats by resolution, frame rate, codec, channels, bitrate, sample rate and size
+const DefaultFormatSort = "res,fps,codec,channels,br,asr,size"
+
+// fieldValue is the value of a format field as seen by filters and sort keys
+type fieldValue struct {
+	num     float64
+	str     string
+	numeric bool
+	known   bool
+
+	// A known 0 is a real value, such as 0 dB loudness, rather than an unset field
+	zeroValid bool
+}
+
+// isSet reports whether the field has a non-empty, non-zero value
+func (v fieldValue) isSet() bool {
+	if !v.known {
+		return false
+	}
+	if v.numeric {
+		return v.num != 0 || v.zeroValid
+	}
+	return v.str != "" && v.str != "none"
+}
+
+// numValue is a numeric field where 0 means unknown
+func numValue(n float64) fieldValue {
+	return fieldValue{num: n, str: strconv.FormatFloat(n, 'f', -1, 64), numeric: true, known: n != 0}
+}
+
+// optNumValue is a numeric field where 0 is a real value, known only if present
+func optNumValue(n *float64) fieldValue {
+	if n == nil {
+		return fieldValue{numeric: true, zeroValid: true}
+	}
+	v := numValue(*n)
+	v.known = true
+	v.zeroValid = true
+	return v
+}
+
+func strValue(s string) fieldValue {
+	return fieldValue{str: s, known: s != ""}
+}
+
+func boolValue(b bool) fieldValue {
+	v := fieldValue{str: strconv.FormatBool(b), numeric: true, known: true}
+	if b {
+		v.num = 1
+	}
+	return v
+}
+
+// namedFields maps yt-dlp style field names to format values
+var namedFields = map[string]func(f *Format) fieldValue{
+	"height": func(f *Format) fieldValue { return numValue(float64(f.Height)) },
+	"width":  func(f *Format) fieldValue { return numValue(float64(f.Width)) },
+	"res":    func(f *Format) fieldValue { return numValue(float64(resolution(f))) },
+	"fps":    func(f *Format) fieldValue { return numValue(float64(f.FPS)) },
+
+	"tbr": func(f *Format) fieldValue { return numValue(kbps(f)) },
+	"br":  func(f *Format) fieldValue { return numValue(kbps(f)) },
+	"vbr": func(f *Format) fieldValue {
+		if !f.IsVideoOnly() {
+			return fieldValue{numeric: true}
+		}
+		return numValue(kbps(f))
+	},
+	"abr": func(f *Format) fieldValue {
+		if !f.IsAudioOnly() {
+			return fieldValue{numeric: true}
+		}
+		return numValue(kbps(f))
+	},
+
+	"asr":            func(f *Format) fieldValue { return numValue(float64(f.AudioSampleRate)) },
+	"channels":       func(f *Format) fieldValue { return numValue(float64(f.AudioChannels)) },
+	"audio_channels": func(f *Format) fieldValue { return numValue(float64(f.AudioChannels)) },
+	"filesize":       func(f *Format) fieldValue { return numValue(float64(f.ContentLength)) },
+	"size":           func(f *Format) fieldValue { return numValue(float64(f.ContentLength)) },
+	"duration":       func(f *Format) fieldValue { return numValue(f.ApproxDuration.Seconds()) },
+	"loudness":       func(f *Format) fieldValue { return optNumValue(f.LoudnessDB) },
+	"itag":           func(f *Format) fieldValue { return numValue(float64(f.ITag)) },
+	"format_id":      func(f *Format) fieldValue { return numValue(float64(f.ITag)) },
+
+	"ext":   func(f *Format) fieldValue { return strValue(f.Extension()) },
+	"codec": func(f *Format) fieldValue { return strValue(f.Codec) },
+	"vcodec": func(f *Format) fieldValue {
+		if !f.HasVideo() {
+			return strValue("none")
+		}
+		return strValue(f.VideoCodec)
+	},
+	"acodec": func(f *Format) fieldValue {
+		if !f.HasAudio() {
+			return strValue("none")
+		}
+		return strValue(f.AudioCodec)
+	},
+	"mime_type": func(f *Format) fieldValue {
+		mediaType, _ := ParseMimeType(f.MimeType)
+		return strValue(mediaType)
+	},
+	"protocol":      func(f *Format) fieldValue { return strValue(string(f.Protocol)) },
+	"proto":         func(f *Format) fieldValue { return strValue(string(f.Protocol)) },
+	"client":        func(f *Format) fieldValue { return strValue(f.ClientName) },
+	"quality":       func(f *Format) fieldValue { return strValue(f.Quality) },
+	"format_note":   func(f *Format) fieldValue { return strValue(f.QualityLabel) },
+	"quality_label": func(f *Format) fieldValue { return strValue(f.QualityLabel) },
+	"audio_quality": func(f *Format) fieldValue { return strValue(f.AudioQuality) },
+	"projection":    func(f *Format) fieldValue { return strValue(string(f.Projection)) },
+	"language": func(f *Format) fieldValue {
+		if f.AudioTrack == nil {
+			return strValue("")
+		}
+		return strValue(f.AudioTrack.Language)
+	},
+	"audio_track": func(f *Format) fieldValue {
+		if f.AudioTrack == nil {
+			return strValue("")
+		}
+		return strValue(f.AudioTrack.ID)
+	},
+	"dynamic_range": func(f *Format) fieldValue {
+		switch {
+		case !f.HasVideo():
+			return strValue("")
+		case f.IsHDR():
+			return strValue("HDR")
+		default:
+			return strValue("SDR")
+		}
+	},
+
+	"hdr":         func(f *Format) fieldValue { return boolValue(f.IsHDR()) },
+	"drm":         func(f *Format) fieldValue { return boolValue(f.HasDRM) },
+	"has_drm":     func(f *Format) fieldValue { return boolValue(f.HasDRM) },
+	"drc":         func(f *Format) fieldValue { return boolValue(f.IsDRC) },
+	"is_drc":      func(f *Format) fieldValue { return boolValue(f.IsDRC) },
+	"missing_pot": func(f *Format) fieldValue { return boolValue(f.MissingPoToken) },
+}
+
+// formatField returns a named field or, failing that, the Format struct field with a matching name
+// Struct fields match case-insensitively with underscores ignored, e.g. "average_bitrate"
+func formatField(f *Format, key string) (fieldValue, bool) {
+	key = strings.ToLower(key)
+	if get, ok := namedFields[key]; ok {
+		return get(f), true
+	}
+
+	name := strings.ReplaceAll(key, "_", "")
+	rv := reflect.ValueOf(f).Elem()
+	field := rv.FieldByNameFunc(func(n string) bool {
+		return strings.ToLower(n) == name
+	})
+	if !field.IsValid() {
+		return fieldValue{}, false
+	}
+
+	switch v := field.Interface().(type) {
+	case *float64:
+		return optNumValue(v), true
+	case time.Duration:
+		return numValue(v.Seconds()), true
+	case time.Time:
+		if v.IsZero() {
+			return fieldValue{numeric: true}, true
+		}
+		return numValue(float64(v.Unix())), true
+	}
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return numValue(float64(field.Int())), true
+	case reflect.Float32, reflect.Float64:
+		return numValue(field.Float()), true
+	case reflect.Bool:
+		return boolValue(field.Bool()), true
+	case reflect.String:
+		return strValue(field.String()), true
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fieldValue{}, false
+		}
+		return strValue(strings.Join(field.Interface().([]string), ",")), true
+	default:
+		return fieldValue{}, false
+	}
+}
+
+// isFormatField reports whether key names a field filters can use
+func isFormatField(key string) bool {
+	_, ok := formatField(&Format{}, key)
+	return ok
+}
+
+// isNumericField reports whether key names a numeric field
+func isNumericField(key string) bool {
+	v, ok := formatField(&Format{}, key)
+	return ok && v.numeric
+}
+
+// resolution returns the smaller frame dimension, so portrait and landscape compare alike
+func resolution(f *Format) int {
+	if f.Width > 0 && f.Height > 0 && f.Width < f.Height {
+		return f.Width
+	}
+	return f.Height
+}
+
+// kbps returns the format's average bitrate in kbit/s, falling back to its peak bitrate
+func kbps(f *Format) float64 {
+	if f.AverageBitrate > 0 {
+		return float64(f.AverageBitrate) / 1000
+	}
+	return float64(f.Bitrate) / 1000
+}
+
+// Codec preference, higher is better
+var (
+	videoCodecRank = map[string]int{"av01": 6, "vp9": 5, "hevc": 4, "avc1": 3, "vp8": 2}
+	audioCodecRank = map[string]int{"flac": 8, "alac": 8, "opus": 6, "vorbis": 5, "mp4a": 4, "mp3": 3, "ec-3": 2, "ac-3": 1}
+)
+
+// FormatSort ranks formats; earlier keys take precedence
+type FormatSort []SortKey
+
+// SortKey is one ranking criterion
+type SortKey struct {
+	Field string
+	// Prefer smaller values instead of larger ones
+	Ascending bool
+	// Prefer values up to Limit (or from Limit when Ascending), e.g. res:1080
+	Limit    float64
+	HasLimit bool
+}
+
+// Sort keys and how each values a format
+var sortFields = map[string]func(f *Format) fieldValue{
+	"res":      namedFields["res"],
+	"height":   namedFields["height"],
+	"width":    namedFields["width"],
+	"fps":      namedFields["fps"],
+	"hdr":      namedFields["hdr"],
+	"br":       namedFields["br"],
+	"tbr":      namedFields["tbr"],
+	"vbr":      namedFields["vbr"],
+	"abr":      namedFields["abr"],
+	"asr":      namedFields["asr"],
+	"channels": namedFields["channels"],
+	"size":     namedFields["size"],
+	"filesize": namedFields["filesize"],
+	"quality": func(f *Format) fieldValue {
+		rank := QualityRank(f.Quality)
+		if f.IsAudioOnly() {
+			rank = QualityRank(f.AudioQuality)
+		}
+		return fieldValue{num: float64(rank), numeric: true, known: rank >= 0}
+	},
+	"vcodec": func(f *Format) fieldValue {
+		rank, ok := videoCodecRank[f.VideoCodecFamily()]
+		if !ok && f.VideoCodec != "" {
+			rank = 1
+		}
+		return fieldValue{num: float64(rank), numeric: true, known: rank > 0}
+	},
+	"acodec": func(f *Format) fieldValue {
+		rank, ok := audioCodecRank[f.AudioCodecFamily()]
+		if !ok && f.AudioCodec != "" {
+			rank = 1
+		}
+		return fieldValue{num: float64(rank), numeric: true, known: rank > 0}
+	},
+	"lang": func(f *Format) fieldValue {
+		return boolValue(f.AudioTrack == nil || f.AudioTrack.IsDefault)
+	},
+}
+
+// ParseFormatSort parses a comma-separated list of sort keys
+// Each key may be prefixed with + to prefer smaller values and suffixed with :limit,
+// e.g. "res:1080,fps,+size"; "codec" expands to vcodec,acodec and codec limits name a codec, e.g. vcodec:vp9
+func ParseFormatSort(s string) (FormatSort, error) {
+	var keys FormatSort
+
+	pos := 0
+	for _, part := range strings.Split(s, ",") {
+		start := pos
+		pos += len(part) + 1
+
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, &FormatExprError{Expr: s, Pos: start, Msg: "empty sort key"}
+		}
+
+		var key SortKey
+		if strings.HasPrefix(part, "+") {
+			key.Ascending = true
+			part = part[1:]
+		}
+
+		name, limit, hasLimit := strings.Cut(part, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		fields := []string{name}
+		if name == "codec" {
+			fields = []string{"vcodec", "acodec"}
+		}
+
+		for _, field := range fields {
+			if _, ok := sortFields[field]; !ok {
+				return nil, &FormatExprError{Expr: s, Pos: start, Msg: fmt.Sprintf("unknown sort key %q", name)}
+			}
+
+			k := key
+			k.Field = field
+			if hasLimit {
+				l, ok := sortLimit(field, strings.TrimSpace(limit))
+				if !ok {
+					return nil, &FormatExprError{Expr: s, Pos: start, Msg: fmt.Sprintf("invalid limit %q for %q", limit, name)}
+				}
+				k.Limit, k.HasLimit = l, true
+			}
+			keys = append(keys, k)
+		}
+	}
+
+	return keys, nil
+}
+
+// sortLimit parses a key's limit; codec keys take a codec name and use its rank
+func sortLimit(field, limit string) (float64, bool) {
+	switch field {
+	case "vcodec":
+		rank, ok := videoCodecRank[CodecFamily(limit)]
+		return float64(rank), ok
+	case "acodec":
+		rank, ok := audioCodecRank[CodecFamily(limit)]
+		return float64(rank), ok
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(limit), "p"), 64)
+	return n, err == nil
+}
+
+// less reports whether a ranks above b
+func (s FormatSort) less(a, b *Format) bool {
+	for _, key := range s {
+		if c := key.compare(a, b); c != 0 {
+			return c > 0
+		}
+	}
+	return false
+}
+
+// compare returns 1 if a ranks above b for this key, -1 if below and 0 if equal
+// Unknown values always rank below known ones
+func (k SortKey) compare(a, b *Format) int {
+	get := sortFields[k.Field]
+	va, vb := get(a), get(b)
+
+	switch {
+	case va.known && !vb.known:
+		return 1
+	case !va.known && vb.known:
+		return -1
+	case !va.known && !vb.known:
+		return 0
+	}
+
+	ta, sa := k.score(va.num)
+	tb, sb := k.score(vb.num)
+	switch {
+	case ta != tb:
+		if ta > tb {
+			return 1
+		}
+		return -1
+	case sa > sb:
+		return 1
+	case sa < sb:
+		return -1
+	}
+	return 0
+}
+
+// score maps a value to a (tier, score) pair where higher ranks better
+// Values within the limit form the upper tier, ranked by preference; values past it rank closest first
+func (k SortKey) score(v float64) (int, float64) {
+	if !k.HasLimit {
+		if k.Ascending {
+			return 0, -v
+		}
+		return 0, v
+	}
+
+	if k.Ascending {
+		if v >= k.Limit {
+			return 1, -v
+		}
+		return 0, v
+	}
+	if v <= k.Limit {
+		return 1, v
+	}
+	return 0, -v
+}
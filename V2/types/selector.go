@@ -0,0 +1,699 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrFormatNotAvailable is returned when a format expression matches no format
+var ErrFormatNotAvailable = errors.New("requested format not available")
+
+// FormatExprError describes a syntax error in a format expression or sort string
+type FormatExprError struct {
+	Expr string
+	Pos  int
+	Msg  string
+}
+
+// Error returns the message with the offending position
+func (e *FormatExprError) Error() string {
+	return fmt.Sprintf("invalid format expression %q at position %d: %s", e.Expr, e.Pos, e.Msg)
+}
+
+// Selection is the result of a format expression: one format, or a video format and an audio format to merge
+type Selection struct {
+	Formats []Format
+}
+
+// IsMerge returns true if the selection is a video+audio pair
+func (s *Selection) IsMerge() bool {
+	return len(s.Formats) == 2
+}
+
+// Format returns the selected format of a single-format selection, or nil for a merge
+func (s *Selection) Format() *Format {
+	if len(s.Formats) != 1 {
+		return nil
+	}
+	return &s.Formats[0]
+}
+
+// Video returns the format providing video, or nil if the selection has none
+func (s *Selection) Video() *Format {
+	if len(s.Formats) > 0 && s.Formats[0].HasVideo() {
+		return &s.Formats[0]
+	}
+	return nil
+}
+
+// Audio returns the format providing audio, or nil if the selection has none
+func (s *Selection) Audio() *Format {
+	for i := len(s.Formats) - 1; i >= 0; i-- {
+		if s.Formats[i].HasAudio() {
+			return &s.Formats[i]
+		}
+	}
+	return nil
+}
+
+// SelectFormats picks formats from the video with a yt-dlp style expression, ranked by DefaultFormatSort
+//
+// Expressions are made of selectors, optionally followed by [filters]:
+//
+//	best, b          best format with both video and audio
+//	bestvideo, bv    best video-only format; bv* also allows formats with audio
+//	bestaudio, ba    best audio-only format; ba* also allows formats with video
+//	worst, w, wv, wa the same, picking the lowest ranked format
+//	b*, w*           best or worst format of any kind
+//	b.2, bv.3        the nth best (or worst) format
+//	251, mp4         a format by itag, or the best format with an extension; mp4, webm and 3gp need
+//	                 both video and audio, m4a and mp3 need audio
+//
+// Filters compare a field with a value, e.g. [height<=1080], [vcodec^=av01], [acodec=opus], [ext!=webm].
+// Numeric operators are = != < <= > >=; string operators are = ^= $= *= ~= (regex), negated with a
+// leading !, e.g. [vcodec!^=avc1]. A ? after the operator also accepts formats where the field is
+// unknown, e.g. [fps<=?30]. [field] and [!field] test whether a field is set.
+//
+// a+b merges a video format with an audio format, a/b falls back to b if a matches nothing, and
+// parentheses group, e.g. (bv*+ba/b)[height<=720]. Filters after a group apply to the selection it
+// returns: for a merge, audio fields such as acodec are checked against the audio format and all
+// other fields against the video format.
+func SelectFormats(video *Video, expr string) (*Selection, error) {
+	return SelectFormatsSorted(video, expr, "")
+}
+
+// SelectFormatsSorted is SelectFormats with a custom ranking, e.g. "res:1080,fps,codec,br"
+// An empty sort uses DefaultFormatSort
+func SelectFormatsSorted(video *Video, expr, sortKeys string) (*Selection, error) {
+	e, err := ParseFormatExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if sortKeys == "" {
+		sortKeys = DefaultFormatSort
+	}
+	s, err := ParseFormatSort(sortKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.Select(video.Formats, s)
+}
+
+// FormatExpr is a parsed format expression
+type FormatExpr struct {
+	expr string
+	root selNode
+}
+
+// ParseFormatExpr parses a format expression; see SelectFormats for the syntax
+func ParseFormatExpr(expr string) (*FormatExpr, error) {
+	p := &exprParser{expr: expr}
+
+	p.skipSpace()
+	if p.pos >= len(p.expr) {
+		return nil, p.errorf("empty expression")
+	}
+
+	root, err := p.parseAlt()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.expr) {
+		if p.expr[p.pos] == ',' {
+			return nil, p.errorf("selecting several formats with ',' is not supported")
+		}
+		return nil, p.errorf("unexpected %q", p.expr[p.pos])
+	}
+
+	return &FormatExpr{expr: expr, root: root}, nil
+}
+
+// String returns the source expression
+func (e *FormatExpr) String() string {
+	return e.expr
+}
+
+// Select evaluates the expression against formats ranked by s
+func (e *FormatExpr) Select(formats []Format, s FormatSort) (*Selection, error) {
+	ranked := make([]Format, len(formats))
+	copy(ranked, formats)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return s.less(&ranked[i], &ranked[j])
+	})
+
+	sel := e.root.eval(ranked, nil)
+	if sel == nil {
+		return nil, fmt.Errorf("%w: %s", ErrFormatNotAvailable, e.expr)
+	}
+	return sel, nil
+}
+
+// selNode is a node of a parsed expression; eval receives candidate formats ranked best first
+// and the filters from enclosing groups, which the selection it returns must pass
+type selNode interface {
+	eval(formats []Format, filters []formatFilter) *Selection
+}
+
+// altNode tries each option in order
+type altNode struct {
+	options []selNode
+}
+
+func (n *altNode) eval(formats []Format, filters []formatFilter) *Selection {
+	for _, option := range n.options {
+		if sel := option.eval(formats, filters); sel != nil {
+			return sel
+		}
+	}
+	return nil
+}
+
+// mergeNode pairs a video format with an audio format
+// Filters on audio fields apply to the audio format and all others to the video format,
+// so (bv+ba)[height<=720] limits the video without rejecting every audio format
+type mergeNode struct {
+	video selNode
+	audio selNode
+}
+
+func (n *mergeNode) eval(formats []Format, filters []formatFilter) *Selection {
+	var videoFilters, audioFilters []formatFilter
+	for _, f := range filters {
+		if f.isAudio() {
+			audioFilters = append(audioFilters, f)
+		} else {
+			videoFilters = append(videoFilters, f)
+		}
+	}
+
+	v := n.video.eval(formats, videoFilters)
+	if v == nil || v.IsMerge() || !v.Formats[0].HasVideo() {
+		return nil
+	}
+
+	a := n.audio.eval(formats, audioFilters)
+	if a == nil || a.IsMerge() || !a.Formats[0].HasAudio() {
+		return nil
+	}
+
+	return &Selection{Formats: []Format{v.Formats[0], a.Formats[0]}}
+}
+
+// filterNode adds its filters to those its inner node's selection must pass
+type filterNode struct {
+	inner   selNode
+	filters []formatFilter
+}
+
+func (n *filterNode) eval(formats []Format, filters []formatFilter) *Selection {
+	combined := make([]formatFilter, 0, len(filters)+len(n.filters))
+	combined = append(combined, filters...)
+	combined = append(combined, n.filters...)
+	return n.inner.eval(formats, combined)
+}
+
+// pickKind restricts which formats a selector considers
+type pickKind int
+
+const (
+	pickCombined  pickKind = iota // video and audio
+	pickAny                       // anything
+	pickVideoOnly                 // video without audio
+	pickVideo                     // video, with or without audio
+	pickAudioOnly                 // audio without video
+	pickAudio                     // audio, with or without video
+	pickITag                      // a specific itag
+	pickExt                       // a specific extension
+)
+
+// pickNode selects the nth best or worst candidate of a kind
+type pickNode struct {
+	kind  pickKind
+	worst bool
+	n     int
+	itag  int
+	ext   string
+}
+
+func (n *pickNode) eval(formats []Format, filters []formatFilter) *Selection {
+	var candidates []Format
+	for i := range formats {
+		if n.accepts(&formats[i]) && matchAll(filters, &formats[i]) {
+			candidates = append(candidates, formats[i])
+		}
+	}
+
+	if n.n > len(candidates) {
+		return nil
+	}
+
+	idx := n.n - 1
+	if n.worst {
+		idx = len(candidates) - n.n
+	}
+	return &Selection{Formats: []Format{candidates[idx]}}
+}
+
+// accepts reports whether a format is of the node's kind
+func (n *pickNode) accepts(f *Format) bool {
+	switch n.kind {
+	case pickCombined:
+		return f.HasVideo() && f.HasAudio()
+	case pickVideoOnly:
+		return f.IsVideoOnly()
+	case pickVideo:
+		return f.HasVideo()
+	case pickAudioOnly:
+		return f.IsAudioOnly()
+	case pickAudio:
+		return f.HasAudio()
+	case pickITag:
+		return f.ITag == n.itag
+	case pickExt:
+		if f.Extension() != n.ext {
+			return false
+		}
+		if extSelectors[n.ext] == pickAudio {
+			return f.HasAudio()
+		}
+		return f.HasVideo() && f.HasAudio()
+	default:
+		return true
+	}
+}
+
+// Named selectors and the kind and direction they pick
+var namedSelectors = map[string]pickNode{
+	"best": {kind: pickCombined}, "b": {kind: pickCombined},
+	"worst": {kind: pickCombined, worst: true}, "w": {kind: pickCombined, worst: true},
+	"best*": {kind: pickAny}, "b*": {kind: pickAny},
+	"worst*": {kind: pickAny, worst: true}, "w*": {kind: pickAny, worst: true},
+	"bestvideo": {kind: pickVideoOnly}, "bv": {kind: pickVideoOnly},
+	"worstvideo": {kind: pickVideoOnly, worst: true}, "wv": {kind: pickVideoOnly, worst: true},
+	"bestvideo*": {kind: pickVideo}, "bv*": {kind: pickVideo},
+	"worstvideo*": {kind: pickVideo, worst: true}, "wv*": {kind: pickVideo, worst: true},
+	"bestaudio": {kind: pickAudioOnly}, "ba": {kind: pickAudioOnly},
+	"worstaudio": {kind: pickAudioOnly, worst: true}, "wa": {kind: pickAudioOnly, worst: true},
+	"bestaudio*": {kind: pickAudio}, "ba*": {kind: pickAudio},
+	"worstaudio*": {kind: pickAudio, worst: true}, "wa*": {kind: pickAudio, worst: true},
+}
+
+// Extensions usable as selectors, with the kind of format each picks
+var extSelectors = map[string]pickKind{
+	"mp4": pickCombined, "webm": pickCombined, "3gp": pickCombined,
+	"m4a": pickAudio, "mp3": pickAudio,
+}
+
+// exprParser is a recursive descent parser over a format expression
+//
+//	alt    = merge { "/" merge }
+//	merge  = atom [ "+" atom ]
+//	atom   = ( name | "(" alt ")" ) { "[" filter "]" }
+type exprParser struct {
+	expr string
+	pos  int
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return &FormatExprError{Expr: p.expr, Pos: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.expr) && (p.expr[p.pos] == ' ' || p.expr[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// peek returns the next non-space byte, or 0 at the end
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.expr) {
+		return 0
+	}
+	return p.expr[p.pos]
+}
+
+func (p *exprParser) parseAlt() (selNode, error) {
+	first, err := p.parseMerge()
+	if err != nil {
+		return nil, err
+	}
+
+	options := []selNode{first}
+	for p.peek() == '/' {
+		p.pos++
+		next, err := p.parseMerge()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, next)
+	}
+
+	if len(options) == 1 {
+		return first, nil
+	}
+	return &altNode{options: options}, nil
+}
+
+func (p *exprParser) parseMerge() (selNode, error) {
+	video, err := p.parseAtom()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek() != '+' {
+		return video, nil
+	}
+	p.pos++
+
+	audio, err := p.parseAtom()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek() == '+' {
+		return nil, p.errorf("merging more than two formats is not supported")
+	}
+
+	return &mergeNode{video: video, audio: audio}, nil
+}
+
+func (p *exprParser) parseAtom() (selNode, error) {
+	var node selNode
+
+	switch ch := p.peek(); {
+	case ch == 0:
+		return nil, p.errorf("expected a format selector")
+
+	case ch == '(':
+		p.pos++
+		inner, err := p.parseAlt()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, p.errorf("expected ')'")
+		}
+		p.pos++
+		node = inner
+
+	case isSelectorChar(ch):
+		pick, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		node = pick
+
+	default:
+		return nil, p.errorf("unexpected %q", ch)
+	}
+
+	var filters []formatFilter
+	for p.pos < len(p.expr) && p.expr[p.pos] == '[' {
+		f, err := p.parseFilter()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+
+	if len(filters) > 0 {
+		node = &filterNode{inner: node, filters: filters}
+	}
+	return node, nil
+}
+
+// parseName parses a named selector, itag or extension, with an optional .n suffix
+func (p *exprParser) parseName() (*pickNode, error) {
+	start := p.pos
+	for p.pos < len(p.expr) && isSelectorChar(p.expr[p.pos]) {
+		p.pos++
+	}
+	name := p.expr[start:p.pos]
+
+	n := 1
+	if dot := strings.LastIndexByte(name, '.'); dot > 0 {
+		idx, err := strconv.Atoi(name[dot+1:])
+		if err != nil || idx < 1 {
+			p.pos = start + dot + 1
+			return nil, p.errorf("invalid format index %q", name[dot+1:])
+		}
+		name, n = name[:dot], idx
+	}
+
+	if pick, ok := namedSelectors[name]; ok {
+		pick.n = n
+		return &pick, nil
+	}
+	if itag, err := strconv.Atoi(name); err == nil {
+		return &pickNode{kind: pickITag, itag: itag, n: n}, nil
+	}
+	if _, ok := extSelectors[name]; ok {
+		return &pickNode{kind: pickExt, ext: name, n: n}, nil
+	}
+
+	p.pos = start
+	return nil, p.errorf("unknown format selector %q", name)
+}
+
+// isSelectorChar reports whether ch can appear in a selector name
+func isSelectorChar(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '*' || ch == '.' || ch == '_'
+}
+
+var (
+	// [key op value] with a numeric operator
+	numericFilterRegex = regexp.MustCompile(`^\s*([\w.-]+)\s*(<=|>=|<|>|!=|=)\s*(\?)?\s*([0-9.]+)\s*([kKmMgGtT]i?[bB]?|[bB])?\s*$`)
+	// [key op value] with a string operator, optionally negated
+	stringFilterRegex = regexp.MustCompile(`^\s*([\w.-]+)\s*(!)?\s*(\^=|\$=|\*=|~=|=)\s*(\?)?\s*(.*?)\s*$`)
+	// [key] and [!key]
+	existsFilterRegex = regexp.MustCompile(`^\s*(!)?\s*([\w.-]+)\s*$`)
+)
+
+// parseFilter parses a bracketed filter starting at '['
+func (p *exprParser) parseFilter() (formatFilter, error) {
+	open := p.pos
+	p.pos++
+
+	// Find the closing bracket, skipping quoted values
+	end := -1
+	var quote byte
+	for i := p.pos; i < len(p.expr); i++ {
+		ch := p.expr[i]
+		switch {
+		case quote != 0 && ch == '\\':
+			i++
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == ']':
+			end = i
+		}
+		if end >= 0 {
+			break
+		}
+	}
+	if end < 0 {
+		p.pos = open
+		return formatFilter{}, p.errorf("unterminated filter")
+	}
+
+	body := p.expr[p.pos:end]
+	p.pos = open + 1
+
+	f, msg := parseFilterBody(body)
+	if msg != "" {
+		return formatFilter{}, p.errorf("%s", msg)
+	}
+
+	p.pos = end + 1
+	return f, nil
+}
+
+// parseFilterBody parses the text between brackets, returning an error message if it is invalid
+func parseFilterBody(body string) (formatFilter, string) {
+	if m := numericFilterRegex.FindStringSubmatch(body); m != nil {
+		num, ok := parseFilterNumber(m[4], m[5])
+		if !ok {
+			return formatFilter{}, fmt.Sprintf("invalid number %q", m[4]+m[5])
+		}
+
+		f := formatFilter{key: m[1], op: m[2], allowUnknown: m[3] != "", numeric: true, num: num, value: m[4]}
+		if !isFormatField(f.key) {
+			return formatFilter{}, fmt.Sprintf("unknown format field %q", f.key)
+		}
+
+		// "=" and "!=" against a string field compare text, e.g. [format_note=720p]
+		if (f.op == "=" || f.op == "!=") && m[5] == "" && !isNumericField(f.key) {
+			f.numeric = false
+			if f.op == "!=" {
+				f.op, f.negate = "=", true
+			}
+		}
+		if f.numeric && !isNumericField(f.key) {
+			return formatFilter{}, fmt.Sprintf("field %q is not numeric", f.key)
+		}
+		return f, ""
+	}
+
+	if m := stringFilterRegex.FindStringSubmatch(body); m != nil {
+		f := formatFilter{key: m[1], negate: m[2] != "", op: m[3], allowUnknown: m[4] != "", value: unquote(m[5])}
+		if !isFormatField(f.key) {
+			return formatFilter{}, fmt.Sprintf("unknown format field %q", f.key)
+		}
+		if f.value == "" {
+			return formatFilter{}, fmt.Sprintf("missing value for %q", f.key)
+		}
+
+		if f.op == "~=" {
+			re, err := regexp.Compile(f.value)
+			if err != nil {
+				return formatFilter{}, fmt.Sprintf("invalid regex %q: %v", f.value, err)
+			}
+			f.re = re
+		}
+		return f, ""
+	}
+
+	if m := existsFilterRegex.FindStringSubmatch(body); m != nil {
+		if !isFormatField(m[2]) {
+			return formatFilter{}, fmt.Sprintf("unknown format field %q", m[2])
+		}
+		return formatFilter{key: m[2], negate: m[1] != ""}, ""
+	}
+
+	if strings.TrimSpace(body) == "" {
+		return formatFilter{}, "empty filter"
+	}
+	return formatFilter{}, fmt.Sprintf("invalid filter %q", body)
+}
+
+// parseFilterNumber parses a number with an optional size suffix such as K, MiB or G
+func parseFilterNumber(digits, suffix string) (float64, bool) {
+	num, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, false
+	}
+	if suffix == "" || strings.EqualFold(suffix, "b") {
+		return num, true
+	}
+
+	base := 1000.0
+	if len(suffix) > 1 && (suffix[1] == 'i' || suffix[1] == 'I') {
+		base = 1024
+	}
+
+	exp := strings.IndexByte("kmgt", byte(strings.ToLower(suffix[:1])[0])) + 1
+	for i := 0; i < exp; i++ {
+		num *= base
+	}
+	return num, true
+}
+
+// unquote strips matching quotes and backslash escapes from a filter value
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		inner := value[1 : len(value)-1]
+		var b strings.Builder
+		for i := 0; i < len(inner); i++ {
+			if inner[i] == '\\' && i+1 < len(inner) {
+				i++
+			}
+			b.WriteByte(inner[i])
+		}
+		return b.String()
+	}
+	return value
+}
+
+// formatFilter is one bracketed filter
+type formatFilter struct {
+	key          string
+	op           string
+	negate       bool
+	value        string
+	num          float64
+	numeric      bool
+	allowUnknown bool
+	re           *regexp.Regexp
+}
+
+// audioFields are the fields a filter checks against the audio format of a merge, lowercased without underscores
+var audioFields = map[string]bool{
+	"abr": true, "acodec": true, "audiocodec": true, "asr": true, "audiosamplerate": true,
+	"channels": true, "audiochannels": true, "audioquality": true, "language": true, "audiotrack": true,
+	"loudness": true, "loudnessdb": true, "drc": true, "isdrc": true,
+}
+
+// isAudio reports whether the filter describes the audio of a selection
+func (ff formatFilter) isAudio() bool {
+	return audioFields[strings.ReplaceAll(strings.ToLower(ff.key), "_", "")]
+}
+
+// matchAll reports whether a format passes every filter
+func matchAll(filters []formatFilter, f *Format) bool {
+	for _, ff := range filters {
+		if !ff.match(f) {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether a format passes the filter
+func (ff formatFilter) match(f *Format) bool {
+	v, _ := formatField(f, ff.key)
+
+	// Existence test
+	if ff.op == "" {
+		return v.isSet() != ff.negate
+	}
+
+	if !v.known {
+		return ff.allowUnknown
+	}
+
+	if ff.numeric {
+		switch ff.op {
+		case "<":
+			return v.num < ff.num
+		case "<=":
+			return v.num <= ff.num
+		case ">":
+			return v.num > ff.num
+		case ">=":
+			return v.num >= ff.num
+		case "=":
+			return v.num == ff.num
+		case "!=":
+			return v.num != ff.num
+		}
+		return false
+	}
+
+	var ok bool
+	switch ff.op {
+	case "=":
+		ok = v.str == ff.value
+	case "^=":
+		ok = strings.HasPrefix(v.str, ff.value)
+	case "$=":
+		ok = strings.HasSuffix(v.str, ff.value)
+	case "*=":
+		ok = strings.Contains(v.str, ff.value)
+	case "~=":
+		ok = ff.re.MatchString(v.str)
+	}
+	return ok != ff.negate
+}
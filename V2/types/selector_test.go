@@ -0,0 +1,347 @@
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+// testFormats is a typical set of progressive, video-only and audio-only formats
+func testFormats() []Format {
+	video := func(itag int, mime, codec string, height, bitrate, size int) Format {
+		return Format{
+			ITag: itag, MimeType: mime + `; codecs="` + codec + `"`, Codec: codec, VideoCodec: codec,
+			Width: height * 16 / 9, Height: height, FPS: 30, Bitrate: bitrate, ContentLength: size,
+		}
+	}
+	audio := func(itag int, mime, codec string, rate, bitrate, size int) Format {
+		return Format{
+			ITag: itag, MimeType: mime + `; codecs="` + codec + `"`, Codec: codec, AudioCodec: codec,
+			AudioQuality: "AUDIO_QUALITY_MEDIUM", AudioChannels: 2, AudioSampleRate: rate, Bitrate: bitrate, ContentLength: size,
+		}
+	}
+
+	return []Format{
+		{
+			ITag: 18, MimeType: `video/mp4; codecs="avc1.42001E, mp4a.40.2"`, Codec: "avc1.42001E, mp4a.40.2",
+			VideoCodec: "avc1.42001E", AudioCodec: "mp4a.40.2", Width: 640, Height: 360, FPS: 30,
+			AudioQuality: "AUDIO_QUALITY_LOW", AudioChannels: 2, AudioSampleRate: 44100, Bitrate: 500000, ContentLength: 10000000,
+		},
+		video(136, "video/mp4", "avc1.4d401f", 720, 1500000, 30000000),
+		video(137, "video/mp4", "avc1.640028", 1080, 3000000, 60000000),
+		video(247, "video/webm", "vp9", 720, 1200000, 25000000),
+		video(248, "video/webm", "vp9", 1080, 2500000, 50000000),
+		video(399, "video/mp4", "av01.0.08M.08", 1080, 2000000, 40000000),
+		audio(140, "audio/mp4", "mp4a.40.2", 44100, 128000, 3000000),
+		audio(249, "audio/webm", "opus", 48000, 50000, 1200000),
+		audio(251, "audio/webm", "opus", 48000, 160000, 4000000),
+	}
+}
+
+// itags returns the itags of a selection, video first
+func itags(sel *Selection) []int {
+	var out []int
+	for _, f := range sel.Formats {
+		out = append(out, f.ITag)
+	}
+	return out
+}
+
+func TestSelectFormats(t *testing.T) {
+	video := &Video{Formats: testFormats()}
+
+	tests := []struct {
+		expr string
+		sort string
+		want []int
+	}{
+		// Named selectors and indexes
+		{expr: "b", want: []int{18}},
+		{expr: "best", want: []int{18}},
+		{expr: "w", want: []int{18}},
+		{expr: "bv", want: []int{399}},
+		{expr: "bestvideo", want: []int{399}},
+		{expr: "bv*", want: []int{399}},
+		{expr: "wv", want: []int{136}},
+		{expr: "ba", want: []int{251}},
+		{expr: "wa", want: []int{140}},
+		{expr: "ba*", want: []int{18}},
+		{expr: "b*", want: []int{399}},
+		{expr: "w*", want: []int{140}},
+		{expr: "bv.2", want: []int{248}},
+		{expr: "ba.3", want: []int{140}},
+		{expr: "wv.2", want: []int{247}},
+
+		// Itags and extensions
+		{expr: "136", want: []int{136}},
+		{expr: "mp4", want: []int{18}},
+		{expr: "m4a", want: []int{140}},
+		{expr: "mp4/webm", want: []int{18}},
+
+		// Numeric operators
+		{expr: "bv[height<=720]", want: []int{247}},
+		{expr: "bv[height=720]", want: []int{247}},
+		{expr: "bv[height!=1080]", want: []int{247}},
+		{expr: "bv*[height<720]", want: []int{18}},
+		{expr: "bv[height>720][vcodec^=avc1]", want: []int{137}},
+		{expr: "bv[height>=1080]", want: []int{399}},
+		{expr: "ba[fps<=?30]", want: []int{251}},
+		{expr: "ba[asr<48000]", want: []int{140}},
+
+		// Size suffixes
+		{expr: "ba[filesize<2M]", want: []int{249}},
+		{expr: "ba[filesize>3.5MiB]", want: []int{251}},
+		{expr: "bv[filesize<28MB]", want: []int{247}},
+		{expr: "bv[filesize<=30000k][vcodec^=avc1]", want: []int{136}},
+
+		// String operators
+		{expr: "bv[vcodec^=avc1]", want: []int{137}},
+		{expr: "bv[vcodec$=028]", want: []int{137}},
+		{expr: "bv[vcodec*=4d40]", want: []int{136}},
+		{expr: `bv[vcodec~='^avc1\.4d']`, want: []int{136}},
+		{expr: "bv[vcodec!^=av01]", want: []int{248}},
+		{expr: "bv[vcodec!*=vp][vcodec!^=av01]", want: []int{137}},
+		{expr: "bv[vcodec!$=028][vcodec!~=vp9|av01]", want: []int{136}},
+		{expr: "ba[acodec=opus]", want: []int{251}},
+		{expr: "ba[acodec!=opus]", want: []int{140}},
+		{expr: "ba[ext=m4a]", want: []int{140}},
+		{expr: `bv[ext="webm"]`, want: []int{248}},
+
+		// Existence
+		{expr: "b*[acodec]", want: []int{18}},
+		{expr: "bv*[!acodec]", want: []int{399}},
+
+		// Merges and fallbacks
+		{expr: "bv+ba", want: []int{399, 251}},
+		{expr: "bv[ext=mp4]+ba[ext=m4a]", want: []int{399, 140}},
+		{expr: "bv[height=9000]+ba/b", want: []int{18}},
+		{expr: "bv[height=9000]/bv[height=720]/b", want: []int{247}},
+		{expr: "ba+bv/bv", want: []int{399}},
+
+		// Group filters apply to the selection, not to the candidates of each side
+		{expr: "(bv*+ba/b)[height<=720]", want: []int{247, 251}},
+		{expr: "(bv*+ba/b)[height<=720][ext=mp4]", want: []int{136, 251}},
+		{expr: "(bv+ba)[height<=720]", want: []int{247, 251}},
+		{expr: "(bv+ba)[acodec^=mp4a]", want: []int{399, 140}},
+		{expr: "(bv+ba)[height<=720][acodec=opus][abr<100]", want: []int{247, 249}},
+		{expr: "(bv*+ba/b)[height<=480]", want: []int{18, 251}},
+		{expr: "(bv+ba/b)[height<=480]", want: []int{18}},
+		{expr: "(b/bv+ba)[acodec=opus]", want: []int{399, 251}},
+
+		// Sort keys and limits
+		{expr: "bv", sort: "res:720,vcodec", want: []int{247}},
+		{expr: "bv", sort: "res:1080,vcodec", want: []int{399}},
+		{expr: "bv*", sort: "res:480", want: []int{18}},
+		{expr: "bv", sort: "vcodec:vp9,res", want: []int{248}},
+		{expr: "bv", sort: "vcodec:avc1,res", want: []int{137}},
+		{expr: "bv", sort: "+res,vcodec", want: []int{247}},
+		{expr: "ba", sort: "+size", want: []int{249}},
+		{expr: "ba", sort: "acodec:mp4a", want: []int{140}},
+		{expr: "bv+ba", sort: "res:720,+br", want: []int{247, 249}},
+	}
+
+	for _, tt := range tests {
+		sel, err := SelectFormatsSorted(video, tt.expr, tt.sort)
+		if err != nil {
+			t.Errorf("%q sorted by %q: %v", tt.expr, tt.sort, err)
+			continue
+		}
+
+		got := itags(sel)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q sorted by %q = %v, want %v", tt.expr, tt.sort, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q sorted by %q = %v, want %v", tt.expr, tt.sort, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestSelectFormatsNotAvailable(t *testing.T) {
+	video := &Video{Formats: testFormats()}
+
+	for _, expr := range []string{
+		"bv.7",
+		"22",
+		"3gp",
+		"webm",
+		"mp4.2",
+		"mp3",
+		"bv[height<720]",
+		"bv[filesize>=1G]",
+		"ba[fps<=30]",
+		"ba+ba",
+		"bv+bv",
+		"(bv+ba)[height<=240]",
+		"(bv*+ba/b)[height<=240]",
+		"bv[height=9000]/ba[acodec=flac]",
+	} {
+		_, err := SelectFormats(video, expr)
+		if !errors.Is(err, ErrFormatNotAvailable) {
+			t.Errorf("%q: got error %v, want ErrFormatNotAvailable", expr, err)
+		}
+	}
+}
+
+func TestSelectionRoles(t *testing.T) {
+	video := &Video{Formats: testFormats()}
+
+	sel, err := SelectFormats(video, "bv+ba")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sel.IsMerge() || sel.Format() != nil {
+		t.Errorf("bv+ba: IsMerge = %v, Format = %v", sel.IsMerge(), sel.Format())
+	}
+	if sel.Video().ITag != 399 || sel.Audio().ITag != 251 {
+		t.Errorf("bv+ba: video %d, audio %d", sel.Video().ITag, sel.Audio().ITag)
+	}
+
+	sel, err = SelectFormats(video, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sel.IsMerge() || sel.Format().ITag != 18 || sel.Video().ITag != 18 || sel.Audio().ITag != 18 {
+		t.Errorf("b: got %v", itags(sel))
+	}
+
+	sel, err = SelectFormats(video, "ba")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sel.Video() != nil {
+		t.Errorf("ba: Video = %v, want nil", sel.Video())
+	}
+}
+
+func TestParseFormatExprErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+	}{
+		{"", 0},
+		{"   ", 3},
+		{"bestest", 0},
+		{"bv+", 3},
+		{"bv+ba+ba", 5},
+		{"(bv", 3},
+		{"bv)", 2},
+		{"bv,ba", 2},
+		{"bv.0", 3},
+		{"bv.x", 3},
+		{"/bv", 0},
+		{"bv[height<=720", 2},
+		{"bv[]", 3},
+		{"bv[foo=1]", 3},
+		{"bv[ext<5]", 3},
+		{"bv[vcodec~=(]", 3},
+		{"bv[vcodec^=]", 3},
+		{"bv[height<=720]x", 15},
+		{"(bv+ba)[height<=720][", 20},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseFormatExpr(tt.expr)
+
+		var exprErr *FormatExprError
+		if !errors.As(err, &exprErr) {
+			t.Errorf("%q: got error %v, want a FormatExprError", tt.expr, err)
+			continue
+		}
+		if exprErr.Pos != tt.pos {
+			t.Errorf("%q: error at position %d, want %d (%v)", tt.expr, exprErr.Pos, tt.pos, err)
+		}
+		if exprErr.Expr != tt.expr {
+			t.Errorf("%q: error has expression %q", tt.expr, exprErr.Expr)
+		}
+	}
+}
+
+func TestParseFormatSort(t *testing.T) {
+	keys, err := ParseFormatSort("res:1080p,+size,codec,vcodec:vp9")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := FormatSort{
+		{Field: "res", Limit: 1080, HasLimit: true},
+		{Field: "size", Ascending: true},
+		{Field: "vcodec"},
+		{Field: "acodec"},
+		{Field: "vcodec", Limit: float64(videoCodecRank["vp9"]), HasLimit: true},
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("got %+v, want %+v", keys, want)
+	}
+	for i := range keys {
+		if keys[i] != want[i] {
+			t.Errorf("key %d = %+v, want %+v", i, keys[i], want[i])
+		}
+	}
+}
+
+func TestParseFormatSortErrors(t *testing.T) {
+	tests := []struct {
+		sort string
+		pos  int
+	}{
+		{"foo", 0},
+		{"res,,fps", 4},
+		{"res,fps,bogus", 8},
+		{"res:abc", 0},
+		{"fps,vcodec:xyz", 4},
+		{"res,", 4},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseFormatSort(tt.sort)
+
+		var exprErr *FormatExprError
+		if !errors.As(err, &exprErr) {
+			t.Errorf("%q: got error %v, want a FormatExprError", tt.sort, err)
+			continue
+		}
+		if exprErr.Pos != tt.pos {
+			t.Errorf("%q: error at position %d, want %d (%v)", tt.sort, exprErr.Pos, tt.pos, err)
+		}
+	}
+}
+
+func TestSelectLoudnessZero(t *testing.T) {
+	loudness := func(db float64) *float64 { return &db }
+	audio := func(itag int, db *float64) Format {
+		return Format{
+			ITag: itag, MimeType: `audio/webm; codecs="opus"`, Codec: "opus", AudioCodec: "opus",
+			AudioChannels: 2, AudioSampleRate: 48000, Bitrate: 160000 - itag, LoudnessDB: db,
+		}
+	}
+	// 0 dB is a real loudness, unlike a format that reports none
+	video := &Video{Formats: []Format{audio(249, nil), audio(250, loudness(-3)), audio(251, loudness(0))}}
+
+	tests := []struct {
+		expr string
+		sort string
+		want int
+	}{
+		{expr: "ba[loudness]", sort: "br", want: 250},
+		{expr: "ba[!loudness]", want: 249},
+		{expr: "ba[loudness=0]", want: 251},
+		{expr: "ba[loudness<0]", want: 250},
+		{expr: "ba[loudness>=0]", want: 251},
+		{expr: "ba[loudness<?1]", sort: "br", want: 249},
+	}
+
+	for _, tt := range tests {
+		sel, err := SelectFormatsSorted(video, tt.expr, tt.sort)
+		if err != nil {
+			t.Errorf("%q sorted by %q: %v", tt.expr, tt.sort, err)
+			continue
+		}
+		if got := sel.Format().ITag; got != tt.want {
+			t.Errorf("%q sorted by %q = %d, want %d", tt.expr, tt.sort, got, tt.want)
+		}
+	}
+}
@@ -26,6 +26,30 @@ type PoTokenPolicy struct {
 	NotRequiredWithPlayerToken bool
 }
 
+// PoTokenState is the account and request state a PO token policy is evaluated against
+type PoTokenState struct {
+	// The signed-in account has YouTube Premium
+	Premium bool
+	// A player PO token was sent with the player request
+	PlayerToken bool
+}
+
+// Exempt reports whether the state lifts the policy's requirement
+func (p PoTokenPolicy) Exempt(state PoTokenState) bool {
+	return p.NotRequiredForPremium && state.Premium ||
+		p.NotRequiredWithPlayerToken && state.PlayerToken
+}
+
+// IsRequired reports whether requests fail without a token under the given state
+func (p PoTokenPolicy) IsRequired(state PoTokenState) bool {
+	return p.Required && !p.Exempt(state)
+}
+
+// ShouldFetch reports whether a token should be fetched under the given state
+func (p PoTokenPolicy) ShouldFetch(state PoTokenState) bool {
+	return (p.Required || p.Recommended) && !p.Exempt(state)
+}
+
 // DefaultGVSPoTokenPolicy returns the default GVS PO token policy for web clients
 func DefaultGVSPoTokenPolicy() PoTokenPolicy {
 	return PoTokenPolicy{
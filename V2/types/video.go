@@ -1,7 +1,11 @@
 // Package types provides core type definitions for the YouTube library.
 package types
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // Video represents a YouTube video with all its metadata and available formats
 type Video struct {
@@ -14,18 +18,38 @@ type Video struct {
 	Author      string
 	ChannelID   string
 	ChannelURL  string
-	UploadDate  string
+	// Owner profile URL, typically the channel handle
+	OwnerProfileURL string
+
+	UploadDate  time.Time
+	PublishDate time.Time
 
 	Thumbnails []Thumbnail
 	Keywords   []string
 	Category   string
+	LikeCount  int
 
 	IsLive        bool
 	IsLiveContent bool
+	IsUpcoming    bool
 	IsPrivate     bool
+	IsUnlisted    bool
+	IsFamilySafe  bool
 	AgeRestricted bool
 
+	// Broadcast window for live streams and premieres
+	LiveStartTime time.Time
+	LiveEndTime   time.Time
+
+	// ISO 3166 codes of countries the video is available in; empty if unknown
+	AvailableCountries []string
+
 	Formats []Format
+	// When the formats were resolved; stream URLs expire some hours later
+	FetchedAt time.Time
+
+	Captions             []CaptionTrack
+	TranslationLanguages []CaptionLanguage
 
 	// Internal data for format URL resolution
 	VisitorData string
@@ -132,9 +156,42 @@ func (v *Video) VideoOnlyFormats() []Format {
 	})
 }
 
+// CaptionTrack returns the best caption track for a language code, preferring manual captions over ASR
+func (v *Video) CaptionTrack(languageCode string) (CaptionTrack, bool) {
+	var fallback *CaptionTrack
+	for i := range v.Captions {
+		t := &v.Captions[i]
+		if !strings.EqualFold(t.LanguageCode, languageCode) {
+			continue
+		}
+		if t.Kind == CaptionKindManual {
+			return *t, true
+		}
+		if fallback == nil {
+			fallback = t
+		}
+	}
+
+	if fallback != nil {
+		return *fallback, true
+	}
+	return CaptionTrack{}, false
+}
+
 // StreamableFormats returns formats that support range requests
 func (v *Video) StreamableFormats() []Format {
 	return v.FilterFormats(func(f Format) bool {
 		return f.ContentLength > 0
 	})
 }
+
+// ExpiresAt returns the earliest expiry among the format URLs, or the zero time if none is known
+func (v *Video) ExpiresAt() time.Time {
+	var earliest time.Time
+	for _, f := range v.Formats {
+		if !f.ExpiresAt.IsZero() && (earliest.IsZero() || f.ExpiresAt.Before(earliest)) {
+			earliest = f.ExpiresAt
+		}
+	}
+	return earliest
+}
@@ -0,0 +1,305 @@
+// Package webm demuxes Opus audio from WebM/Matroska streams and remuxes it to Ogg Opus.
+package webm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/elucid503/overture-play/v2/internal/ebml"
+)
+
+// ErrNoOpusTrack is returned when a stream has no Opus audio track
+var ErrNoOpusTrack = errors.New("no opus track")
+
+// codecOpus is the Matroska codec ID for Opus
+const codecOpus = "A_OPUS"
+
+// Track describes a Matroska track
+type Track struct {
+	Number       uint64
+	Type         uint64
+	CodecID      string
+	CodecPrivate []byte
+
+	SampleRate float64
+	Channels   int
+
+	// Audio the decoder must discard at the start, and how far before a seek point decoding must begin
+	CodecDelay  time.Duration
+	SeekPreRoll time.Duration
+}
+
+// Packet is one Opus packet with its presentation time
+type Packet struct {
+	Data      []byte
+	Timestamp time.Duration
+	Duration  time.Duration
+}
+
+// Demuxer reads Opus packets from a WebM stream
+// It reads forward only, so any io.Reader works, including the body returned by GetStream
+type Demuxer struct {
+	reader *ebml.Reader
+
+	timecodeScale uint64
+	track         *Track
+	head          *OpusHead
+
+	clusterTime int64
+	pending     []Packet
+}
+
+// NewDemuxer reads the stream header up to the track list and selects the first Opus track
+func NewDemuxer(r io.Reader) (*Demuxer, error) {
+	d := &Demuxer{
+		reader:        ebml.NewReader(r),
+		timecodeScale: ebml.DefaultTimecodeScale,
+	}
+
+	if err := d.readHeader(); err != nil {
+		return nil, invalidData(err)
+	}
+
+	return d, nil
+}
+
+// readHeader reads up to and including the track list and builds the OpusHead
+func (d *Demuxer) readHeader() error {
+	for d.track == nil {
+		id, size, err := d.reader.ReadHeader()
+		if err != nil {
+			if err == io.EOF {
+				return ErrNoOpusTrack
+			}
+			return err
+		}
+
+		switch id {
+		case ebml.IDSegment:
+			// Descend into the segment
+		case ebml.IDInfo:
+			data, err := d.reader.ReadBody(id, size)
+			if err != nil {
+				return err
+			}
+			if err := d.parseInfo(data); err != nil {
+				return err
+			}
+		case ebml.IDTracks:
+			data, err := d.reader.ReadBody(id, size)
+			if err != nil {
+				return err
+			}
+			if err := d.parseTracks(data); err != nil {
+				return err
+			}
+			if d.track == nil {
+				return ErrNoOpusTrack
+			}
+		case ebml.IDCluster:
+			return fmt.Errorf("%w: cluster before track list", ErrInvalidData)
+		default:
+			if err := d.reader.Skip(id, size); err != nil {
+				return err
+			}
+		}
+	}
+
+	head, err := d.opusHead()
+	if err != nil {
+		return err
+	}
+	d.head = head
+
+	return nil
+}
+
+// Track returns the selected Opus track
+func (d *Demuxer) Track() Track {
+	return *d.track
+}
+
+// OpusHead returns the Opus identification header from CodecPrivate, or one built from the track
+func (d *Demuxer) OpusHead() *OpusHead {
+	return d.head
+}
+
+// ReadPacket returns the next Opus packet, or io.EOF at the end of the stream
+func (d *Demuxer) ReadPacket() (Packet, error) {
+	for len(d.pending) == 0 {
+		if err := d.readElement(); err != nil {
+			return Packet{}, invalidData(err)
+		}
+	}
+
+	p := d.pending[0]
+	d.pending = d.pending[1:]
+	return p, nil
+}
+
+// readElement reads one element, queueing the packets of any block for the selected track
+// Segment and Cluster are entered rather than read whole, so unknown sizes work
+func (d *Demuxer) readElement() error {
+	id, size, err := d.reader.ReadHeader()
+	if err != nil {
+		return err
+	}
+
+	switch id {
+	case ebml.IDSegment:
+	case ebml.IDInfo:
+		data, err := d.reader.ReadBody(id, size)
+		if err != nil {
+			return err
+		}
+		return d.parseInfo(data)
+	case ebml.IDCluster:
+		d.clusterTime = 0
+	case ebml.IDTimecode:
+		data, err := d.reader.ReadBody(id, size)
+		if err != nil {
+			return err
+		}
+		d.clusterTime = int64(ebml.ReadUint(data))
+	case ebml.IDSimpleBlock:
+		data, err := d.reader.ReadBody(id, size)
+		if err != nil {
+			return err
+		}
+		return d.parseBlock(data, 0)
+	case ebml.IDBlockGroup:
+		data, err := d.reader.ReadBody(id, size)
+		if err != nil {
+			return err
+		}
+		return d.parseBlockGroup(data)
+	default:
+		return d.reader.Skip(id, size)
+	}
+
+	return nil
+}
+
+// parseInfo reads the timecode scale from Segment Info
+func (d *Demuxer) parseInfo(data []byte) error {
+	return ebml.EachElement(data, func(id uint64, data []byte) error {
+		if id == ebml.IDTimecodeScale {
+			if scale := ebml.ReadUint(data); scale > 0 {
+				d.timecodeScale = scale
+			}
+		}
+		return nil
+	})
+}
+
+// parseTracks selects the first Opus track
+func (d *Demuxer) parseTracks(data []byte) error {
+	return ebml.EachElement(data, func(id uint64, data []byte) error {
+		if id != ebml.IDTrackEntry || d.track != nil {
+			return nil
+		}
+
+		track, err := parseTrackEntry(data)
+		if err != nil {
+			return err
+		}
+		if track.CodecID == codecOpus {
+			d.track = track
+		}
+		return nil
+	})
+}
+
+// parseTrackEntry reads one TrackEntry
+func parseTrackEntry(data []byte) (*Track, error) {
+	track := &Track{}
+
+	err := ebml.EachElement(data, func(id uint64, data []byte) error {
+		switch id {
+		case ebml.IDTrackNumber:
+			track.Number = ebml.ReadUint(data)
+		case ebml.IDTrackType:
+			track.Type = ebml.ReadUint(data)
+		case ebml.IDCodecID:
+			track.CodecID = string(data)
+		case ebml.IDCodecPrivate:
+			track.CodecPrivate = append([]byte(nil), data...)
+		case ebml.IDCodecDelay:
+			track.CodecDelay = time.Duration(ebml.ReadUint(data))
+		case ebml.IDSeekPreRoll:
+			track.SeekPreRoll = time.Duration(ebml.ReadUint(data))
+		case ebml.IDAudio:
+			return ebml.EachElement(data, func(id uint64, data []byte) error {
+				switch id {
+				case ebml.IDSampleRate:
+					track.SampleRate = ebml.ReadFloat(data)
+				case ebml.IDChannels:
+					track.Channels = int(ebml.ReadUint(data))
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+
+	return track, err
+}
+
+// parseBlockGroup reads the Block and BlockDuration of a BlockGroup
+func (d *Demuxer) parseBlockGroup(data []byte) error {
+	var block []byte
+	var duration uint64
+
+	err := ebml.EachElement(data, func(id uint64, data []byte) error {
+		switch id {
+		case ebml.IDBlock:
+			block = data
+		case ebml.IDBlockDuration:
+			duration = ebml.ReadUint(data)
+		}
+		return nil
+	})
+	if err != nil || block == nil {
+		return err
+	}
+
+	return d.parseBlock(block, time.Duration(duration*d.timecodeScale))
+}
+
+// parseBlock splits a Block or SimpleBlock into packets, queueing those of the selected track
+// blockDuration, if known, is used for packets whose duration cannot be read from the Opus TOC
+func (d *Demuxer) parseBlock(data []byte, blockDuration time.Duration) error {
+	track, n, err := ebml.ParseVint(data, false)
+	if err != nil {
+		return err
+	}
+	if track != d.track.Number {
+		return nil
+	}
+	if len(data) < n+3 {
+		return fmt.Errorf("%w: truncated block header", ErrInvalidData)
+	}
+
+	relative := int16(uint16(data[n])<<8 | uint16(data[n+1]))
+	flags := data[n+2]
+
+	frames, err := splitLaces(data[n+3:], (flags>>1)&0x03)
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Duration((d.clusterTime + int64(relative)) * int64(d.timecodeScale))
+	for _, frame := range frames {
+		duration := PacketDuration(frame)
+		if duration == 0 && len(frames) == 1 {
+			duration = blockDuration
+		}
+
+		d.pending = append(d.pending, Packet{Data: frame, Timestamp: timestamp, Duration: duration})
+		timestamp += duration
+	}
+
+	return nil
+}
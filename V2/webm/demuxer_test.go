@@ -0,0 +1,117 @@
+package webm
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// fixturePackets are the Opus packets of testdata/opus.webm in order
+// The file has an unknown-size segment and cluster, a VP9 track whose blocks must be skipped,
+// SimpleBlocks without lacing and with Xiph, EBML and fixed lacing, and a BlockGroup whose
+// packet has no readable duration so BlockDuration is used
+var fixturePackets = []struct {
+	data      string
+	timestamp time.Duration
+	duration  time.Duration
+}{
+	{"\xf8a0", 0, 20 * time.Millisecond},
+	{"\xf8a1", 20 * time.Millisecond, 20 * time.Millisecond},
+	{"\xf1a2", 40 * time.Millisecond, 20 * time.Millisecond},
+	{"\xf8x0", 60 * time.Millisecond, 20 * time.Millisecond},
+	{"\xf8x1-longer", 80 * time.Millisecond, 20 * time.Millisecond},
+	{"\xf8x2", 100 * time.Millisecond, 20 * time.Millisecond},
+	{"\xfb", 120 * time.Millisecond, 20 * time.Millisecond},
+	{"\xf8e0-abc", 140 * time.Millisecond, 20 * time.Millisecond},
+	{"\xf8e1", 160 * time.Millisecond, 20 * time.Millisecond},
+	{"\xf8e2-abcdef", 180 * time.Millisecond, 20 * time.Millisecond},
+	{"\xf8f0", 200 * time.Millisecond, 20 * time.Millisecond},
+	{"\xf8f1", 220 * time.Millisecond, 20 * time.Millisecond},
+}
+
+func readFixture(t *testing.T) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/opus.webm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDemuxerTrack(t *testing.T) {
+	d, err := NewDemuxer(bytes.NewReader(readFixture(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	track := d.Track()
+	if track.Number != 1 || track.CodecID != codecOpus || track.Channels != 2 || track.SampleRate != 48000 {
+		t.Errorf("track = %+v", track)
+	}
+	if track.CodecDelay != 6500*time.Microsecond || track.SeekPreRoll != 80*time.Millisecond {
+		t.Errorf("codec delay %v, seek pre-roll %v", track.CodecDelay, track.SeekPreRoll)
+	}
+
+	head := d.OpusHead()
+	if head.Channels != 2 || head.PreSkip != 312 || head.InputSampleRate != 48000 || head.MappingFamily != 0 {
+		t.Errorf("head = %+v", head)
+	}
+}
+
+func TestDemuxerReadPacket(t *testing.T) {
+	d, err := NewDemuxer(bytes.NewReader(readFixture(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range fixturePackets {
+		p, err := d.ReadPacket()
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		if string(p.Data) != want.data || p.Timestamp != want.timestamp || p.Duration != want.duration {
+			t.Errorf("packet %d = %q at %v for %v, want %q at %v for %v",
+				i, p.Data, p.Timestamp, p.Duration, want.data, want.timestamp, want.duration)
+		}
+	}
+
+	if _, err := d.ReadPacket(); err != io.EOF {
+		t.Errorf("after the last packet: got %v, want io.EOF", err)
+	}
+}
+
+func TestDemuxerTruncated(t *testing.T) {
+	data := readFixture(t)
+
+	d, err := NewDemuxer(bytes.NewReader(data[:len(data)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, err = d.ReadPacket()
+		if err != nil {
+			break
+		}
+	}
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated stream: got %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestDemuxerNoOpusTrack(t *testing.T) {
+	data := readFixture(t)
+
+	// Renaming the codec leaves only the VP9 track and an unknown audio codec
+	data = bytes.Replace(data, []byte("A_OPUS"), []byte("A_OPUX"), 1)
+	if _, err := NewDemuxer(bytes.NewReader(data)); !errors.Is(err, ErrNoOpusTrack) {
+		t.Errorf("got %v, want ErrNoOpusTrack", err)
+	}
+
+	if _, err := NewDemuxer(bytes.NewReader(nil)); !errors.Is(err, ErrNoOpusTrack) {
+		t.Errorf("empty stream: got %v, want ErrNoOpusTrack", err)
+	}
+}
@@ -0,0 +1,20 @@
+package webm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elucid503/overture-play/v2/internal/ebml"
+)
+
+// ErrInvalidData is returned for malformed EBML
+var ErrInvalidData = errors.New("invalid webm data")
+
+// invalidData reports malformed EBML as ErrInvalidData, passing other errors through
+func invalidData(err error) error {
+	var ebmlErr *ebml.Error
+	if errors.As(err, &ebmlErr) {
+		return fmt.Errorf("%w: %s", ErrInvalidData, ebmlErr.Msg)
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package webm
+
+import (
+	"fmt"
+
+	"github.com/elucid503/overture-play/v2/internal/ebml"
+)
+
+// Block lacing modes from the block flags
+const (
+	lacingNone  = 0
+	lacingXiph  = 1
+	lacingFixed = 2
+	lacingEBML  = 3
+)
+
+// splitLaces splits a block payload into its frames
+func splitLaces(data []byte, lacing byte) ([][]byte, error) {
+	if lacing == lacingNone {
+		return [][]byte{data}, nil
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: laced block has no frame count", ErrInvalidData)
+	}
+	count := int(data[0]) + 1
+	data = data[1:]
+
+	sizes := make([]int, count)
+	switch lacing {
+	case lacingXiph:
+		pos := 0
+		for i := 0; i < count-1; i++ {
+			for {
+				if pos >= len(data) {
+					return nil, fmt.Errorf("%w: truncated Xiph lacing", ErrInvalidData)
+				}
+				b := data[pos]
+				pos++
+				sizes[i] += int(b)
+				if b != 0xFF {
+					break
+				}
+			}
+		}
+		data = data[pos:]
+
+	case lacingEBML:
+		first, n, err := ebml.ParseVint(data, false)
+		if err != nil {
+			return nil, invalidData(err)
+		}
+		pos := n
+		sizes[0] = int(first)
+
+		// Later sizes are signed differences from the previous size
+		for i := 1; i < count-1; i++ {
+			raw, n, err := ebml.ParseVint(data[pos:], false)
+			if err != nil {
+				return nil, invalidData(err)
+			}
+			pos += n
+			bias := int64(1)<<(7*n-1) - 1
+			sizes[i] = sizes[i-1] + int(int64(raw)-bias)
+			if sizes[i] < 0 {
+				return nil, fmt.Errorf("%w: negative EBML lace size", ErrInvalidData)
+			}
+		}
+		data = data[pos:]
+
+	case lacingFixed:
+		if len(data)%count != 0 {
+			return nil, fmt.Errorf("%w: %d bytes do not split into %d equal frames", ErrInvalidData, len(data), count)
+		}
+		for i := range sizes {
+			sizes[i] = len(data) / count
+		}
+		return cutFrames(data, sizes)
+	}
+
+	// The last frame takes whatever remains
+	used := 0
+	for _, size := range sizes[:count-1] {
+		used += size
+	}
+	if used > len(data) {
+		return nil, fmt.Errorf("%w: lace sizes exceed block", ErrInvalidData)
+	}
+	sizes[count-1] = len(data) - used
+
+	return cutFrames(data, sizes)
+}
+
+// cutFrames slices data into consecutive frames of the given sizes
+func cutFrames(data []byte, sizes []int) ([][]byte, error) {
+	frames := make([][]byte, len(sizes))
+	pos := 0
+	for i, size := range sizes {
+		if pos+size > len(data) {
+			return nil, fmt.Errorf("%w: lace sizes exceed block", ErrInvalidData)
+		}
+		frames[i] = data[pos : pos+size]
+		pos += size
+	}
+	return frames, nil
+}
@@ -0,0 +1,67 @@
+package webm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSplitLaces(t *testing.T) {
+	tests := []struct {
+		name   string
+		lacing byte
+		data   []byte
+		want   []string
+	}{
+		{"none", lacingNone, []byte("abc"), []string{"abc"}},
+		{"xiph", lacingXiph, []byte("\x02\x01\x03abcdefg"), []string{"a", "bcd", "efg"}},
+		{"xiph 255", lacingXiph, append([]byte{0x01, 0xFF, 0x01}, make([]byte, 257)...), []string{string(make([]byte, 256)), "\x00"}},
+		{"xiph empty frame", lacingXiph, []byte("\x01\x00ab"), []string{"", "ab"}},
+		// 2 then +1 (0xC0 is 64 with a bias of 63) then the rest
+		{"ebml", lacingEBML, []byte("\x02\x82\xC0abcdefgh"), []string{"ab", "cde", "fgh"}},
+		// 3 then -2 (0xBD is 61)
+		{"ebml negative", lacingEBML, []byte("\x02\x83\xBDabcdefg"), []string{"abc", "d", "efg"}},
+		// A two byte difference: 0x6000 is +1 with a bias of 8191
+		{"ebml two byte", lacingEBML, []byte("\x02\x82\x60\x00abcdefgh"), []string{"ab", "cde", "fgh"}},
+		{"fixed", lacingFixed, []byte("\x02abcdef"), []string{"ab", "cd", "ef"}},
+		{"fixed single", lacingFixed, []byte("\x00abc"), []string{"abc"}},
+	}
+
+	for _, tt := range tests {
+		frames, err := splitLaces(tt.data, tt.lacing)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(frames) != len(tt.want) {
+			t.Errorf("%s: got %d frames, want %d", tt.name, len(frames), len(tt.want))
+			continue
+		}
+		for i := range frames {
+			if string(frames[i]) != tt.want[i] {
+				t.Errorf("%s: frame %d = %q, want %q", tt.name, i, frames[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestSplitLacesInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		lacing byte
+		data   []byte
+	}{
+		{"xiph no count", lacingXiph, nil},
+		{"xiph truncated sizes", lacingXiph, []byte("\x02\x01")},
+		{"xiph sizes exceed block", lacingXiph, []byte("\x01\x05ab")},
+		{"ebml bad size", lacingEBML, []byte("\x01\x00ab")},
+		{"ebml negative size", lacingEBML, []byte("\x02\x81\x80abc")},
+		{"ebml sizes exceed block", lacingEBML, []byte("\x01\x85ab")},
+		{"fixed uneven", lacingFixed, []byte("\x01abc")},
+	}
+
+	for _, tt := range tests {
+		if _, err := splitLaces(tt.data, tt.lacing); !errors.Is(err, ErrInvalidData) {
+			t.Errorf("%s: got %v, want ErrInvalidData", tt.name, err)
+		}
+	}
+}
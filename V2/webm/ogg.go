@@ -0,0 +1,179 @@
+package webm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+// Ogg page header flags
+const (
+	pageContinued = 0x01
+	pageBOS       = 0x02
+	pageEOS       = 0x04
+)
+
+// maxPagePacket is the largest packet that fits in one page's 255 lacing values
+const maxPagePacket = 255*255 - 1
+
+// oggVendor is written in the OpusTags header
+const oggVendor = "overture-play"
+
+// oggCRCTable is the lookup table for the Ogg CRC-32 (polynomial 0x04C11DB7, no reflection)
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// OggWriter writes Opus packets as an Ogg Opus stream (RFC 7845), one packet per page
+// The last packet is held back so Close can mark its page as the end of the stream
+type OggWriter struct {
+	w      io.Writer
+	serial uint32
+	seq    uint32
+
+	granule uint64
+	last    *Packet
+	closed  bool
+}
+
+// NewOggWriter writes the OpusHead and OpusTags headers and returns a writer for the audio packets
+func NewOggWriter(w io.Writer, head *OpusHead) (*OggWriter, error) {
+	o := &OggWriter{w: w, serial: rand.Uint32()}
+
+	if err := o.writePage(head.Marshal(), 0, pageBOS); err != nil {
+		return nil, err
+	}
+
+	tags := make([]byte, 0, 16+len(oggVendor))
+	tags = append(tags, "OpusTags"...)
+	tags = binary.LittleEndian.AppendUint32(tags, uint32(len(oggVendor)))
+	tags = append(tags, oggVendor...)
+	tags = binary.LittleEndian.AppendUint32(tags, 0)
+
+	if err := o.writePage(tags, 0, 0); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// WritePacket queues an Opus packet, writing the previously queued one
+func (o *OggWriter) WritePacket(p Packet) error {
+	if o.closed {
+		return fmt.Errorf("ogg writer closed")
+	}
+	if len(p.Data) > maxPagePacket {
+		return fmt.Errorf("opus packet of %d bytes does not fit in an ogg page", len(p.Data))
+	}
+
+	if err := o.flush(0); err != nil {
+		return err
+	}
+
+	p.Data = append([]byte(nil), p.Data...)
+	o.last = &p
+	return nil
+}
+
+// Close writes the final packet with the end-of-stream flag; it does not close the underlying writer
+func (o *OggWriter) Close() error {
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+
+	if o.last == nil {
+		// A stream needs an EOS page even without audio
+		return o.writePage(nil, o.granule, pageEOS)
+	}
+	return o.flush(pageEOS)
+}
+
+// flush writes the queued packet, if any
+func (o *OggWriter) flush(flags byte) error {
+	if o.last == nil {
+		return nil
+	}
+
+	p := o.last
+	o.last = nil
+
+	duration := p.Duration
+	if duration == 0 {
+		duration = PacketDuration(p.Data)
+	}
+	o.granule += durationToSamples(duration)
+
+	return o.writePage(p.Data, o.granule, flags)
+}
+
+// writePage writes a single page holding one complete packet
+func (o *OggWriter) writePage(packet []byte, granule uint64, flags byte) error {
+	segments := len(packet)/255 + 1
+
+	page := make([]byte, 27+segments, 27+segments+len(packet))
+	copy(page, "OggS")
+	page[4] = 0
+	page[5] = flags
+	binary.LittleEndian.PutUint64(page[6:], granule)
+	binary.LittleEndian.PutUint32(page[14:], o.serial)
+	binary.LittleEndian.PutUint32(page[18:], o.seq)
+	page[26] = byte(segments)
+
+	for i := 0; i < segments-1; i++ {
+		page[27+i] = 255
+	}
+	page[27+segments-1] = byte(len(packet) % 255)
+	page = append(page, packet...)
+
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	binary.LittleEndian.PutUint32(page[22:], crc)
+
+	o.seq++
+	_, err := o.w.Write(page)
+	return err
+}
+
+// RemuxOpus reads a WebM Opus stream from r and writes it to w as Ogg Opus
+func RemuxOpus(w io.Writer, r io.Reader) error {
+	d, err := NewDemuxer(r)
+	if err != nil {
+		return err
+	}
+
+	o, err := NewOggWriter(w, d.OpusHead())
+	if err != nil {
+		return err
+	}
+
+	for {
+		p, err := d.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := o.WritePacket(p); err != nil {
+			return err
+		}
+	}
+
+	return o.Close()
+}
@@ -0,0 +1,183 @@
+package webm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// oggPage is a parsed Ogg page
+type oggPage struct {
+	flags   byte
+	granule uint64
+	serial  uint32
+	seq     uint32
+	packets [][]byte
+}
+
+// parseOggPages splits an Ogg stream into pages, checking the capture pattern and CRC of each
+func parseOggPages(t *testing.T, data []byte) []oggPage {
+	t.Helper()
+
+	var pages []oggPage
+	for pos := 0; pos < len(data); {
+		if len(data)-pos < 27 || string(data[pos:pos+4]) != "OggS" || data[pos+4] != 0 {
+			t.Fatalf("page %d: bad header at offset %d", len(pages), pos)
+		}
+
+		segments := int(data[pos+26])
+		size := 0
+		for _, lace := range data[pos+27 : pos+27+segments] {
+			size += int(lace)
+		}
+		end := pos + 27 + segments + size
+		if end > len(data) {
+			t.Fatalf("page %d: truncated", len(pages))
+		}
+
+		page := append([]byte(nil), data[pos:end]...)
+		want := binary.LittleEndian.Uint32(page[22:])
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		var crc uint32
+		for _, b := range page {
+			crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+		}
+		if crc != want {
+			t.Errorf("page %d: crc %08x, want %08x", len(pages), crc, want)
+		}
+
+		p := oggPage{
+			flags:   page[5],
+			granule: binary.LittleEndian.Uint64(page[6:]),
+			serial:  binary.LittleEndian.Uint32(page[14:]),
+			seq:     binary.LittleEndian.Uint32(page[18:]),
+		}
+
+		// Rebuild packets from the lacing values; a value below 255 ends a packet
+		body := page[27+segments:]
+		var packet []byte
+		for _, lace := range page[27 : 27+segments] {
+			packet = append(packet, body[:lace]...)
+			body = body[lace:]
+			if lace < 255 {
+				p.packets = append(p.packets, packet)
+				packet = nil
+			}
+		}
+
+		pages = append(pages, p)
+		pos = end
+	}
+	return pages
+}
+
+func TestOggCRC(t *testing.T) {
+	// The Ogg CRC of "123456789" (CRC-32/MPEG-2 without the final inversion)
+	var crc uint32
+	for _, b := range []byte("123456789") {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	if crc != 0x89A1897F {
+		t.Errorf("crc = %08x, want 89a1897f", crc)
+	}
+}
+
+func TestRemuxOpus(t *testing.T) {
+	var out bytes.Buffer
+	if err := RemuxOpus(&out, bytes.NewReader(readFixture(t))); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := parseOggPages(t, out.Bytes())
+	if len(pages) != 2+len(fixturePackets) {
+		t.Fatalf("got %d pages, want %d", len(pages), 2+len(fixturePackets))
+	}
+
+	for i, p := range pages {
+		if p.seq != uint32(i) || p.serial != pages[0].serial {
+			t.Errorf("page %d: sequence %d, serial %08x", i, p.seq, p.serial)
+		}
+		if len(p.packets) != 1 {
+			t.Errorf("page %d: %d packets, want 1", i, len(p.packets))
+		}
+	}
+
+	head, err := ParseOpusHead(pages[0].packets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages[0].flags != pageBOS || pages[0].granule != 0 || head.Channels != 2 || head.PreSkip != 312 {
+		t.Errorf("head page: flags %#x, granule %d, head %+v", pages[0].flags, pages[0].granule, head)
+	}
+
+	if tags := pages[1].packets[0]; pages[1].flags != 0 || pages[1].granule != 0 || !bytes.HasPrefix(tags, []byte("OpusTags")) {
+		t.Errorf("tags page: flags %#x, granule %d, packet %q", pages[1].flags, pages[1].granule, tags)
+	}
+
+	// Every fixture packet is 20 ms, 960 samples at 48 kHz
+	for i, want := range fixturePackets {
+		p := pages[2+i]
+		if string(p.packets[0]) != want.data {
+			t.Errorf("audio page %d: packet %q, want %q", i, p.packets[0], want.data)
+		}
+		if p.granule != uint64(960*(i+1)) {
+			t.Errorf("audio page %d: granule %d, want %d", i, p.granule, 960*(i+1))
+		}
+
+		flags := byte(0)
+		if i == len(fixturePackets)-1 {
+			flags = pageEOS
+		}
+		if p.flags != flags {
+			t.Errorf("audio page %d: flags %#x, want %#x", i, p.flags, flags)
+		}
+	}
+}
+
+func TestOggWriterLargePacket(t *testing.T) {
+	var out bytes.Buffer
+	o, err := NewOggWriter(&out, &OpusHead{Version: 1, Channels: 2, InputSampleRate: 48000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 510 bytes need lacing values 255, 255, 0
+	packet := append([]byte{31 << 3}, bytes.Repeat([]byte{0xAA}, 509)...)
+	if err := o.WritePacket(Packet{Data: packet}); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.WritePacket(Packet{Data: make([]byte, maxPagePacket+1)}); err == nil {
+		t.Error("oversized packet was accepted")
+	}
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.WritePacket(Packet{Data: packet}); err == nil {
+		t.Error("write after close was accepted")
+	}
+
+	pages := parseOggPages(t, out.Bytes())
+	if len(pages) != 3 {
+		t.Fatalf("got %d pages, want 3", len(pages))
+	}
+	last := pages[2]
+	if !bytes.Equal(last.packets[0], packet) || last.granule != 960 || last.flags != pageEOS {
+		t.Errorf("audio page: %d byte packet, granule %d, flags %#x", len(last.packets[0]), last.granule, last.flags)
+	}
+}
+
+func TestOggWriterEmpty(t *testing.T) {
+	var out bytes.Buffer
+	o, err := NewOggWriter(&out, &OpusHead{Version: 1, Channels: 1, InputSampleRate: 48000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := parseOggPages(t, out.Bytes())
+	if len(pages) != 3 || pages[2].flags != pageEOS || pages[2].granule != 0 {
+		t.Errorf("got %d pages, want headers and an empty EOS page", len(pages))
+	}
+}
@@ -0,0 +1,132 @@
+package webm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// opusSampleRate is the rate Opus timestamps and granule positions are counted in
+const opusSampleRate = 48000
+
+// OpusHead is the Opus identification header (RFC 7845 section 5.1)
+type OpusHead struct {
+	Version         uint8
+	Channels        uint8
+	PreSkip         uint16
+	InputSampleRate uint32
+	OutputGain      int16
+	MappingFamily   uint8
+
+	// Present when MappingFamily is not 0
+	StreamCount    uint8
+	CoupledCount   uint8
+	ChannelMapping []byte
+}
+
+// ParseOpusHead parses an OpusHead packet, as stored in the track's CodecPrivate
+func ParseOpusHead(data []byte) (*OpusHead, error) {
+	if len(data) < 19 || string(data[:8]) != "OpusHead" {
+		return nil, fmt.Errorf("%w: not an OpusHead", ErrInvalidData)
+	}
+
+	head := &OpusHead{
+		Version:         data[8],
+		Channels:        data[9],
+		PreSkip:         binary.LittleEndian.Uint16(data[10:]),
+		InputSampleRate: binary.LittleEndian.Uint32(data[12:]),
+		OutputGain:      int16(binary.LittleEndian.Uint16(data[16:])),
+		MappingFamily:   data[18],
+	}
+
+	if head.MappingFamily != 0 {
+		if len(data) < 21+int(head.Channels) {
+			return nil, fmt.Errorf("%w: truncated OpusHead channel mapping", ErrInvalidData)
+		}
+		head.StreamCount = data[19]
+		head.CoupledCount = data[20]
+		head.ChannelMapping = append([]byte(nil), data[21:21+int(head.Channels)]...)
+	}
+
+	return head, nil
+}
+
+// Marshal encodes the header as an OpusHead packet
+func (h *OpusHead) Marshal() []byte {
+	b := make([]byte, 19, 21+len(h.ChannelMapping))
+	copy(b, "OpusHead")
+	b[8] = h.Version
+	b[9] = h.Channels
+	binary.LittleEndian.PutUint16(b[10:], h.PreSkip)
+	binary.LittleEndian.PutUint32(b[12:], h.InputSampleRate)
+	binary.LittleEndian.PutUint16(b[16:], uint16(h.OutputGain))
+	b[18] = h.MappingFamily
+
+	if h.MappingFamily != 0 {
+		b = append(b, h.StreamCount, h.CoupledCount)
+		b = append(b, h.ChannelMapping...)
+	}
+
+	return b
+}
+
+// opusHead parses the track's CodecPrivate, falling back to a stereo-or-mono header built from the track
+func (d *Demuxer) opusHead() (*OpusHead, error) {
+	if len(d.track.CodecPrivate) > 0 {
+		return ParseOpusHead(d.track.CodecPrivate)
+	}
+
+	channels := d.track.Channels
+	if channels == 0 {
+		channels = 2
+	}
+	if channels > 2 {
+		return nil, fmt.Errorf("%w: %d channel track has no OpusHead", ErrInvalidData, channels)
+	}
+
+	return &OpusHead{
+		Version:         1,
+		Channels:        uint8(channels),
+		PreSkip:         uint16(durationToSamples(d.track.CodecDelay)),
+		InputSampleRate: uint32(d.track.SampleRate),
+	}, nil
+}
+
+// frameDurations maps a TOC configuration to its frame duration (RFC 6716 section 3.1)
+var frameDurations = [32]time.Duration{
+	10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 60 * time.Millisecond, // SILK NB
+	10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 60 * time.Millisecond, // SILK MB
+	10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 60 * time.Millisecond, // SILK WB
+	10 * time.Millisecond, 20 * time.Millisecond, // Hybrid SWB
+	10 * time.Millisecond, 20 * time.Millisecond, // Hybrid FB
+	2500 * time.Microsecond, 5 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond, // CELT NB
+	2500 * time.Microsecond, 5 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond, // CELT WB
+	2500 * time.Microsecond, 5 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond, // CELT SWB
+	2500 * time.Microsecond, 5 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond, // CELT FB
+}
+
+// PacketDuration returns the audio duration of an Opus packet from its TOC byte, or 0 if it is malformed
+func PacketDuration(packet []byte) time.Duration {
+	if len(packet) == 0 {
+		return 0
+	}
+
+	toc := packet[0]
+	frames := 1
+	switch toc & 0x03 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0
+		}
+		frames = int(packet[1] & 0x3F)
+	}
+
+	return time.Duration(frames) * frameDurations[toc>>3]
+}
+
+// durationToSamples converts a duration to a count of 48 kHz samples
+func durationToSamples(d time.Duration) uint64 {
+	return uint64(d) * opusSampleRate / uint64(time.Second)
+}
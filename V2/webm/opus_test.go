@@ -0,0 +1,82 @@
+package webm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOpusHeadRoundTrip(t *testing.T) {
+	heads := []OpusHead{
+		{Version: 1, Channels: 2, PreSkip: 312, InputSampleRate: 48000, OutputGain: -256},
+		{Version: 1, Channels: 1, PreSkip: 0, InputSampleRate: 44100},
+		{
+			Version: 1, Channels: 6, PreSkip: 312, InputSampleRate: 48000, MappingFamily: 1,
+			StreamCount: 4, CoupledCount: 2, ChannelMapping: []byte{0, 4, 1, 2, 3, 5},
+		},
+	}
+
+	for _, head := range heads {
+		data := head.Marshal()
+		if string(data[:8]) != "OpusHead" {
+			t.Errorf("%+v: marshaled without magic: %q", head, data)
+		}
+
+		parsed, err := ParseOpusHead(data)
+		if err != nil {
+			t.Errorf("%+v: %v", head, err)
+			continue
+		}
+		if parsed.Version != head.Version || parsed.Channels != head.Channels || parsed.PreSkip != head.PreSkip ||
+			parsed.InputSampleRate != head.InputSampleRate || parsed.OutputGain != head.OutputGain ||
+			parsed.MappingFamily != head.MappingFamily || parsed.StreamCount != head.StreamCount ||
+			parsed.CoupledCount != head.CoupledCount || !bytes.Equal(parsed.ChannelMapping, head.ChannelMapping) {
+			t.Errorf("round trip of %+v gave %+v", head, *parsed)
+		}
+
+		if !bytes.Equal(parsed.Marshal(), data) {
+			t.Errorf("%+v: marshal is not stable", head)
+		}
+	}
+}
+
+func TestParseOpusHeadInvalid(t *testing.T) {
+	valid := (&OpusHead{Version: 1, Channels: 6, MappingFamily: 1, StreamCount: 4, CoupledCount: 2, ChannelMapping: []byte{0, 4, 1, 2, 3, 5}}).Marshal()
+
+	for _, data := range [][]byte{
+		nil,
+		[]byte("OpusHead"),
+		append([]byte("OpusTags"), valid[8:]...),
+		valid[:19],
+		valid[:len(valid)-1],
+	} {
+		if _, err := ParseOpusHead(data); !errors.Is(err, ErrInvalidData) {
+			t.Errorf("%q: got %v, want ErrInvalidData", data, err)
+		}
+	}
+}
+
+func TestPacketDuration(t *testing.T) {
+	tests := []struct {
+		packet []byte
+		want   time.Duration
+	}{
+		{nil, 0},
+		{[]byte{0 << 3}, 10 * time.Millisecond},                        // SILK NB 10 ms
+		{[]byte{3 << 3}, 60 * time.Millisecond},                        // SILK NB 60 ms
+		{[]byte{16 << 3}, 2500 * time.Microsecond},                     // CELT NB 2.5 ms
+		{[]byte{31 << 3}, 20 * time.Millisecond},                       // CELT FB 20 ms
+		{[]byte{31<<3 | 1}, 40 * time.Millisecond},                     // two equal frames
+		{[]byte{31<<3 | 2}, 40 * time.Millisecond},                     // two frames of different sizes
+		{[]byte{30<<3 | 3, 0x06}, 60 * time.Millisecond},               // six 10 ms frames
+		{[]byte{31<<3 | 3, 0x80 | 0x40 | 0x03}, 60 * time.Millisecond}, // padding and VBR flags ignored
+		{[]byte{31<<3 | 3}, 0},                                         // missing frame count
+	}
+
+	for _, tt := range tests {
+		if got := PacketDuration(tt.packet); got != tt.want {
+			t.Errorf("PacketDuration(%x) = %v, want %v", tt.packet, got, tt.want)
+		}
+	}
+}
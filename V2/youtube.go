@@ -15,10 +15,13 @@ import (
 	"io"
 
 	"github.com/elucid503/overture-play/v2/client"
+	"github.com/elucid503/overture-play/v2/dash"
+	"github.com/elucid503/overture-play/v2/decipher"
 	"github.com/elucid503/overture-play/v2/innertube"
 	"github.com/elucid503/overture-play/v2/pot"
 	"github.com/elucid503/overture-play/v2/stream"
 	"github.com/elucid503/overture-play/v2/types"
+	"github.com/elucid503/overture-play/v2/webm"
 )
 
 // Re-export core types for convenient access
@@ -28,18 +31,101 @@ type (
 	Thumbnail = types.Thumbnail
 	Range     = types.Range
 
+	Selection  = types.Selection
+	FormatExpr = types.FormatExpr
+	FormatSort = types.FormatSort
+
+	Projection = types.Projection
+	ColorInfo  = types.ColorInfo
+	AudioTrack = types.AudioTrack
+
+	CaptionTrack    = types.CaptionTrack
+	CaptionLanguage = types.CaptionLanguage
+	CaptionCue      = types.CaptionCue
+	CaptionFormat   = types.CaptionFormat
+	Transcript      = types.Transcript
+
+	PoTokenContext = types.PoTokenContext
+	PoTokenState   = types.PoTokenState
+	PoTokenPolicy  = types.PoTokenPolicy
+
 	Client        = client.Client
 	ClientOptions = client.ClientOptions
 
+	CaptionOptions = client.CaptionOptions
+
+	PlayerInfo           = client.PlayerInfo
+	PlayerChange         = client.PlayerChange
+	PlayerChangeCallback = client.PlayerChangeCallback
+
 	StreamHandler  = stream.Handler
 	StreamInfo     = stream.StreamInfo
 	StreamProgress = stream.Progress
+	StatusError    = stream.StatusError
+	StreamResolver = stream.Resolver
+	StreamReader   = stream.Reader
 
-	POTProvider = pot.Provider
+	SegmentIndex = dash.SegmentIndex
+	Segment      = dash.Segment
+
+	WebMDemuxer = webm.Demuxer
+	OpusPacket  = webm.Packet
+	OpusHead    = webm.OpusHead
+	OggWriter   = webm.OggWriter
+
+	POTProvider       = pot.Provider
+	POTHealth         = pot.Health
+	POTokenCache      = pot.TokenCache
+	POTokenProvider   = pot.TokenProvider
+	POTokenRequest    = pot.TokenRequest
+	POTokenChain      = pot.Chain
+	StaticPOTProvider = pot.StaticProvider
+	ScriptPOTProvider = pot.ScriptProvider
+
+	PlayerCache     = decipher.PlayerCache
+	PlayerArtifacts = decipher.PlayerArtifacts
+
+	ChallengeSolver  = decipher.ChallengeSolver
+	SubprocessSolver = decipher.SubprocessSolver
 
 	ClientConfig = innertube.ClientConfig
 )
 
+// Re-export error types for matching with errors.As
+type (
+	PlayabilityError      = client.PlayabilityError
+	ClientError           = client.ClientError
+	AllClientsFailedError = client.AllClientsFailedError
+)
+
+// Re-export playability sentinel errors for matching with errors.Is
+var (
+	ErrLoginRequired     = client.ErrLoginRequired
+	ErrBotCheck          = client.ErrBotCheck
+	ErrUnplayable        = client.ErrUnplayable
+	ErrLiveStreamOffline = client.ErrLiveStreamOffline
+	ErrVideoUnavailable  = client.ErrVideoUnavailable
+	ErrNotPlayable       = client.ErrNotPlayable
+	ErrPoTokenRequired   = client.ErrPoTokenRequired
+)
+
+// Re-export download errors
+var (
+	ErrSizeMismatch = stream.ErrSizeMismatch
+	ErrReaderClosed = stream.ErrReaderClosed
+
+	ErrNoIndex        = stream.ErrNoIndex
+	ErrSeekOutOfRange = stream.ErrSeekOutOfRange
+	ErrInvalidIndex   = dash.ErrInvalidIndex
+
+	ErrNoOpusTrack = webm.ErrNoOpusTrack
+)
+
+// Re-export format selection errors
+var ErrFormatNotAvailable = types.ErrFormatNotAvailable
+
+type FormatExprError = types.FormatExprError
+
 // Re-export progress callback type
 type ProgressCallback = stream.ProgressCallback
 
@@ -59,6 +145,22 @@ func GetVideo(videoIDOrURL string) (*Video, error) {
 	return New().GetVideo(videoIDOrURL)
 }
 
+// GetVideoContext fetches video information for the given video ID or URL within the given context
+// This is a convenience function that creates a new client internally
+func GetVideoContext(ctx context.Context, videoIDOrURL string) (*Video, error) {
+	return New().GetVideoContext(ctx, videoIDOrURL)
+}
+
+// SelectFormats picks formats from a video with a yt-dlp style expression such as "bv[height<=1080]+ba/b"
+func SelectFormats(video *Video, expr string) (*Selection, error) {
+	return types.SelectFormats(video, expr)
+}
+
+// SelectFormatsSorted is SelectFormats with a custom ranking such as "res:1080,fps,codec,br"
+func SelectFormatsSorted(video *Video, expr, sort string) (*Selection, error) {
+	return types.SelectFormatsSorted(video, expr, sort)
+}
+
 // NewStreamHandler creates a new stream handler for downloading videos
 func NewStreamHandler() *StreamHandler {
 	return stream.NewHandler()
@@ -75,6 +177,15 @@ func DownloadWithProgress(ctx context.Context, format Format, w io.Writer, callb
 	return stream.NewHandler().DownloadWithProgress(ctx, format, w, callback)
 }
 
+// DownloadToFile downloads a format to path, resuming an earlier interrupted download if possible
+// Stream URLs expire after a few hours, so a download resumed later needs resolver to fetch a fresh one;
+// pass Client.FormatResolver for the video, or nil to fail once the URL is rejected
+func DownloadToFile(ctx context.Context, format Format, path string, resolver StreamResolver) error {
+	h := stream.NewHandler()
+	h.Resolver = resolver
+	return h.DownloadToFile(ctx, format, path)
+}
+
 // GetStream returns a reader for streaming the format
 func GetStream(ctx context.Context, format Format) (io.ReadCloser, int64, error) {
 	return stream.NewHandler().GetStream(ctx, format)
@@ -85,6 +196,46 @@ func GetStreamRange(ctx context.Context, format Format, start, end int64) (io.Re
 	return stream.NewHandler().GetStreamRange(ctx, format, start, end)
 }
 
+// OpenStream returns a seekable reader over the format
+func OpenStream(ctx context.Context, format Format) (*StreamReader, error) {
+	return stream.NewHandler().Open(ctx, format)
+}
+
+// NewWebMDemuxer reads Opus packets from a WebM stream such as the body returned by GetStream
+func NewWebMDemuxer(r io.Reader) (*WebMDemuxer, error) {
+	return webm.NewDemuxer(r)
+}
+
+// RemuxOpus converts a WebM Opus stream to Ogg Opus without re-encoding
+func RemuxOpus(w io.Writer, r io.Reader) error {
+	return webm.RemuxOpus(w, r)
+}
+
+// NewFileCache creates an on-disk player cache rooted at dir
+func NewFileCache(dir string) (*decipher.FileCache, error) {
+	return decipher.NewFileCache(dir)
+}
+
+// NewSubprocessSolver creates a challenge solver that delegates to an external JS runtime
+func NewSubprocessSolver(command string, args ...string) *SubprocessSolver {
+	return decipher.NewSubprocessSolver(command, args...)
+}
+
+// NewPOTokenChain creates a PO token provider that tries each provider in order
+func NewPOTokenChain(providers ...POTokenProvider) *POTokenChain {
+	return pot.NewChain(providers...)
+}
+
+// NewStaticPOTProvider creates a PO token provider serving operator-supplied tokens per context
+func NewStaticPOTProvider(tokens map[PoTokenContext]string) *StaticPOTProvider {
+	return pot.NewStaticProvider(tokens)
+}
+
+// NewScriptPOTProvider creates a PO token provider that runs a bgutil-style script
+func NewScriptPOTProvider(command string, args ...string) *ScriptPOTProvider {
+	return pot.NewScriptProvider(command, args...)
+}
+
 // DefaultClients returns the default list of innertube clients used for fetching
 func DefaultClients() []ClientConfig {
 	return innertube.DefaultClients()